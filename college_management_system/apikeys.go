@@ -0,0 +1,204 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// API keys let machine clients (roster sync, library kiosk) call /api without a human login.
+// A key looks like cms_<key_id>_<secret>; key_id is stored in clear to find the row,
+// the secret only as a SHA-256 hash. Keys are sent in the X-API-Key header and are
+// limited to their scopes, see RouteScopes.
+
+// APIKeyPrefix starts every API key
+const APIKeyPrefix = "cms_"
+
+// Scopes an API key can be granted
+var Scopes = []string{
+	"students:read", "students:write",
+	"lecturers:read", "lecturers:write",
+	"library:read", "library:write",
+}
+
+// APIKey represents a machine client key stored in MySQL.
+// Key is only returned once, when the key is created.
+type APIKey struct {
+	ID         int      `json:"id"`
+	KeyID      string   `json:"key_id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	CreatedBy  string   `json:"created_by"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	RevokedAt  string   `json:"revoked_at,omitempty"`
+	Key        string   `json:"key,omitempty"`
+}
+
+// ValidateAPIKey validates incoming api key data
+func ValidateAPIKey(key APIKey) error {
+	if strings.TrimSpace(key.Name) == "" {
+		return fmt.Errorf("name is invalid and empty")
+	}
+	if len(key.Scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range key.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("invalid scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// hashAPISecret hashes the secret part of a key, secrets are random so SHA-256 is enough
+func hashAPISecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuthenticateAPIKey checks a raw X-API-Key value and returns the key id and scopes.
+func (a *HybridHandler) AuthenticateAPIKey(ctx context.Context, raw string) (int, string, []string, error) {
+	keyID, secret, ok := strings.Cut(strings.TrimPrefix(raw, APIKeyPrefix), "_")
+	if !strings.HasPrefix(raw, APIKeyPrefix) || !ok {
+		return 0, "", nil, fmt.Errorf("malformed api key")
+	}
+
+	key, hash, err := a.APIKeys.Active(ctx, keyID)
+	if err == ErrNotFound {
+		return 0, "", nil, fmt.Errorf("invalid or revoked api key")
+	}
+	if err != nil {
+		return 0, "", nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashAPISecret(secret))) != 1 {
+		return 0, "", nil, fmt.Errorf("invalid or revoked api key")
+	}
+	return key.ID, keyID, key.Scopes, nil
+}
+
+// CreateAPIKeyHandler godoc
+// @Summary Create API key
+// @Description Create a scoped key for a machine client. The key is only shown in this response.
+// @Tags API Keys
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param key body APIKey true "Name and scopes"
+// @Success 201 {object} APIKey
+// @Failure 400 {object} map[string]string
+// @Router /api/apikeys [post]
+// CreateAPIKeyHandler handles creation of a new api key
+func (a *HybridHandler) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Decode incoming JSON request body
+	var key APIKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+
+	// validate requests payload
+	if err := ValidateAPIKey(key); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"err": err.Error()})
+		return
+	}
+
+	// generate the key, only the hash of the secret is stored
+	key.KeyID = randomID()[:16]
+	secret := randomID() + randomID()
+	key.Key = APIKeyPrefix + key.KeyID + "_" + secret
+	key.CreatedBy = Actor(r)
+
+	if err := a.APIKeys.Create(r.Context(), &key, hashAPISecret(secret)); err != nil {
+		http.Error(w, "Unable to insert", http.StatusInternalServerError)
+		return
+	}
+
+	// Log activity and Audit trail
+	go LogActivity("CREATE_API_KEY", Actor(r))
+	go AuditLog("CREATE", "API_KEY", key.KeyID, Actor(r))
+
+	// send success response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+// GetAPIKeysHandler godoc
+// @Summary Get all API keys
+// @Description List API keys with their scopes and last use, without the secret
+// @Tags API Keys
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} APIKey
+// @Router /api/apikeys [get]
+// GetAPIKeysHandler to get all api keys
+func (a *HybridHandler) GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+
+	// fetch api key records
+	keys, err := a.APIKeys.List(r.Context())
+	if err != nil {
+		http.Error(w, "unable to fetch api keys", http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []APIKey{}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKeyHandler godoc
+// @Summary Revoke API key
+// @Tags API Keys
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/apikeys/{id} [delete]
+// RevokeAPIKeyHandler revokes an api key by ID, the row is kept for the audit trail
+func (a *HybridHandler) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	vars := mux.Vars(r)
+	idINT, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// revoke the key if an active one exsists
+	err = a.APIKeys.Revoke(r.Context(), idINT, time.Now())
+	if err == ErrNotFound {
+		http.Error(w, "api key not found or already revoked", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "unable to revoke", http.StatusInternalServerError)
+		return
+	}
+
+	// Log revoke action
+	go LogActivity("REVOKE_API_KEY", Actor(r))
+	go AuditLog("REVOKE", "API_KEY", idINT, Actor(r))
+
+	// Send success response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "api key revoked"})
+}
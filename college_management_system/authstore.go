@@ -0,0 +1,314 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// AuthStore keeps the short-lived login state in expiring keys: login failure counters
+// and lockouts, refresh token sessions, one-time tokens and used TOTP steps.
+// The key layout is described in loginlimiter.go, sessions.go and verification.go.
+// Reads of missing keys return ErrNotFound.
+type AuthStore interface {
+	// TTL returns the time left of key, 0 when it does not exist or never expires
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX sets key only when it does not exist and reports whether it was set
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// Incr increments the counter at key, a missing key starts at 0 without expiry
+	Incr(ctx context.Context, key string) (int64, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	// Del deletes keys and returns how many existed
+	Del(ctx context.Context, keys ...string) (int64, error)
+	// HGet returns a field of the hash at key
+	HGet(ctx context.Context, key, field string) (string, error)
+	// HIncrBy increments a field of the hash at key and returns the new value
+	HIncrBy(ctx context.Context, key, field string, by int64) (int64, error)
+	// SMembers returns the members of the set at key, none when it does not exist
+	SMembers(ctx context.Context, key string) ([]string, error)
+
+	// AddRefresh records the refresh jti in its family and the family in the sessions
+	// of email, all at once and for ttl
+	AddRefresh(ctx context.Context, email, family, jti string, ttl time.Duration) error
+	// RevokeFamily deletes every jti of family and the family itself, and removes it
+	// from the sessions of email, all at once
+	RevokeFamily(ctx context.Context, email, family string) error
+}
+
+// RedisAuthStore keeps the login state in Redis
+type RedisAuthStore struct {
+	Client *redis.Client
+}
+
+func (s *RedisAuthStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.Client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// -2 is a missing key, -1 a key without expiry
+	return max(ttl, 0), nil
+}
+
+func (s *RedisAuthStore) Get(ctx context.Context, key string) (string, error) {
+	value, err := s.Client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (s *RedisAuthStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return s.Client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisAuthStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return s.Client.SetNX(ctx, key, value, ttl).Result()
+}
+
+func (s *RedisAuthStore) Incr(ctx context.Context, key string) (int64, error) {
+	return s.Client.Incr(ctx, key).Result()
+}
+
+func (s *RedisAuthStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return s.Client.Expire(ctx, key, ttl).Err()
+}
+
+func (s *RedisAuthStore) Del(ctx context.Context, keys ...string) (int64, error) {
+	return s.Client.Del(ctx, keys...).Result()
+}
+
+func (s *RedisAuthStore) HGet(ctx context.Context, key, field string) (string, error) {
+	value, err := s.Client.HGet(ctx, key, field).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (s *RedisAuthStore) HIncrBy(ctx context.Context, key, field string, by int64) (int64, error) {
+	return s.Client.HIncrBy(ctx, key, field, by).Result()
+}
+
+func (s *RedisAuthStore) SMembers(ctx context.Context, key string) ([]string, error) {
+	return s.Client.SMembers(ctx, key).Result()
+}
+
+func (s *RedisAuthStore) AddRefresh(ctx context.Context, email, family, jti string, ttl time.Duration) error {
+	pipe := s.Client.TxPipeline()
+	pipe.HSet(ctx, refreshKey(jti), "family", family, "email", email, "used", 0)
+	pipe.Expire(ctx, refreshKey(jti), ttl)
+	pipe.SAdd(ctx, refreshFamilyKey(family), jti)
+	pipe.Expire(ctx, refreshFamilyKey(family), ttl)
+	pipe.SAdd(ctx, userSessionsKey(email), family)
+	pipe.Expire(ctx, userSessionsKey(email), ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisAuthStore) RevokeFamily(ctx context.Context, email, family string) error {
+	jtis, err := s.Client.SMembers(ctx, refreshFamilyKey(family)).Result()
+	if err != nil {
+		return err
+	}
+	keys := []string{refreshFamilyKey(family)}
+	for _, jti := range jtis {
+		keys = append(keys, refreshKey(jti))
+	}
+
+	pipe := s.Client.TxPipeline()
+	pipe.Del(ctx, keys...)
+	pipe.SRem(ctx, userSessionsKey(email), family)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// MemoryAuthStore keeps the login state in process memory, for tests and demos
+type MemoryAuthStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryAuthEntry
+}
+
+// memoryAuthEntry is a string, hash or set value, a zero expires never expires
+type memoryAuthEntry struct {
+	value   string
+	fields  map[string]string
+	members map[string]bool
+	expires time.Time
+}
+
+// NewMemoryAuthStore returns an empty MemoryAuthStore
+func NewMemoryAuthStore() *MemoryAuthStore {
+	return &MemoryAuthStore{entries: map[string]*memoryAuthEntry{}}
+}
+
+// entry returns the live entry of key, expired entries are dropped
+func (s *MemoryAuthStore) entry(key string) (*memoryAuthEntry, bool) {
+	e, ok := s.entries[key]
+	if ok && !e.expires.IsZero() && time.Now().After(e.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return e, ok
+}
+
+// create returns the live entry of key, adding an empty one without expiry when missing
+func (s *MemoryAuthStore) create(key string) *memoryAuthEntry {
+	e, ok := s.entry(key)
+	if !ok {
+		e = &memoryAuthEntry{fields: map[string]string{}, members: map[string]bool{}}
+		s.entries[key] = e
+	}
+	return e
+}
+
+// authExpiry returns when an entry set now for ttl expires
+func authExpiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func (s *MemoryAuthStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entry(key)
+	if !ok || e.expires.IsZero() {
+		return 0, nil
+	}
+	return time.Until(e.expires), nil
+}
+
+func (s *MemoryAuthStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entry(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+func (s *MemoryAuthStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = &memoryAuthEntry{value: value, expires: authExpiry(ttl)}
+	return nil
+}
+
+func (s *MemoryAuthStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entry(key); ok {
+		return false, nil
+	}
+	s.entries[key] = &memoryAuthEntry{value: value, expires: authExpiry(ttl)}
+	return true, nil
+}
+
+func (s *MemoryAuthStore) Incr(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.create(key)
+	n, _ := strconv.ParseInt(e.value, 10, 64)
+	n++
+	e.value = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+func (s *MemoryAuthStore) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entry(key); ok {
+		e.expires = authExpiry(ttl)
+	}
+	return nil
+}
+
+func (s *MemoryAuthStore) Del(ctx context.Context, keys ...string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	for _, key := range keys {
+		if _, ok := s.entry(key); ok {
+			delete(s.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *MemoryAuthStore) HGet(ctx context.Context, key, field string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entry(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	value, ok := e.fields[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *MemoryAuthStore) HIncrBy(ctx context.Context, key, field string, by int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.create(key)
+	n, _ := strconv.ParseInt(e.fields[field], 10, 64)
+	n += by
+	e.fields[field] = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+func (s *MemoryAuthStore) SMembers(ctx context.Context, key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entry(key)
+	if !ok {
+		return nil, nil
+	}
+	return slices.Collect(maps.Keys(e.members)), nil
+}
+
+func (s *MemoryAuthStore) AddRefresh(ctx context.Context, email, family, jti string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[refreshKey(jti)] = &memoryAuthEntry{
+		fields:  map[string]string{"family": family, "email": email, "used": "0"},
+		expires: authExpiry(ttl),
+	}
+	for key, member := range map[string]string{refreshFamilyKey(family): jti, userSessionsKey(email): family} {
+		e := s.create(key)
+		e.members[member] = true
+		e.expires = authExpiry(ttl)
+	}
+	return nil
+}
+
+func (s *MemoryAuthStore) RevokeFamily(ctx context.Context, email, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entry(refreshFamilyKey(family)); ok {
+		for jti := range e.members {
+			delete(s.entries, refreshKey(jti))
+		}
+		delete(s.entries, refreshFamilyKey(family))
+	}
+	if e, ok := s.entry(userSessionsKey(email)); ok {
+		delete(e.members, family)
+		// like Redis, an empty set is gone
+		if len(e.members) == 0 {
+			delete(s.entries, userSessionsKey(email))
+		}
+	}
+	return nil
+}
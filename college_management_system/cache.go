@@ -0,0 +1,469 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrCacheMiss is returned by Cache.Get when the key is not cached
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cache is the key/value store behind EntityCache
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+	// TTL returns the time left of key, ErrCacheMiss when it is not cached
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// DelPrefix deletes every key starting with prefix and returns how many were deleted
+	DelPrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// RedisCache keeps cache entries in Redis
+type RedisCache struct {
+	Client *redis.Client
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) (string, error) {
+	value, err := c.Client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrCacheMiss
+	}
+	return value, err
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.Client.Set(ctx, key, value, ttl).Err()
+}
+
+func (c *RedisCache) Del(ctx context.Context, key string) error {
+	return c.Client.Del(ctx, key).Err()
+}
+
+func (c *RedisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := c.Client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// -2 is a missing key, -1 a key without expiry
+	if ttl == -2*time.Millisecond {
+		return 0, ErrCacheMiss
+	}
+	return max(ttl, 0), nil
+}
+
+func (c *RedisCache) DelPrefix(ctx context.Context, prefix string) (int, error) {
+	// SCAN instead of KEYS, so a large namespace doesn't block Redis
+	deleted := 0
+	iter := c.Client.Scan(ctx, 0, prefix+"*", 500).Iterator()
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := c.Client.Del(ctx, batch...).Result()
+		deleted += int(n)
+		batch = batch[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == 500 {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return deleted, err
+	}
+	return deleted, flush()
+}
+
+// MemoryCache keeps cache entries in process memory, for tests and demos
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+// NewMemoryCache returns an empty MemoryCache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]memoryCacheEntry{}}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return "", ErrCacheMiss
+	}
+	return e.value, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = memoryCacheEntry{value: string(value), expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (c *MemoryCache) Del(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	return nil
+}
+
+func (c *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	left := time.Until(e.expires)
+	if !ok || left <= 0 {
+		return 0, ErrCacheMiss
+	}
+	return left, nil
+}
+
+func (c *MemoryCache) DelPrefix(ctx context.Context, prefix string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deleted := 0
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// CacheKeyPrefix namespaces every key of the application in a shared Redis
+const CacheKeyPrefix = "cms:"
+
+// DefaultCacheTTL is used when CACHE_TTL is not set
+const DefaultCacheTTL = 10 * time.Second
+
+// cacheTimeout bounds every cache call, a slow Redis must not hold up requests
+const cacheTimeout = 250 * time.Millisecond
+
+// cacheRetryAfter is how long the cache is skipped after it failed
+const cacheRetryAfter = 5 * time.Second
+
+// cacheBreaker takes the cache out of the read path for a while after a failure,
+// so while Redis is down requests go straight to the database instead of waiting
+// on Redis and failing one by one.
+type cacheBreaker struct {
+	downUntil atomic.Int64
+}
+
+func (b *cacheBreaker) available() bool {
+	return time.Now().UnixNano() >= b.downUntil.Load()
+}
+
+// check records the outcome of a cache call, cache misses are not failures
+func (b *cacheBreaker) check(key string, op string, err error) {
+	if err == nil || err == ErrCacheMiss {
+		return
+	}
+	if b.available() {
+		log.Printf("[CACHE] %s %s: %v, serving from the database for %s\n", op, key, err, cacheRetryAfter)
+	}
+	b.downUntil.Store(time.Now().Add(cacheRetryAfter).UnixNano())
+}
+
+// CacheStats are the counters of one entity cache
+type CacheStats struct {
+	Entity string `json:"entity"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Errors counts failed cache calls
+	Errors uint64 `json:"errors"`
+	// Bypassed counts reads served from the database because the cache was down
+	Bypassed   uint64  `json:"bypassed"`
+	TTLSeconds float64 `json:"ttl_seconds"`
+}
+
+// CacheEntry is what an entity cache holds for one id
+type CacheEntry struct {
+	Key    string          `json:"key"`
+	Cached bool            `json:"cached"`
+	TTL    float64         `json:"ttl_seconds"`
+	Value  json.RawMessage `json:"value,omitempty" swaggertype:"object"`
+}
+
+// EntityCache is a read-through cache of one entity type, keyed "cms:<entity>:<id>".
+// Concurrent misses of the same key share one load, so a hot key expiring
+// causes a single repository read instead of a stampede.
+type EntityCache[T any] struct {
+	cache   Cache
+	entity  string
+	prefix  string
+	ttl     time.Duration
+	group   singleflight.Group
+	breaker *cacheBreaker
+
+	// generation counts invalidations, a load that overlapped one doesn't cache its possibly old value
+	generation atomic.Uint64
+
+	hits, misses, errors, bypassed atomic.Uint64
+}
+
+// NewEntityCache returns the cache of entity, entries live for ttl
+func NewEntityCache[T any](cache Cache, entity string, ttl time.Duration) *EntityCache[T] {
+	return &EntityCache[T]{cache: cache, entity: entity, prefix: CacheKeyPrefix + entity + ":", ttl: ttl, breaker: &cacheBreaker{}}
+}
+
+// Entity returns the entity name, the middle part of the keys
+func (c *EntityCache[T]) Entity() string {
+	return c.entity
+}
+
+// Key returns the cache key of id
+func (c *EntityCache[T]) Key(id int) string {
+	return c.prefix + strconv.Itoa(id)
+}
+
+// call runs one cache operation with cacheTimeout, counting and reporting failures
+func (c *EntityCache[T]) call(ctx context.Context, key, op string, f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+	err := f(ctx)
+	if err != nil && err != ErrCacheMiss {
+		c.errors.Add(1)
+	}
+	c.breaker.check(key, op, err)
+	return err
+}
+
+// Get returns the cached entity or loads it with load and caches it.
+// Errors of load, e.g. ErrNotFound, are returned and not cached.
+// While the cache is down Get only loads.
+func (c *EntityCache[T]) Get(ctx context.Context, id int, load func(ctx context.Context, id int) (T, error)) (T, error) {
+	key := c.Key(id)
+	var value T
+	if !c.breaker.available() {
+		c.bypassed.Add(1)
+		return load(ctx, id)
+	}
+
+	var cached string
+	err := c.call(ctx, key, "get", func(ctx context.Context) (err error) {
+		cached, err = c.cache.Get(ctx, key)
+		return err
+	})
+	if err == nil && json.Unmarshal([]byte(cached), &value) == nil {
+		c.hits.Add(1)
+		return value, nil
+	}
+	if err != nil && err != ErrCacheMiss {
+		c.bypassed.Add(1)
+		return load(ctx, id)
+	}
+	c.misses.Add(1)
+
+	// the load is shared, so it must not be cancelled when the first caller goes away
+	shared := context.WithoutCancel(ctx)
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		generation := c.generation.Load()
+		value, err := load(shared, id)
+		if err != nil {
+			return value, err
+		}
+		if generation == c.generation.Load() {
+			c.set(shared, id, value)
+		}
+		return value, nil
+	})
+	if err != nil {
+		return value, err
+	}
+	return v.(T), nil
+}
+
+// set stores value under id, failures only count and log
+func (c *EntityCache[T]) set(ctx context.Context, id int, value T) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	key := c.Key(id)
+	return c.call(ctx, key, "set", func(ctx context.Context) error {
+		return c.cache.Set(ctx, key, data, c.ttl)
+	})
+}
+
+// Invalidate drops the cached entity, call it after every write to the entity
+func (c *EntityCache[T]) Invalidate(ctx context.Context, id int) {
+	key := c.Key(id)
+	// a load already running may hold the old row, don't cache it or hand it to later callers
+	c.generation.Add(1)
+	c.group.Forget(key)
+	c.call(ctx, key, "del", func(ctx context.Context) error {
+		return c.cache.Del(ctx, key)
+	})
+}
+
+// Stats returns the counters since the start of the process
+func (c *EntityCache[T]) Stats() CacheStats {
+	return CacheStats{
+		Entity:     c.entity,
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Errors:     c.errors.Load(),
+		Bypassed:   c.bypassed.Load(),
+		TTLSeconds: c.ttl.Seconds(),
+	}
+}
+
+// Inspect returns the cached value of id and its time left
+func (c *EntityCache[T]) Inspect(ctx context.Context, id int) (CacheEntry, error) {
+	key := c.Key(id)
+	entry := CacheEntry{Key: key}
+	var value string
+	var ttl time.Duration
+	err := c.call(ctx, key, "get", func(ctx context.Context) (err error) {
+		if value, err = c.cache.Get(ctx, key); err != nil {
+			return err
+		}
+		ttl, err = c.cache.TTL(ctx, key)
+		return err
+	})
+	if err == ErrCacheMiss {
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+	entry.Cached, entry.TTL, entry.Value = true, ttl.Seconds(), json.RawMessage(value)
+	return entry, nil
+}
+
+// Flush deletes every entry of the entity and returns how many were deleted
+func (c *EntityCache[T]) Flush(ctx context.Context) (int, error) {
+	c.generation.Add(1)
+	// flushing a large namespace takes longer than one call, so no cacheTimeout here
+	deleted, err := c.cache.DelPrefix(ctx, c.prefix)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	c.breaker.check(c.prefix+"*", "flush", err)
+	return deleted, err
+}
+
+// Warm loads ids with load and caches them, it returns the ids that were not found.
+// It stops at the first other error.
+func (c *EntityCache[T]) Warm(ctx context.Context, ids []int, load func(ctx context.Context, id int) (T, error)) (warmed int, missing []int, err error) {
+	missing = []int{}
+	for _, id := range ids {
+		value, err := load(ctx, id)
+		if err == ErrNotFound {
+			missing = append(missing, id)
+			continue
+		}
+		if err != nil {
+			return warmed, missing, err
+		}
+		if err := c.set(ctx, id, value); err != nil {
+			return warmed, missing, err
+		}
+		warmed++
+	}
+	return warmed, missing, nil
+}
+
+// CacheNamespace is the type independent part of an EntityCache, used by the cache admin API
+type CacheNamespace interface {
+	Entity() string
+	Stats() CacheStats
+	Inspect(ctx context.Context, id int) (CacheEntry, error)
+	Flush(ctx context.Context) (int, error)
+}
+
+// CacheTTLs are the entry lifetimes per entity
+type CacheTTLs struct {
+	Students  time.Duration
+	Lecturers time.Duration
+	Books     time.Duration
+}
+
+// CacheTTLsFromEnv reads CACHE_TTL (default 10s) and the per entity overrides
+// CACHE_TTL_STUDENTS, CACHE_TTL_LECTURERS and CACHE_TTL_BOOKS, e.g. "30s" or "5m"
+func CacheTTLsFromEnv() CacheTTLs {
+	ttl := func(name string, fallback time.Duration) time.Duration {
+		v := os.Getenv(name)
+		if v == "" {
+			return fallback
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Printf("invalid %s %q, using %s\n", name, v, fallback)
+			return fallback
+		}
+		return d
+	}
+	def := ttl("CACHE_TTL", DefaultCacheTTL)
+	return CacheTTLs{
+		Students:  ttl("CACHE_TTL_STUDENTS", def),
+		Lecturers: ttl("CACHE_TTL_LECTURERS", def),
+		Books:     ttl("CACHE_TTL_BOOKS", def),
+	}
+}
+
+// EntityCaches groups the entity caches used by HybridHandler
+type EntityCaches struct {
+	Students  *EntityCache[Student]
+	Lecturers *EntityCache[Lecturer]
+	Books     *EntityCache[Library]
+}
+
+// NewEntityCaches returns the entity caches stored in cache.
+// They share one breaker, when Redis fails every entity skips it.
+func NewEntityCaches(cache Cache, ttls CacheTTLs) EntityCaches {
+	c := EntityCaches{
+		Students:  NewEntityCache[Student](cache, "student", ttls.Students),
+		Lecturers: NewEntityCache[Lecturer](cache, "lecturer", ttls.Lecturers),
+		Books:     NewEntityCache[Library](cache, "book", ttls.Books),
+	}
+	breaker := &cacheBreaker{}
+	c.Students.breaker, c.Lecturers.breaker, c.Books.breaker = breaker, breaker, breaker
+	return c
+}
+
+// All returns every entity cache
+func (c EntityCaches) All() []CacheNamespace {
+	return []CacheNamespace{c.Students, c.Lecturers, c.Books}
+}
+
+// Namespace returns the entity cache named entity, nil when there is none
+func (c EntityCaches) Namespace(entity string) CacheNamespace {
+	for _, n := range c.All() {
+		if n.Entity() == entity {
+			return n
+		}
+	}
+	return nil
+}
+
+// Available reports whether the cache is in use, false while it is skipped after a failure
+func (c EntityCaches) Available() bool {
+	return c.Students.breaker.available()
+}
@@ -0,0 +1,197 @@
+package collegemanagementsystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// MaxWarmIDs caps the ids of one warm request
+const MaxWarmIDs = 1000
+
+// CacheStatsResponse is the body of GET /api/cache
+type CacheStatsResponse struct {
+	// Available is false while the cache is skipped after a failure
+	Available bool         `json:"available"`
+	Entities  []CacheStats `json:"entities"`
+}
+
+// WarmCacheRequest is the body of POST /api/cache/{entity}/warm
+type WarmCacheRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// cacheNamespace looks up the {entity} of the URL, answering 404 when it is unknown
+func (a *HybridHandler) cacheNamespace(w http.ResponseWriter, r *http.Request) (CacheNamespace, bool) {
+	entity := mux.Vars(r)["entity"]
+	n := a.Caches.Namespace(entity)
+	if n == nil {
+		var names []string
+		for _, n := range a.Caches.All() {
+			names = append(names, n.Entity())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"err": fmt.Sprintf("unknown cache %q, use one of %s", entity, strings.Join(names, ", "))})
+		return nil, false
+	}
+	return n, true
+}
+
+// GetCacheStatsHandler godoc
+// @Summary Cache statistics
+// @Description Hit, miss, error and bypass counters per entity since the server started
+// @Tags Cache
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} CacheStatsResponse
+// @Router /api/cache [get]
+// GetCacheStatsHandler returns the cache counters
+func (a *HybridHandler) GetCacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats := CacheStatsResponse{Available: a.Caches.Available(), Entities: []CacheStats{}}
+	for _, n := range a.Caches.All() {
+		stats.Entities = append(stats.Entities, n.Stats())
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// InspectCacheHandler godoc
+// @Summary Inspect a cache entry
+// @Tags Cache
+// @Security BearerAuth
+// @Produce json
+// @Param entity path string true "student, lecturer or book"
+// @Param id path int true "Record ID"
+// @Success 200 {object} CacheEntry
+// @Failure 404 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/cache/{entity}/{id} [get]
+// InspectCacheHandler shows what the cache holds for one record
+func (a *HybridHandler) InspectCacheHandler(w http.ResponseWriter, r *http.Request) {
+	n, ok := a.cacheNamespace(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract id from URL
+	idINT, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := n.Inspect(r.Context(), idINT)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"err": "cache unavailable: " + err.Error()})
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+// FlushCacheHandler godoc
+// @Summary Flush an entity cache
+// @Description Delete every cached record of the entity
+// @Tags Cache
+// @Security BearerAuth
+// @Produce json
+// @Param entity path string true "student, lecturer or book"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/cache/{entity} [delete]
+// FlushCacheHandler deletes the namespace of one entity
+func (a *HybridHandler) FlushCacheHandler(w http.ResponseWriter, r *http.Request) {
+	n, ok := a.cacheNamespace(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := n.Flush(r.Context())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"err": "cache unavailable: " + err.Error()})
+		return
+	}
+
+	// Log flush actions
+	go LogActivity("FLUSH_CACHE_"+strings.ToUpper(n.Entity()), Actor(r))
+	go AuditLog("FLUSH", "CACHE", n.Entity(), Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"entity": n.Entity(), "deleted": deleted})
+}
+
+// WarmCacheHandler godoc
+// @Summary Warm an entity cache
+// @Description Load the given records from the database into the cache
+// @Tags Cache
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param entity path string true "student, lecturer or book"
+// @Param ids body WarmCacheRequest true "Record IDs"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/cache/{entity}/warm [post]
+// WarmCacheHandler caches a list of records ahead of the reads
+func (a *HybridHandler) WarmCacheHandler(w http.ResponseWriter, r *http.Request) {
+	n, ok := a.cacheNamespace(w, r)
+	if !ok {
+		return
+	}
+
+	// Decode request body
+	var req WarmCacheRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > MaxWarmIDs {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"err": fmt.Sprintf("ids must hold 1 to %d ids", MaxWarmIDs)})
+		return
+	}
+
+	// every entity loads from its own repository
+	var warmed int
+	var missing []int
+	var err error
+	switch n.Entity() {
+	case a.Caches.Students.Entity():
+		warmed, missing, err = a.Caches.Students.Warm(r.Context(), req.IDs, a.Students.Get)
+	case a.Caches.Lecturers.Entity():
+		warmed, missing, err = a.Caches.Lecturers.Warm(r.Context(), req.IDs, a.Lecturers.Get)
+	case a.Caches.Books.Entity():
+		warmed, missing, err = a.Caches.Books.Warm(r.Context(), req.IDs, a.Libraries.Get)
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]any{"err": "unable to warm cache: " + err.Error(), "warmed": warmed})
+		return
+	}
+
+	// Log warm actions
+	go LogActivity("WARM_CACHE_"+strings.ToUpper(n.Entity()), Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"entity": n.Entity(), "warmed": warmed, "missing": missing})
+}
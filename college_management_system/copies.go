@@ -0,0 +1,300 @@
+package collegemanagementsystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Every library book has physical copies, each with its own barcode. Borrow and return
+// scan a copy, and available_copies of the book is derived from its copies: a copy counts
+// while it is not on loan, kept for a hold, damaged or lost.
+
+// Conditions of a copy
+const (
+	CopyNew     = "new"
+	CopyGood    = "good"
+	CopyWorn    = "worn"
+	CopyDamaged = "damaged"
+	CopyLost    = "lost"
+)
+
+// CopyConditions lists every condition, damaged and lost copies can't be borrowed
+var CopyConditions = []string{CopyNew, CopyGood, CopyWorn, CopyDamaged, CopyLost}
+
+// BookCopy is one physical copy of a library book
+type BookCopy struct {
+	CopyID        int    `json:"copy_id"`
+	BookID        int    `json:"book_id"`
+	Barcode       string `json:"barcode"`
+	Condition     string `json:"condition"`
+	ShelfLocation string `json:"shelf_location"`
+	// OnLoan is set while the copy is borrowed
+	OnLoan bool `json:"on_loan"`
+	// OnHold is set while the copy is kept for a ready hold
+	OnHold bool `json:"on_hold"`
+}
+
+// Lendable reports whether the copy can be borrowed by anyone
+func (c BookCopy) Lendable() bool {
+	return !c.OnLoan && !c.OnHold && c.Condition != CopyDamaged && c.Condition != CopyLost
+}
+
+// keptFor marks c on hold when one of the ready holds got it
+func (c *BookCopy) keptFor(ready []Hold) {
+	for _, hold := range ready {
+		if hold.Barcode == c.Barcode {
+			c.OnHold = true
+		}
+	}
+}
+
+// copyPatchFields are the fields a PATCH may change, barcode and book are fixed
+var copyPatchFields = []string{"condition", "shelf_location"}
+
+// copyRules validate a copy field by field
+var copyRules = []fieldRule[BookCopy]{
+	// validate barcode
+	{"barcode", func(c BookCopy) error {
+		if strings.TrimSpace(c.Barcode) == "" {
+			return fmt.Errorf("barcode is invalid and empty")
+		}
+		if len(c.Barcode) > 64 || strings.ContainsAny(c.Barcode, " \t\n/") {
+			return fmt.Errorf("barcode must be at most 64 characters without spaces or /")
+		}
+		return nil
+	}},
+	// validate condition
+	{"condition", func(c BookCopy) error {
+		if !slices.Contains(CopyConditions, c.Condition) {
+			return fmt.Errorf("condition must be one of %s", strings.Join(CopyConditions, ", "))
+		}
+		return nil
+	}},
+	// validate shelf_location
+	{"shelf_location", func(c BookCopy) error {
+		if len(c.ShelfLocation) > 100 {
+			return fmt.Errorf("shelf_location must be at most 100 characters")
+		}
+		return nil
+	}},
+}
+
+// ValidateBookCopy ensures copy input data is valid before DB operations
+func ValidateBookCopy(c BookCopy) error {
+	return validateFields(c, copyRules, nil)
+}
+
+// CreateCopyHandler godoc
+// @Summary Add a copy of a book
+// @Description Register a physical copy by its barcode, the condition defaults to good
+// @Tags Copies
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Library ID"
+// @Param copy body BookCopy true "Barcode, condition and shelf location"
+// @Success 201 {object} BookCopy
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/libraries/{id}/copies [post]
+// CreateCopyHandler adds a physical copy to a book
+func (h *HybridHandler) CreateCopyHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract library id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	// Decode request body
+	var c BookCopy
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	c.BookID, c.OnLoan, c.OnHold = idInt, false, false
+	c.Barcode = strings.TrimSpace(c.Barcode)
+	if c.Condition == "" {
+		c.Condition = CopyGood
+	}
+
+	// validate copy
+	if err := ValidateBookCopy(c); err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	// Insert the copy, the book gains an available copy
+	err := h.Copies.Create(r.Context(), &c)
+	if err == ErrNotFound {
+		http.Error(w, "Book not found", http.StatusNotFound)
+		return
+	}
+	if err == ErrDuplicateBarcode {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"err": "barcode " + c.Barcode + " is already in use"})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// available copies changed, drop the cached book and serve the hold queue
+	h.Caches.Books.Invalidate(r.Context(), idInt)
+	c.keptFor(h.assignHolds(r.Context(), idInt))
+
+	// Log create actions
+	go LogActivity("CREATE_COPY", Actor(r))
+	go AuditLog("CREATE", "COPY", c.CopyID, Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// GetCopiesHandler godoc
+// @Summary List the copies of a book
+// @Tags Copies
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Library ID"
+// @Success 200 {array} BookCopy
+// @Failure 404 {object} map[string]string
+// @Router /api/libraries/{id}/copies [get]
+// GetCopiesHandler lists the physical copies of a book by barcode
+func (h *HybridHandler) GetCopiesHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract library id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	// the book must exist, an empty list means it has no copies
+	if _, err := h.Libraries.Get(r.Context(), idInt); err == ErrNotFound {
+		http.Error(w, "Book not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	copies, err := h.Copies.List(r.Context(), idInt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if copies == nil {
+		copies = []BookCopy{}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(copies)
+}
+
+// GetCopyHandler godoc
+// @Summary Look up a copy by barcode
+// @Tags Copies
+// @Security BearerAuth
+// @Produce json
+// @Param barcode path string true "Copy barcode"
+// @Success 200 {object} BookCopy
+// @Failure 404 {object} map[string]string
+// @Router /api/copies/{barcode} [get]
+// GetCopyHandler returns the copy with a scanned barcode
+func (h *HybridHandler) GetCopyHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract barcode from URL
+	barcode := mux.Vars(r)["barcode"]
+
+	c, err := h.Copies.Get(r.Context(), barcode)
+	if err == ErrNotFound {
+		http.Error(w, "copy not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// PatchCopyHandler godoc
+// @Summary Patch a copy
+// @Description Change condition or shelf location with a JSON merge patch (RFC 7396), e.g. {"condition": "damaged"}. Damaged and lost copies can't be borrowed, a hold waiting on a copy that is damaged or lost goes back to the queue.
+// @Tags Copies
+// @Security BearerAuth
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param barcode path string true "Copy barcode"
+// @Param patch body BookCopy true "Fields to change"
+// @Success 200 {object} BookCopy
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Router /api/copies/{barcode} [patch]
+// PatchCopyHandler changes the condition or shelf location of a copy
+func (h *HybridHandler) PatchCopyHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract barcode from URL
+	barcode := mux.Vars(r)["barcode"]
+
+	// copies have no version, If-Match is not used
+	patch, _, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	// Load the current copy, the patch applies to it
+	current, err := h.Copies.Get(r.Context(), barcode)
+	if err == ErrNotFound {
+		http.Error(w, "copy not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Apply the patch and validate the changed fields only
+	c, fields, err := applyMergePatch(current, patch, copyPatchFields)
+	if err != nil {
+		writePatchError(w, err)
+		return
+	}
+	if err := validateFields(c, copyRules, fields); err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	if len(fields) > 0 {
+		// the condition decides whether the copy counts as available
+		err = h.Copies.Update(r.Context(), &c)
+		if err == ErrNotFound {
+			http.Error(w, "copy not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.Caches.Books.Invalidate(r.Context(), c.BookID)
+		c.keptFor(h.assignHolds(r.Context(), c.BookID))
+
+		// Log patch actions
+		go LogActivity("PATCH_COPY", Actor(r))
+		go AuditLog("PATCH", "COPY", c.CopyID, Actor(r))
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
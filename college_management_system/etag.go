@@ -0,0 +1,66 @@
+package collegemanagementsystem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Students, lecturers and books carry a version that is bumped on every write.
+// GET by id sends it as the ETag and answers 304 to a matching If-None-Match.
+// PUT must send the ETag back in If-Match: 428 without it, 412 when the record
+// changed in the meantime, so two people editing the same record can't overwrite each other.
+
+// ETag returns the entity tag of a record version
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// notModified answers 304 when If-None-Match holds etag (or "*"), the caller then stops
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	// If-None-Match uses the weak comparison, W/ prefixes are ignored
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion returns the version named by If-Match, 0 for "*" (any version).
+// It answers 428 when the header is missing and 400 when it is not one of our ETags.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(map[string]string{"err": "If-Match header required, send the ETag of the record you read"})
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || version <= 0 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"err": "If-Match must hold one ETag like \"3\""})
+		return 0, false
+	}
+	return version, true
+}
+
+// writeVersionConflict answers 412 for an update based on an old version
+func writeVersionConflict(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(map[string]string{"err": "the record was modified since you read it, fetch it again and retry"})
+}
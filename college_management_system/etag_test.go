@@ -0,0 +1,84 @@
+package collegemanagementsystem
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version int
+		ok      bool
+		status  int
+	}{
+		{"missing", "", 0, false, http.StatusPreconditionRequired},
+		{"blank", "   ", 0, false, http.StatusPreconditionRequired},
+		{"any version", "*", 0, true, 0},
+		{"etag", `"3"`, 3, true, 0},
+		{"etag with spaces", ` "12" `, 12, true, 0},
+		{"unquoted", "3", 0, false, http.StatusBadRequest},
+		{"weak etag", `W/"3"`, 0, false, http.StatusBadRequest},
+		{"zero", `"0"`, 0, false, http.StatusBadRequest},
+		{"negative", `"-1"`, 0, false, http.StatusBadRequest},
+		{"not a number", `"abc"`, 0, false, http.StatusBadRequest},
+		{"list", `"3", "4"`, 0, false, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/api/students/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+			version, ok := ifMatchVersion(w, r)
+			if version != tt.version || ok != tt.ok {
+				t.Errorf("ifMatchVersion(%q) = %d, %v, want %d, %v", tt.header, version, ok, tt.version, tt.ok)
+			}
+			// a refused header is answered, an accepted one leaves the response to the handler
+			if tt.ok && w.Body.Len() > 0 {
+				t.Errorf("accepted header wrote a response: %d %s", w.Code, w.Body)
+			}
+			if !tt.ok && w.Code != tt.status {
+				t.Errorf("status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestUpdateVersionConflict(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser(t, "registrar@example.com", "s3cret-pass", RoleRegistrar)
+	token := s.login(t, "registrar@example.com", "s3cret-pass").AccessToken
+
+	w := s.do(t, "POST", "/api/students", token, Student{Name: "Ada", Age: 20, Email: "ada@gmail.com", Dept: "CS"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	path := "/api/students/" + strconv.Itoa(decode[Student](t, w).Id)
+	etag := s.do(t, "GET", path, token, nil).Header().Get("ETag")
+
+	// two clients read the same version, the second write is refused
+	first := Student{Name: "Ada", Age: 20, Email: "ada@gmail.com", Dept: "Maths"}
+	if w := s.do(t, "PUT", path, token, first, "If-Match", etag); w.Code != http.StatusOK {
+		t.Fatalf("first update: status %d: %s", w.Code, w.Body)
+	}
+	second := Student{Name: "Ada", Age: 20, Email: "ada@gmail.com", Dept: "Physics"}
+	if w := s.do(t, "PUT", path, token, second, "If-Match", etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("second update: status %d, want 412", w.Code)
+	}
+	if w := s.do(t, "PUT", path, token, second, "If-Match", `"99"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("update with a future version: status %d, want 412", w.Code)
+	}
+
+	w = s.do(t, "GET", path, token, nil)
+	if got := decode[Student](t, w); got.Dept != "Maths" {
+		t.Errorf("dept = %q, want the first update", got.Dept)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("ETag did not change after the update")
+	}
+}
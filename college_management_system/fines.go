@@ -0,0 +1,347 @@
+package collegemanagementsystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Every loan gets a due date and a fine rate from its loan policy when it is borrowed.
+// A copy returned after its due date is charged a fine per day late, which is paid or
+// waived at the desk. Amounts are in cents.
+
+// DefaultFineLimit is the unpaid total above which borrowing is blocked, in cents
+const DefaultFineLimit = 1000
+
+// Kinds of fine transactions
+const (
+	FinePayment = "payment"
+	FineWaiver  = "waiver"
+)
+
+// LoanSettings holds the loan settings that are not part of a loan policy
+type LoanSettings struct {
+	// FineLimit blocks borrowing while a user owes more, in cents
+	FineLimit int
+	// PickupWindow is how long a ready hold keeps its copy
+	PickupWindow time.Duration
+}
+
+// LoanSettingsFromEnv reads FINE_LIMIT and HOLD_PICKUP_WINDOW, falling back to the defaults
+func LoanSettingsFromEnv() LoanSettings {
+	settings := LoanSettings{FineLimit: DefaultFineLimit, PickupWindow: DefaultPickupWindow}
+	if v := os.Getenv("FINE_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Printf("invalid FINE_LIMIT %q, using %d\n", v, DefaultFineLimit)
+		} else {
+			settings.FineLimit = n
+		}
+	}
+	if v := os.Getenv("HOLD_PICKUP_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Printf("invalid HOLD_PICKUP_WINDOW %q, using %s\n", v, DefaultPickupWindow)
+		} else {
+			settings.PickupWindow = d
+		}
+	}
+	return settings
+}
+
+// dueDate is the day a loan starting at borrowed must be returned by
+func dueDate(borrowed time.Time, days int) time.Time {
+	y, m, d := borrowed.Date()
+	return time.Date(y, m, d+days, 0, 0, 0, 0, time.Local)
+}
+
+// daysLate counts the days from the due day to the day of at, 0 when at is not past due
+func daysLate(due, at time.Time) int {
+	y, m, d := due.Date()
+	dueDay := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	y, m, d = at.Date()
+	atDay := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return max(0, int(atDay.Sub(dueDay).Hours()/24))
+}
+
+// Fine is charged for a late return
+type Fine struct {
+	FineID    int    `json:"fine_id"`
+	BorrowID  int    `json:"borrow_id"`
+	UserID    int    `json:"user_id"`
+	UserType  string `json:"user_type"`
+	BookID    int    `json:"book_id"`
+	DaysLate  int    `json:"days_late"`
+	Amount    int    `json:"amount"`
+	Paid      int    `json:"paid"`
+	Waived    int    `json:"waived"`
+	Balance   int    `json:"balance"`
+	CreatedAt string `json:"created_at"`
+
+	// Transactions are the payments and waivers, only returned for a single fine
+	Transactions []FineTransaction `json:"transactions,omitempty"`
+}
+
+// FineTransaction is a payment or waiver recorded against a fine
+type FineTransaction struct {
+	TransactionID int    `json:"transaction_id"`
+	FineID        int    `json:"fine_id"`
+	Kind          string `json:"kind"`
+	Amount        int    `json:"amount"`
+	Note          string `json:"note"`
+	RecordedBy    string `json:"recorded_by"`
+	RecordedAt    string `json:"recorded_at"`
+}
+
+// FineFilter selects fines, zero fields match everything
+type FineFilter struct {
+	UserType string
+	UserID   int
+	Unpaid   bool
+}
+
+// FineSettlement is the body of a payment or waiver
+type FineSettlement struct {
+	// Amount in cents, 0 or omitted settles the whole balance
+	Amount int    `json:"amount"`
+	Note   string `json:"note"`
+}
+
+// OverdueLoan is an open loan past its due date with the fine it has accrued so far
+type OverdueLoan struct {
+	BorrowInfo
+	DaysLate    int `json:"days_late"`
+	AccruedFine int `json:"accrued_fine"`
+}
+
+// GetOverdueHandler godoc
+// @Summary List overdue loans
+// @Description Open loans past their due date with the days late and the fine accrued so far, oldest due date first
+// @Tags Borrow
+// @Security BearerAuth
+// @Produce json
+// @Param user_type query string false "student or lecturer"
+// @Param user_id query int false "Only loans of this user, with user_type"
+// @Success 200 {array} OverdueLoan
+// @Failure 400 {object} map[string]string
+// @Router /api/borrow/overdue [get]
+// GetOverdueHandler lists the loans that should have been returned
+func (h *HybridHandler) GetOverdueHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Read filters from the query string
+	f, err := parseFineFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	loans, err := h.Borrows.Overdue(r.Context(), time.Now(), f.UserType, f.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if loans == nil {
+		loans = []OverdueLoan{}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loans)
+}
+
+// parseFineFilter reads user_type, user_id and unpaid from the query string
+func parseFineFilter(r *http.Request) (FineFilter, error) {
+	q := r.URL.Query()
+	f := FineFilter{UserType: q.Get("user_type")}
+	if f.UserType != "" && f.UserType != "student" && f.UserType != "lecturer" {
+		return f, fmt.Errorf("invalid user_type, must be 'student' or 'lecturer'")
+	}
+	if v := q.Get("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return f, fmt.Errorf("user_id must be a positive number")
+		}
+		if f.UserType == "" {
+			return f, fmt.Errorf("user_id needs a user_type")
+		}
+		f.UserID = id
+	}
+	if v := q.Get("unpaid"); v != "" {
+		unpaid, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("unpaid must be true or false")
+		}
+		f.Unpaid = unpaid
+	}
+	return f, nil
+}
+
+// GetFinesHandler godoc
+// @Summary List fines
+// @Description Fines charged for late returns, newest first
+// @Tags Fines
+// @Security BearerAuth
+// @Produce json
+// @Param user_type query string false "student or lecturer"
+// @Param user_id query int false "Only fines of this user, with user_type"
+// @Param unpaid query bool false "true for fines with a balance"
+// @Success 200 {array} Fine
+// @Failure 400 {object} map[string]string
+// @Router /api/fines [get]
+// GetFinesHandler lists the fines
+func (h *HybridHandler) GetFinesHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Read filters from the query string
+	f, err := parseFineFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fines, err := h.Fines.List(r.Context(), f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if fines == nil {
+		fines = []Fine{}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fines)
+}
+
+// GetFineHandler godoc
+// @Summary Get a fine
+// @Description A fine with its payments and waivers
+// @Tags Fines
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Fine ID"
+// @Success 200 {object} Fine
+// @Failure 404 {object} map[string]string
+// @Router /api/fines/{id} [get]
+// GetFineHandler returns one fine by id
+func (h *HybridHandler) GetFineHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	fine, err := h.Fines.Get(r.Context(), idInt)
+	if err == ErrNotFound {
+		http.Error(w, "fine not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fine)
+}
+
+// PayFineHandler godoc
+// @Summary Pay a fine
+// @Description Record a payment in cents, without an amount the whole balance is paid
+// @Tags Fines
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Fine ID"
+// @Param payment body FineSettlement false "Amount and note"
+// @Success 200 {object} Fine
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/fines/{id}/pay [post]
+// PayFineHandler records a payment of a fine
+func (h *HybridHandler) PayFineHandler(w http.ResponseWriter, r *http.Request) {
+	h.settleFine(w, r, FinePayment)
+}
+
+// WaiveFineHandler godoc
+// @Summary Waive a fine
+// @Description Waive an amount in cents, without an amount the whole balance is waived
+// @Tags Fines
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Fine ID"
+// @Param waiver body FineSettlement false "Amount and note"
+// @Success 200 {object} Fine
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/fines/{id}/waive [post]
+// WaiveFineHandler records a waiver of a fine
+func (h *HybridHandler) WaiveFineHandler(w http.ResponseWriter, r *http.Request) {
+	h.settleFine(w, r, FineWaiver)
+}
+
+// settleFine records a payment or waiver from the request body
+func (h *HybridHandler) settleFine(w http.ResponseWriter, r *http.Request, kind string) {
+
+	// Extract id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	// Decode request body, an empty body settles the balance
+	var body FineSettlement
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	if body.Amount < 0 {
+		http.Error(w, "amount must not be negative", http.StatusBadRequest)
+		return
+	}
+	if len(body.Note) > 255 {
+		http.Error(w, "note must be at most 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	t := FineTransaction{FineID: idInt, Kind: kind, Amount: body.Amount, Note: strings.TrimSpace(body.Note), RecordedBy: Actor(r)}
+	fine, err := h.Fines.Settle(r.Context(), &t)
+	switch err {
+	case nil:
+	case ErrNotFound:
+		http.Error(w, "fine not found", http.StatusNotFound)
+		return
+	case ErrFineSettled:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"err": "fine is already settled"})
+		return
+	case ErrExceedsBalance:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"err": "amount exceeds the balance of the fine", "balance": fine.Balance})
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Log payment and waiver actions
+	action := "PAY"
+	if kind == FineWaiver {
+		action = "WAIVE"
+	}
+	go LogActivity(action+"_FINE", Actor(r))
+	go AuditLog(action, "FINE", fine.FineID, Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fine)
+}
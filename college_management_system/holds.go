@@ -0,0 +1,321 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// A user can place a hold on a book with no available copies and joins its queue. Holds
+// are served first come, first served: a copy that becomes free is kept for the oldest
+// waiting hold, which is then ready for pickup until its window ends. A hold that is not
+// collected by then expires and the copy goes to the next in line.
+
+// Statuses of a hold, waiting and ready holds are open
+const (
+	HoldWaiting   = "waiting"
+	HoldReady     = "ready"
+	HoldCollected = "collected"
+	HoldExpired   = "expired"
+	HoldCancelled = "cancelled"
+)
+
+// Codes of the refused holds and borrows, sent as "code" with the error
+const (
+	RuleCopiesAvailable = "copies_available"
+	RuleHoldExists      = "hold_exists"
+	RuleCopyOnHold      = "copy_on_hold"
+)
+
+// DefaultPickupWindow is how long a ready hold is kept for its user
+const DefaultPickupWindow = 72 * time.Hour
+
+// holdSweepInterval is how often SweepHolds expires uncollected holds
+const holdSweepInterval = time.Minute
+
+// Hold is a place in the queue of a book
+type Hold struct {
+	HoldID   int    `json:"hold_id"`
+	BookID   int    `json:"book_id"`
+	UserID   int    `json:"user_id"`
+	UserType string `json:"user_type"`
+	Status   string `json:"status"`
+	// Position is 1 for the next waiting hold to be served, 0 once the hold is not waiting
+	Position int `json:"position"`
+	// Barcode of the copy kept for a ready hold
+	Barcode   string `json:"barcode,omitempty"`
+	PlacedAt  string `json:"placed_at"`
+	ReadyAt   string `json:"ready_at,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	ClosedAt  string `json:"closed_at,omitempty"`
+}
+
+// HoldFilter selects holds, zero fields match everything
+type HoldFilter struct {
+	BookID   int
+	UserType string
+	UserID   int
+	// Open limits the holds to waiting and ready ones
+	Open bool
+}
+
+// HoldRequest is the body of a new hold
+type HoldRequest struct {
+	UserID   int    `json:"user_id"`
+	UserType string `json:"user_type"`
+}
+
+// assignHolds keeps the free copies of a book for its queue and drops the cached book
+// when copies were put on hold. It returns the holds that became ready.
+func (h *HybridHandler) assignHolds(ctx context.Context, bookID int) []Hold {
+	ready, err := h.Holds.Assign(ctx, bookID, time.Now(), h.Loans.PickupWindow)
+	if err != nil {
+		log.Println("unable to assign holds of book", bookID, ":", err)
+		return nil
+	}
+	if len(ready) > 0 {
+		h.Caches.Books.Invalidate(ctx, bookID)
+	}
+	for _, hold := range ready {
+		go AuditLog("READY", "HOLD", hold.HoldID, "system")
+	}
+	return ready
+}
+
+// SweepHolds expires the uncollected holds and passes their copies on, every interval
+// until ctx is done. The server runs it in the background.
+func (h *HybridHandler) SweepHolds(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		books, err := h.Holds.Expire(ctx, time.Now())
+		if err != nil {
+			log.Println("unable to expire holds:", err)
+			continue
+		}
+		for _, bookID := range books {
+			h.assignHolds(ctx, bookID)
+		}
+	}
+}
+
+// PlaceHoldHandler godoc
+// @Summary Place a hold
+// @Description Put a student or lecturer in the queue of a book with no available copies. The user must be allowed to borrow the book by a loan policy. Refusals answer {"err", "code"}: no_loan_policy, reference_only (403), copies_available or hold_exists (409).
+// @Tags Holds
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Book ID"
+// @Param hold body HoldRequest true "user_id and user_type"
+// @Success 201 {object} Hold
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/libraries/{id}/holds [post]
+// PlaceHoldHandler adds a user to the queue of a book
+func (h *HybridHandler) PlaceHoldHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	bookID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	// Decode request body
+	var req HoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	// validate user type
+	if req.UserType != "student" && req.UserType != "lecturer" {
+		http.Error(w, "invalid user_type, must be 'student' or 'lecturer'", http.StatusBadRequest)
+		return
+	}
+	if req.UserID <= 0 {
+		http.Error(w, "user_id must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.Libraries.Get(r.Context(), bookID)
+	if err == ErrNotFound {
+		http.Error(w, "book not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// only books the user may borrow can be held
+	policy, err := h.LoanPolicies.Get(r.Context(), req.UserType, book.Category)
+	if err == ErrNotFound {
+		writeLoanRefusal(w, http.StatusForbidden, RuleNoPolicy, "no loan policy lets a "+req.UserType+" borrow "+book.Category+" books", nil)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policy.MaxLoans == 0 {
+		writeLoanRefusal(w, http.StatusForbidden, RuleReferenceOnly, book.Category+" books are for use in the library only", nil)
+		return
+	}
+	if book.Available_copies > 0 {
+		writeLoanRefusal(w, http.StatusConflict, RuleCopiesAvailable, "copies are available, borrow one instead",
+			map[string]any{"available_copies": book.Available_copies})
+		return
+	}
+
+	hold := Hold{BookID: bookID, UserID: req.UserID, UserType: req.UserType}
+	err = h.Holds.Place(r.Context(), &hold)
+	if err == ErrNotFound {
+		http.Error(w, "book not found", http.StatusNotFound)
+		return
+	}
+	if err == ErrBorrowerNotFound {
+		http.Error(w, req.UserType+" not found", http.StatusNotFound)
+		return
+	}
+	if err == ErrDuplicateHold {
+		writeLoanRefusal(w, http.StatusConflict, RuleHoldExists, "the user already has an open hold on this book", nil)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Log Activity and audit trails
+	go LogActivity("PLACE_HOLD", Actor(r))
+	go AuditLog("PLACE", "HOLD", hold.HoldID, Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hold)
+}
+
+// GetBookHoldsHandler godoc
+// @Summary Hold queue of a book
+// @Description The open holds of a book, oldest first, with the queue position of the waiting ones
+// @Tags Holds
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Book ID"
+// @Success 200 {array} Hold
+// @Failure 404 {object} map[string]string
+// @Router /api/libraries/{id}/holds [get]
+// GetBookHoldsHandler lists the queue of a book
+func (h *HybridHandler) GetBookHoldsHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	bookID, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	if _, err := h.Libraries.Get(r.Context(), bookID); err == ErrNotFound {
+		http.Error(w, "book not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	holds, err := h.Holds.List(r.Context(), HoldFilter{BookID: bookID, Open: true})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if holds == nil {
+		holds = []Hold{}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holds)
+}
+
+// GetHoldHandler godoc
+// @Summary Get a hold
+// @Description A hold with its status and queue position
+// @Tags Holds
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Hold ID"
+// @Success 200 {object} Hold
+// @Failure 404 {object} map[string]string
+// @Router /api/holds/{id} [get]
+// GetHoldHandler returns one hold by id
+func (h *HybridHandler) GetHoldHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	hold, err := h.Holds.Get(r.Context(), idInt)
+	if err == ErrNotFound {
+		http.Error(w, "hold not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hold)
+}
+
+// CancelHoldHandler godoc
+// @Summary Cancel a hold
+// @Description Take a waiting or ready hold out of the queue. The copy kept for a ready hold goes to the next in line.
+// @Tags Holds
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Hold ID"
+// @Success 200 {object} Hold
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/holds/{id} [delete]
+// CancelHoldHandler cancels a hold
+func (h *HybridHandler) CancelHoldHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	hold, err := h.Holds.Cancel(r.Context(), idInt, time.Now())
+	switch err {
+	case nil:
+	case ErrNotFound:
+		http.Error(w, "hold not found", http.StatusNotFound)
+		return
+	case ErrHoldClosed:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"err": "hold is already " + hold.Status})
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// a released copy goes to the next in line
+	h.Caches.Books.Invalidate(r.Context(), hold.BookID)
+	h.assignHolds(r.Context(), hold.BookID)
+
+	// Log Activity and audit trails
+	go LogActivity("CANCEL_HOLD", Actor(r))
+	go AuditLog("CANCEL", "HOLD", hold.HoldID, Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hold)
+}
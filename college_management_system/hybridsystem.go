@@ -2,7 +2,6 @@ package collegemanagementsystem
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
@@ -19,9 +18,10 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-// MySQLInstance  wraps a MySQL database connections
+// MySQLInstance  wraps the SQL database connection.
+// Despite the name it can be MySQL, PostgreSQL or SQLite, see ParseDSN.
 type MySQLInstance struct {
-	db *sql.DB
+	db *DB
 }
 
 // Redis Instance wraps a redis client.
@@ -30,17 +30,44 @@ type RedisInstance struct {
 }
 
 // HybridHandler aggregates MySQL , MongoDB , Redis instances along with a shared context.
+// Handlers reach the database only through the Repositories and Caches.
 type HybridHandler struct {
 	MySQL *MySQLInstance
 	Redis *RedisInstance
-	Ctx   context.Context
+	Repositories
+	Auth   AuthStore
+	Cache  Cache
+	Caches EntityCaches
+	Loans  LoanSettings
+	Mailer Mailer
+	Ctx    context.Context
 }
 
-// connectMySQL initilizes a MySQL connection using DSN from environment variables.
+// NewMemoryHandler returns a handler whose repositories, login state and cache are kept in memory,
+// for tests and demos.
+func NewMemoryHandler() *HybridHandler {
+	cache := NewMemoryCache()
+	return &HybridHandler{
+		Repositories: NewMemoryRepositories(),
+		Auth:         NewMemoryAuthStore(),
+		Cache:        cache,
+		Caches:       NewEntityCaches(cache, CacheTTLsFromEnv()),
+		Loans:        LoanSettingsFromEnv(),
+		Mailer:       &LogMailer{},
+		Ctx:          context.Background(),
+	}
+}
+
+// connectMySQL initilizes the database connection using DATABASE_URL (or the older MYSQL_DSN)
+// from environment variables. The DSN scheme selects the backend.
 func ConnectMySQL() (*MySQLInstance, error) {
-	db, err := sql.Open("mysql", os.Getenv("MYSQL_DSN"))
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = os.Getenv("MYSQL_DSN")
+	}
+	db, err := OpenDB(dsn)
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
 	return &MySQLInstance{db: db}, nil
 }
@@ -65,6 +92,18 @@ func AuditLog(action, entity string, id any, actor string) {
 	log.Printf("[AUDIT] action=%s entity=%s id=%v actor=%s time=%s\n", action, entity, id, actor, time.Now())
 }
 
+// Actor returns the email of the authenticated account set by JwtMiddleware,
+// "apikey:<key_id>" for machine clients, or "system" when the request is not authenticated.
+func Actor(r *http.Request) string {
+	if keyID := r.Header.Get("X-API-Key-ID"); keyID != "" {
+		return "apikey:" + keyID
+	}
+	if email := r.Header.Get("X-User-Email"); email != "" {
+		return email
+	}
+	return "system"
+}
+
 // @title College Management System API
 // @version 1.0
 // @description REST API for managing students, lecturers, library, and authentication.
@@ -82,18 +121,31 @@ func AuditLog(action, entity string, id any, actor string) {
 // @securityDefinitions.apikey BearerAuth
 // @in cookie
 // @name access_token
+
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
 // main function
 func CollegeManagementSystem() {
 
 	// Load environment variables from .env file
 	godotenv.Load()
 
-	// Ensures JWT Secret  is set
+	// Ensures JWT signing keys are set
+	// JWT_SECRET signs with HS256, JWT_KEY_DIR loads RS256/EdDSA keys and takes over signing
 	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		log.Fatal("JWT_SECRET is not set or empty")
+	keyDir := os.Getenv("JWT_KEY_DIR")
+	if secret == "" && keyDir == "" {
+		log.Fatal("JWT_SECRET or JWT_KEY_DIR must be set")
+	}
+	if secret != "" {
+		Keys.AddHMACKey([]byte(secret))
+	}
+	if keyDir != "" {
+		if err := Keys.LoadKeyDir(keyDir, os.Getenv("JWT_SIGNING_KID")); err != nil {
+			log.Fatal("unable to load JWT keys: ", err)
+		}
 	}
-	SecretKey = []byte(secret)
 
 	// Initilizes Redis
 	redisinstance, err := ConnectRedis()
@@ -107,49 +159,167 @@ func CollegeManagementSystem() {
 		panic(err)
 	}
 
+	// Apply pending migrations at startup when AUTO_MIGRATE=true
+	if os.Getenv("AUTO_MIGRATE") == "true" {
+		migrator, err := NewMigrator(mysqlinstance)
+		if err != nil {
+			log.Fatal("unable to load migrations: ", err)
+		}
+		if _, err := migrator.Up(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Create the first account from EMAIL and PASSWORD if there are no users yet
+	repositories := NewSQLRepositories(mysqlinstance)
+	if err := SeedAdminUser(repositories.Users); err != nil {
+		log.Println("unable to seed initial user:", err)
+	}
+
 	// Create handler with all DB instanmces
-	handler := &HybridHandler{Redis: redisinstance, MySQL: mysqlinstance, Ctx: context.Background()}
+	cache := &RedisCache{Client: redisinstance.Client}
+	handler := &HybridHandler{
+		Redis:        redisinstance,
+		MySQL:        mysqlinstance,
+		Repositories: repositories,
+		Auth:         &RedisAuthStore{Client: redisinstance.Client},
+		Cache:        cache,
+		Caches:       NewEntityCaches(cache, CacheTTLsFromEnv()),
+		Loans:        LoanSettingsFromEnv(),
+		Mailer:       NewMailer(),
+		Ctx:          context.Background(),
+	}
+
+	// Expire uncollected holds in the background
+	go handler.SweepHolds(handler.Ctx, holdSweepInterval)
 
 	// Setup HTTP routers
+	r := handler.Routes()
+
+	fmt.Println("Server running on port:8080")
+	http.ListenAndServe(":8080", r)
+}
+
+// Routes registers every route of the API on a new router
+func (h *HybridHandler) Routes() *mux.Router {
 	r := mux.NewRouter()
 
 	//
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
+	// Public keys for services verifying our tokens
+	r.HandleFunc("/.well-known/jwks.json", JWKSHandler).Methods("GET")
+
 	// Authentication routes
-	r.HandleFunc("/login", LoginHandler).Methods("POST")
-	r.HandleFunc("/refresh", RefreshHandler).Methods("POST")
-	r.HandleFunc("/logout", LogoutHandler).Methods("POST")
+	r.HandleFunc("/login", h.LoginHandler).Methods("POST")
+	r.HandleFunc("/login/mfa", h.LoginMFAHandler).Methods("POST")
+	r.HandleFunc("/refresh", h.RefreshHandler).Methods("POST")
+	r.HandleFunc("/logout", h.LogoutHandler).Methods("POST")
+
+	// Password reset and email verification links
+	r.HandleFunc("/password/forgot", h.ForgotPasswordHandler).Methods("POST")
+	r.HandleFunc("/password/reset", h.ResetPasswordHandler).Methods("POST")
+	r.HandleFunc("/verify-email", h.VerifyEmailHandler).Methods("GET")
 
-	// Protected route
+	// Protected route, every route must also be listed in RoutePermissions
 	api := r.PathPrefix("/api").Subrouter()
-	api.Use(JwtMiddleware)
+	api.Use(h.JwtMiddleware)
+	api.Use(AuthorizeMiddleware)
+
+	// Session routes
+	api.HandleFunc("/logout-all", h.LogoutAllHandler).Methods("POST")
+
+	// User account routes
+	api.HandleFunc("/users", h.RegisterUserHandler).Methods("POST")
+	api.HandleFunc("/users", h.GetUsersHandler).Methods("GET")
+	api.HandleFunc("/users/{id}/disable", h.DisableUserHandler).Methods("POST")
+	api.HandleFunc("/users/{id}/password", h.ResetUserPasswordHandler).Methods("POST")
+	api.HandleFunc("/users/{id}/role", h.SetUserRoleHandler).Methods("PUT")
+	api.HandleFunc("/users/{id}/unlock", h.UnlockUserHandler).Methods("POST")
+	api.HandleFunc("/users/{id}/mfa", h.ResetMFAHandler).Methods("DELETE")
+	api.HandleFunc("/users/{id}/link", h.LinkUserHandler).Methods("PUT")
+
+	// API keys
+	api.HandleFunc("/apikeys", h.CreateAPIKeyHandler).Methods("POST")
+	api.HandleFunc("/apikeys", h.GetAPIKeysHandler).Methods("GET")
+	api.HandleFunc("/apikeys/{id}", h.RevokeAPIKeyHandler).Methods("DELETE")
+
+	// Self-service routes
+	api.HandleFunc("/me", h.GetMeHandler).Methods("GET")
+	api.HandleFunc("/me", h.UpdateMeHandler).Methods("PUT")
+	api.HandleFunc("/me/borrowed", h.GetMyBorrowedHandler).Methods("GET")
+	api.HandleFunc("/me/courses", h.GetMyCoursesHandler).Methods("GET")
+	api.HandleFunc("/me/fines", h.GetMyFinesHandler).Methods("GET")
+	api.HandleFunc("/me/holds", h.GetMyHoldsHandler).Methods("GET")
+
+	// Two-factor authentication routes
+	api.HandleFunc("/mfa/enroll", h.EnrollMFAHandler).Methods("POST")
+	api.HandleFunc("/mfa/activate", h.ActivateMFAHandler).Methods("POST")
 
 	// Student CRUD routes
-	api.HandleFunc("/students", handler.CreateStudentHandler).Methods("POST")
-	api.HandleFunc("/students", handler.GetStudentHandler).Methods("GET")
-	api.HandleFunc("/students/{id}", handler.GetstudentByIDHandler).Methods("GET")
-	api.HandleFunc("/students/{id}", handler.UpdateStudentHandler).Methods("PUT")
-	api.HandleFunc("/students/{id}", handler.DeleteStudentHandler).Methods("DELETE")
+	api.HandleFunc("/students", h.CreateStudentHandler).Methods("POST")
+	api.HandleFunc("/students", h.GetStudentHandler).Methods("GET")
+	api.HandleFunc("/students/{id}", h.GetstudentByIDHandler).Methods("GET")
+	api.HandleFunc("/students/{id}", h.UpdateStudentHandler).Methods("PUT")
+	api.HandleFunc("/students/{id}", h.PatchStudentHandler).Methods("PATCH")
+	api.HandleFunc("/students/{id}", h.DeleteStudentHandler).Methods("DELETE")
+	api.HandleFunc("/students/{id}/verify-email", h.SendStudentVerificationHandler).Methods("POST")
 
 	// Lecturer CRUD routes
-	api.HandleFunc("/lecturers", handler.CreateLecturerHandler).Methods("POST")
-	api.HandleFunc("/lecturers", handler.GetLecturerHandler).Methods("GET")
-	api.HandleFunc("/lecturers/{id}", handler.GetLecturerByIDHandler).Methods("GET")
-	api.HandleFunc("/lecturers/{id}", handler.UpdateLecturerHandler).Methods("PUT")
-	api.HandleFunc("/lecturers/{id}", handler.DeleteLecturerHandler).Methods("DELETE")
+	api.HandleFunc("/lecturers", h.CreateLecturerHandler).Methods("POST")
+	api.HandleFunc("/lecturers", h.GetLecturerHandler).Methods("GET")
+	api.HandleFunc("/lecturers/{id}", h.GetLecturerByIDHandler).Methods("GET")
+	api.HandleFunc("/lecturers/{id}", h.UpdateLecturerHandler).Methods("PUT")
+	api.HandleFunc("/lecturers/{id}", h.PatchLecturerHandler).Methods("PATCH")
+	api.HandleFunc("/lecturers/{id}", h.DeleteLecturerHandler).Methods("DELETE")
+	api.HandleFunc("/lecturers/{id}/verify-email", h.SendLecturerVerificationHandler).Methods("POST")
 
 	// Library routes
-	api.HandleFunc("/libraries", handler.CreateLibraryHandler).Methods("POST")
-	api.HandleFunc("/libraries/{id}", handler.GetLibraryByIDHandler).Methods("GEt")
-	api.HandleFunc("/libraries/{id}", handler.UpdateLibraryHandler).Methods("PUT")
-	api.HandleFunc("/libraries/{id}", handler.DeleteLibraryHandler).Methods("DELETE")
+	api.HandleFunc("/libraries", h.CreateLibraryHandler).Methods("POST")
+	api.HandleFunc("/libraries/{id}", h.GetLibraryByIDHandler).Methods("GEt")
+	api.HandleFunc("/libraries/{id}", h.UpdateLibraryHandler).Methods("PUT")
+	api.HandleFunc("/libraries/{id}", h.PatchLibraryHandler).Methods("PATCH")
+	api.HandleFunc("/libraries/{id}", h.DeleteLibraryHandler).Methods("DELETE")
+
+	// Book copies routes
+	api.HandleFunc("/libraries/{id}/copies", h.CreateCopyHandler).Methods("POST")
+	api.HandleFunc("/libraries/{id}/copies", h.GetCopiesHandler).Methods("GET")
+	api.HandleFunc("/copies/{barcode}", h.GetCopyHandler).Methods("GET")
+	api.HandleFunc("/copies/{barcode}", h.PatchCopyHandler).Methods("PATCH")
+
+	// Hold queues
+	api.HandleFunc("/libraries/{id}/holds", h.PlaceHoldHandler).Methods("POST")
+	api.HandleFunc("/libraries/{id}/holds", h.GetBookHoldsHandler).Methods("GET")
+	api.HandleFunc("/holds/{id}", h.GetHoldHandler).Methods("GET")
+	api.HandleFunc("/holds/{id}", h.CancelHoldHandler).Methods("DELETE")
 
 	// Borrow_records routes
-	api.HandleFunc("/borrow", handler.BorrowRecordsHandler).Methods("POST")
-	api.HandleFunc("/borrow", handler.GetBorrowRecordsHandler).Methods("GET")
-	api.HandleFunc("/return", handler.ReturnRecordsHandler).Methods("POST")
+	api.HandleFunc("/borrow", h.BorrowRecordsHandler).Methods("POST")
+	api.HandleFunc("/borrow", h.GetBorrowRecordsHandler).Methods("GET")
+	api.HandleFunc("/borrow/overdue", h.GetOverdueHandler).Methods("GET")
+	api.HandleFunc("/borrow/{borrow_id}/renew", h.RenewBorrowHandler).Methods("POST")
+	api.HandleFunc("/borrow/{borrow_id}/renewals", h.GetRenewalsHandler).Methods("GET")
+	api.HandleFunc("/return", h.ReturnRecordsHandler).Methods("POST")
 
-	fmt.Println("Server running on port:8080")
-	http.ListenAndServe(":8080", r)
+	// Loan policy routes
+	api.HandleFunc("/loan-policies", h.GetLoanPoliciesHandler).Methods("GET")
+	api.HandleFunc("/loan-policies/{user_type}/{category}", h.PutLoanPolicyHandler).Methods("PUT")
+	api.HandleFunc("/loan-policies/{user_type}/{category}", h.DeleteLoanPolicyHandler).Methods("DELETE")
+
+	// Fines routes
+	api.HandleFunc("/fines", h.GetFinesHandler).Methods("GET")
+	api.HandleFunc("/fines/{id}", h.GetFineHandler).Methods("GET")
+	api.HandleFunc("/fines/{id}/pay", h.PayFineHandler).Methods("POST")
+	api.HandleFunc("/fines/{id}/waive", h.WaiveFineHandler).Methods("POST")
+
+	// Search route
+	api.HandleFunc("/search", h.SearchHandler).Methods("GET")
+
+	// Cache administration routes
+	api.HandleFunc("/cache", h.GetCacheStatsHandler).Methods("GET")
+	api.HandleFunc("/cache/{entity}/{id}", h.InspectCacheHandler).Methods("GET")
+	api.HandleFunc("/cache/{entity}", h.FlushCacheHandler).Methods("DELETE")
+	api.HandleFunc("/cache/{entity}/warm", h.WarmCacheHandler).Methods("POST")
+
+	return r
 }
@@ -0,0 +1,279 @@
+package collegemanagementsystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	Keys.AddHMACKey([]byte("test-secret"))
+	os.Exit(m.Run())
+}
+
+// testServer is a NewMemoryHandler with its routes
+type testServer struct {
+	*HybridHandler
+	routes http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	h := NewMemoryHandler()
+	return &testServer{HybridHandler: h, routes: h.Routes()}
+}
+
+// createUser adds an account with the password and role
+func (s *testServer) createUser(t *testing.T, email, password, role string) User {
+	t.Helper()
+	hash, err := HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := User{Email: email, Role: role, PasswordHash: hash}
+	if err := s.Users.Create(context.Background(), &u); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// do sends body as JSON with token as the Bearer token and returns the recorded response,
+// header holds extra request headers as name/value pairs
+func (s *testServer) do(t *testing.T, method, path, token string, body any, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, path, &buf)
+	r.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.routes.ServeHTTP(w, r)
+	return w
+}
+
+// login logs in with the tokens in the body and fails the test unless it succeeds
+func (s *testServer) login(t *testing.T, email, password string) TokenResponse {
+	t.Helper()
+	w := s.do(t, "POST", "/login?token_in_body=true", "", Credentials{Email: email, Password: password})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body)
+	}
+	return decode[TokenResponse](t, w)
+}
+
+// decode reads the JSON response body into a T
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(w.Body).Decode(&v); err != nil {
+		t.Fatalf("decode %q: %v", w.Body, err)
+	}
+	return v
+}
+
+func TestLoginHandler(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser(t, "admin@example.com", "s3cret-pass", RoleAdmin)
+	disabled := s.createUser(t, "gone@example.com", "s3cret-pass", RoleStudent)
+	if err := s.Users.Disable(context.Background(), disabled.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		email, password string
+		want            int
+	}{
+		{"valid", "admin@example.com", "s3cret-pass", http.StatusOK},
+		{"wrong password", "admin@example.com", "wrong", http.StatusUnauthorized},
+		{"unknown email", "nobody@example.com", "s3cret-pass", http.StatusUnauthorized},
+		{"disabled", "gone@example.com", "s3cret-pass", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(t, "POST", "/login?token_in_body=true", "", Credentials{Email: tt.email, Password: tt.password})
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			tokens := decode[TokenResponse](t, w)
+			if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+				t.Fatalf("missing tokens: %+v", tokens)
+			}
+			if w := s.do(t, "GET", "/api/students", tokens.AccessToken, nil); w.Code != http.StatusOK {
+				t.Errorf("GET /api/students with the access token: status %d", w.Code)
+			}
+		})
+	}
+
+	if w := s.do(t, "GET", "/api/students", "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/students without a token: status %d, want 401", w.Code)
+	}
+}
+
+func TestRefreshHandler(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser(t, "admin@example.com", "s3cret-pass", RoleAdmin)
+	tokens := s.login(t, "admin@example.com", "s3cret-pass")
+
+	w := s.do(t, "POST", "/refresh?token_in_body=true", tokens.RefreshToken, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d: %s", w.Code, w.Body)
+	}
+	rotated := decode[TokenResponse](t, w)
+	if rotated.RefreshToken == "" || rotated.RefreshToken == tokens.RefreshToken {
+		t.Fatalf("refresh token was not rotated")
+	}
+	if w := s.do(t, "GET", "/api/students", rotated.AccessToken, nil); w.Code != http.StatusOK {
+		t.Errorf("GET /api/students with the new access token: status %d", w.Code)
+	}
+
+	// replaying the used token revokes the session, the rotated token stops working too
+	if w := s.do(t, "POST", "/refresh", tokens.RefreshToken, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("replayed refresh: status %d, want 401", w.Code)
+	}
+	if w := s.do(t, "POST", "/refresh", rotated.RefreshToken, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after reuse: status %d, want 401", w.Code)
+	}
+	if w := s.do(t, "POST", "/refresh", tokens.AccessToken, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh with an access token: status %d, want 401", w.Code)
+	}
+}
+
+func TestStudentCRUD(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser(t, "admin@example.com", "s3cret-pass", RoleAdmin)
+	token := s.login(t, "admin@example.com", "s3cret-pass").AccessToken
+
+	w := s.do(t, "POST", "/api/students", token, Student{Name: "Ada", Age: 20, Email: "ada@gmail.com", Dept: "CS"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	created := decode[Student](t, w)
+	path := "/api/students/" + strconv.Itoa(created.Id)
+
+	w = s.do(t, "GET", path, token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get: status %d: %s", w.Code, w.Body)
+	}
+	etag := w.Header().Get("ETag")
+	if got := decode[Student](t, w); got.Name != "Ada" || got.Email != "ada@gmail.com" {
+		t.Errorf("get = %+v", got)
+	}
+
+	update := Student{Name: "Ada Lovelace", Age: 21, Email: "ada@gmail.com", Dept: "Maths"}
+	if w := s.do(t, "PUT", path, token, update); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("update without If-Match: status %d, want 428", w.Code)
+	}
+	if w := s.do(t, "PUT", path, token, update, "If-Match", etag); w.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", w.Code, w.Body)
+	}
+	w = s.do(t, "GET", path, token, nil)
+	if got := decode[Student](t, w); got.Name != "Ada Lovelace" || got.Dept != "Maths" || got.Age != 21 {
+		t.Errorf("after update get = %+v", got)
+	}
+
+	if w := s.do(t, "DELETE", path, token, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", w.Code, w.Body)
+	}
+	if w := s.do(t, "GET", path, token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("get after delete: status %d, want 404", w.Code)
+	}
+	if w := s.do(t, "DELETE", path, token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("delete twice: status %d, want 404", w.Code)
+	}
+}
+
+func TestBorrowAndReturn(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser(t, "registrar@example.com", "s3cret-pass", RoleRegistrar)
+	s.createUser(t, "librarian@example.com", "s3cret-pass", RoleLibrarian)
+	registrar := s.login(t, "registrar@example.com", "s3cret-pass").AccessToken
+	token := s.login(t, "librarian@example.com", "s3cret-pass").AccessToken
+
+	w := s.do(t, "POST", "/api/students", registrar, Student{Name: "Ada", Age: 20, Email: "ada@gmail.com", Dept: "CS"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create student: status %d: %s", w.Code, w.Body)
+	}
+	student := decode[Student](t, w)
+	w = s.do(t, "POST", "/api/libraries", token, Library{Book_name: "Go", Title: "The Go Programming Language", Author: "Donovan"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create book: status %d: %s", w.Code, w.Body)
+	}
+	book := decode[Library](t, w)
+	if w := s.do(t, "POST", "/api/libraries/"+strconv.Itoa(book.Book_id)+"/copies", token, BookCopy{Barcode: "GO-1"}); w.Code != http.StatusCreated {
+		t.Fatalf("create copy: status %d: %s", w.Code, w.Body)
+	}
+
+	// available copies as the book route reports them
+	available := func() int {
+		t.Helper()
+		w := s.do(t, "GET", "/api/libraries/"+strconv.Itoa(book.Book_id), token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("get book: status %d: %s", w.Code, w.Body)
+		}
+		return decode[Library](t, w).Available_copies
+	}
+
+	borrow := Borrow_records{User_id: student.Id, User_type: "student", Barcode: "GO-1"}
+	w = s.do(t, "POST", "/api/borrow", token, borrow)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("borrow: status %d: %s", w.Code, w.Body)
+	}
+	borrowed := decode[struct{ Record Borrow_records }](t, w).Record
+	if borrowed.Book_id != book.Book_id || borrowed.Due_date == "" {
+		t.Errorf("borrowed record = %+v", borrowed)
+	}
+	if n := available(); n != 0 {
+		t.Errorf("available copies after borrow = %d, want 0", n)
+	}
+
+	tests := []struct {
+		name string
+		rec  Borrow_records
+		want int
+	}{
+		{"copy on loan", borrow, http.StatusBadRequest},
+		{"unknown barcode", Borrow_records{User_id: student.Id, User_type: "student", Barcode: "NOPE"}, http.StatusNotFound},
+		{"unknown student", Borrow_records{User_id: 999, User_type: "student", Barcode: "GO-1"}, http.StatusNotFound},
+		{"invalid user type", Borrow_records{User_id: student.Id, User_type: "staff", Barcode: "GO-1"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := s.do(t, "POST", "/api/borrow", token, tt.rec); w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	w = s.do(t, "POST", "/api/return", token, Borrow_records{Barcode: "GO-1"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("return: status %d: %s", w.Code, w.Body)
+	}
+	returned := decode[struct{ Record Borrow_records }](t, w).Record
+	if returned.Borrow_id != borrowed.Borrow_id || returned.Return_date == "" {
+		t.Errorf("returned record = %+v, want borrow %d with a return date", returned, borrowed.Borrow_id)
+	}
+	if n := available(); n != 1 {
+		t.Errorf("available copies after return = %d, want 1", n)
+	}
+	if w := s.do(t, "POST", "/api/return", token, Borrow_records{Barcode: "GO-1"}); w.Code != http.StatusNotFound {
+		t.Errorf("return twice: status %d, want 404", w.Code)
+	}
+}
@@ -4,20 +4,22 @@
 package collegemanagementsystem
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 // Claims represents the JWT payload.
-// It includes the user's email , token_type (access/refresh),
+// It includes the user's email , role , token_type (access/refresh/mfa_pending),
 // and standard registered clalims like expiration and issue time
 type Claims struct {
 	Email     string
+	Role      string
 	TokenType string
 	jwt.RegisteredClaims
 }
@@ -29,8 +31,19 @@ type Credentials struct {
 	Password string
 }
 
-// secret key is the key to sign JWT token
-var SecretKey []byte
+// TokenResponse is returned by login and refresh to non-browser clients
+// that ask for the tokens in the response body.
+type TokenResponse struct {
+	Message      string `json:"message"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// TokenMediaType is the Accept header value that asks for tokens in the response body,
+// the same as the query parameter ?token_in_body=true.
+const TokenMediaType = "application/vnd.cms.token+json"
 
 // access and refresh Token TTL(time to live)
 const (
@@ -38,32 +51,33 @@ const (
 	RefreshTokenTTL = 24 * 7 * time.Hour
 )
 
-// Generate access token creates a signed JWT access token for the given email.
-func GenerateAccessToken(email string) (string, error) {
+// Generate access token creates a signed JWT access token for the given email and role.
+func GenerateAccessToken(email, role string) (string, error) {
 	claims := &Claims{
 		Email:     email,
+		Role:      role,
 		TokenType: "access",
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(SecretKey)
+	return Keys.Sign(claims)
 }
 
-// Generate Refresh token creates a signed JWT refresh token for the given email
-func GenerateRefreshToken(email string) (string, error) {
+// Generate Refresh token creates a signed JWT refresh token for the given email.
+// jti identifies the token in Redis, use IssueRefreshToken to create tracked tokens.
+func GenerateRefreshToken(email, jti string) (string, error) {
 	claims := &Claims{
 		Email:     email,
 		TokenType: "refresh",
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(RefreshTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(SecretKey)
+	return Keys.Sign(claims)
 }
 
 // Set Access cookies sets the access token in an HTTP-only cookie.
@@ -103,14 +117,71 @@ func ClearAccessCookies(w http.ResponseWriter) {
 	})
 }
 
-// validation parses and validates a JWT string.
+// Clear refresh cookies removes the refresh token by setting its expiration in the past.
+func ClearRefreshCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh_token",
+		Value:    "",
+		HttpOnly: true,
+		Path:     "/",
+		Expires:  time.Now().Add(-time.Hour),
+	})
+}
+
+// TokenFromRequest returns the token sent by the client.
+// An "Authorization: Bearer <token>" header takes precedence over the cookie,
+// the cookie is only read when there is no Authorization header at all.
+func TokenFromRequest(r *http.Request, cookieName string) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return "", fmt.Errorf("malformed Authorization header")
+		}
+		return strings.TrimSpace(token), nil
+	}
+	cookie, err := r.Cookie(cookieName)
+	if err != nil {
+		return "", fmt.Errorf("%s missing", strings.ReplaceAll(cookieName, "_", " "))
+	}
+	return cookie.Value, nil
+}
+
+// WantsTokenBody reports whether the client asked for tokens in the JSON body,
+// either with ?token_in_body=true or an Accept header containing TokenMediaType.
+func WantsTokenBody(r *http.Request) bool {
+	if r.URL.Query().Get("token_in_body") == "true" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), TokenMediaType)
+}
+
+// writeTokens writes the login or refresh response, with the tokens in the body when asked for.
+func writeTokens(w http.ResponseWriter, r *http.Request, message, accessToken, refreshToken string) {
+	w.Header().Set("Content-Type", "application/json")
+	if WantsTokenBody(r) {
+		json.NewEncoder(w).Encode(TokenResponse{
+			Message:      message,
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			TokenType:    "Bearer",
+			ExpiresIn:    int(AccessTokenTTL.Seconds()),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// validation parses and validates a JWT string against every key of the key ring.
 // It returns the claims if the token is valid, otherwise an error.
 func Validation(tokenstr string) (*Claims, error) {
+	return parseToken(tokenstr)
+}
+
+// parseToken parses a JWT string with the given parser options.
+func parseToken(tokenstr string, opts ...jwt.ParserOption) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenstr, claims, func(t *jwt.Token) (interface{}, error) {
-		return SecretKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenstr, claims, Keys.Keyfunc, opts...)
 	if err != nil || !token.Valid {
 		return nil, fmt.Errorf("Token not valid")
 	}
@@ -119,81 +190,206 @@ func Validation(tokenstr string) (*Claims, error) {
 
 // LoginHandler godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT tokens in cookies
+// @Description Authenticate user against the users table and return JWT tokens in cookies.
+// @Description Accounts with two-factor authentication get {"mfa_required": true, "mfa_token": "..."} instead and finish at /login/mfa.
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param credentials body Credentials true "Login credentials"
-// @Success 200 {object} map[string]string
+// @Param token_in_body query bool false "Also return the tokens in the JSON body"
+// @Success 200 {object} TokenResponse
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Router /login [post]
 // Login handler handles user login requests.
 // It validates credentials , generate access and refresh token , sets them in cookies and returns a success message.
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+// Failed attempts are counted per account and IP, too many failures answer 429 with Retry-After.
+func (a *HybridHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var creds Credentials
 	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		http.Error(w, "Failed to decode response", http.StatusInternalServerError)
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
 		return
 	}
-	if creds.Email != os.Getenv("EMAIL") || creds.Password != os.Getenv("PASSWORD") {
-		http.Error(w, "invalid credentials", http.StatusInternalServerError)
+
+	// refuse attempts while the account or client IP is locked out
+	ip := ClientIP(r)
+	wait, err := a.LoginLockedFor(ip, creds.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeRetryAfter(w, wait)
 		return
 	}
 
-	accessToken, _ := GenerateAccessToken(creds.Email)
-	refreshToken, _ := GenerateRefreshToken(creds.Email)
+	// look up the account by email
+	user, err := a.Users.GetByEmail(r.Context(), creds.Email)
+	if err != nil && err != ErrNotFound {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hash := user.PasswordHash
+	if err == ErrNotFound {
+		hash = dummyPasswordHash
+	}
+	if !CheckPassword(hash, creds.Password) || err == ErrNotFound {
+		lockout, err := a.RecordLoginFailure(ip, creds.Email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if lockout > 0 {
+			writeRetryAfter(w, lockout)
+			return
+		}
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if user.Disabled {
+		http.Error(w, "account disabled", http.StatusForbidden)
+		return
+	}
+
+	// accounts with TOTP enabled continue at /login/mfa
+	if user.TOTPEnabled {
+		mfaToken, err := GenerateMFAPendingToken(creds.Email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"message": "two-factor code required", "mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
+
+	a.completeLogin(w, r, creds.Email, user.Role)
+}
+
+// completeLogin issues the access and refresh tokens once every login step passed.
+func (a *HybridHandler) completeLogin(w http.ResponseWriter, r *http.Request, email, role string) {
+
+	// successful login resets the account failure counter
+	if err := a.ClearLoginFailures(email, email); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, _ := GenerateAccessToken(email, role)
+
+	// start a new refresh token family for this session
+	refreshToken, err := a.IssueRefreshToken(email, "")
+	if err != nil {
+		http.Error(w, "unable to create session", http.StatusInternalServerError)
+		return
+	}
 
 	SetAccessCookies(w, accessToken)
 	SetRefreshCookies(w, refreshToken)
 
-	json.NewEncoder(w).Encode(map[string]string{"message": "login succesful!"})
+	go LogActivity("LOGIN", email)
+
+	writeTokens(w, r, "login succesful!", accessToken, refreshToken)
 }
 
 // RefreshHandler godoc
 // @Summary Refresh access token
-// @Description Generate new access token using refresh token
+// @Description Generate new access token using refresh token. The refresh token is rotated on every call,
+// @Description replaying an already used refresh token revokes the whole session.
+// @Description The refresh token is read from "Authorization: Bearer" or the refresh_token cookie.
 // @Tags Authentication
 // @Produce json
-// @Success 200 {object} map[string]string
+// @Param token_in_body query bool false "Also return the rotated refresh token in the JSON body"
+// @Success 200 {object} TokenResponse
 // @Failure 401 {object} map[string]string
 // @Router /refresh [post]
 // Refresh Handler handles requests to refresh the access token.
-func RefreshHandler(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("refresh_token")
+// The role is read again from the users table so role changes and disabled accounts apply on refresh.
+func (a *HybridHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	tokenstr, err := TokenFromRequest(r, "refresh_token")
 	if err != nil {
-		http.Error(w, "refresh token missing", http.StatusUnauthorized)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(cookie.Value, claims, func(t *jwt.Token) (interface{}, error) {
-		return SecretKey, nil
-	})
-	if err != nil || !token.Valid {
+	claims, err := parseRefreshToken(tokenstr, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// load the current role of the account
+	user, err := a.Users.GetByEmail(r.Context(), claims.Email)
+	if err == ErrNotFound || (err == nil && user.Disabled) {
+		http.Error(w, "account not found or disabled", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// rotate the refresh token, a replayed token revokes the session
+	NewRefreshToken, err := a.RotateRefreshToken(claims)
+	if err == ErrRefreshRevoked || err == ErrRefreshReused {
+		ClearAccessCookies(w)
+		ClearRefreshCookies(w)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
-	if claims.TokenType != "refresh" {
-		http.Error(w, "invalid token", http.StatusUnauthorized)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	NewAccessToken, _ := GenerateAccessToken(claims.Email)
+
+	NewAccessToken, _ := GenerateAccessToken(claims.Email, user.Role)
 
 	SetAccessCookies(w, NewAccessToken)
+	SetRefreshCookies(w, NewRefreshToken)
 
+	if WantsTokenBody(r) {
+		writeTokens(w, r, "new access token generated using refresh token", NewAccessToken, NewRefreshToken)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"message": "new access token generated using refresh token", "access_token": NewAccessToken})
 }
 
-// JWTMiddleware validates the access token from cookies
-func JwtMiddleware(next http.Handler) http.Handler {
+// JWTMiddleware validates the access token from the Authorization header or cookies.
+// "Authorization: Bearer <token>" takes precedence, the access_token cookie is used when the header is absent.
+// Machine clients send an API key in the X-API-Key header instead, see AuthenticateAPIKey.
+func (a *HybridHandler) JwtMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("access_token")
+		// identity headers are only ever set here, never trusted from the client
+		r.Header.Del("X-User-Email")
+		r.Header.Del("X-User-Role")
+		r.Header.Del("X-API-Key-ID")
+		r.Header.Del("X-API-Key-Scopes")
+
+		if raw := r.Header.Get("X-API-Key"); raw != "" {
+			id, keyID, scopes, err := a.AuthenticateAPIKey(r.Context(), raw)
+			if err != nil {
+				http.Error(w, "invalid or revoked api key", http.StatusUnauthorized)
+				return
+			}
+			r.Header.Set("X-API-Key-ID", keyID)
+			r.Header.Set("X-API-Key-Scopes", strings.Join(scopes, ","))
+
+			// track last use and record the call with the key as the actor
+			go a.APIKeys.Touch(context.Background(), id, time.Now())
+			go AuditLog(r.Method, "API_KEY_REQUEST", r.URL.Path, Actor(r))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tokenstr, err := TokenFromRequest(r, "access_token")
 		if err != nil {
-			http.Error(w, "access token missing", http.StatusUnauthorized)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
-		claims, err := Validation(cookie.Value)
+		claims, err := Validation(tokenstr)
 		if err != nil {
-			http.Error(w, "invalid or expired jwt cookie", http.StatusUnauthorized)
+			http.Error(w, "invalid or expired jwt", http.StatusUnauthorized)
 			return
 		}
 		if claims.TokenType != "access" {
@@ -201,21 +397,59 @@ func JwtMiddleware(next http.Handler) http.Handler {
 			return
 		}
 		r.Header.Set("X-User-Email", claims.Email)
+		r.Header.Set("X-User-Role", claims.Role)
 		next.ServeHTTP(w, r)
 	})
 }
 
 // LogoutHandler godoc
 // @Summary Logout user
-// @Description Logout and clear JWT cookies
+// @Description Logout, revoke the refresh token session and clear JWT cookies
 // @Tags Authentication
 // @Produce json
 // @Success 200 {object} map[string]string
 // @Router /logout [post]
 // Logout Handler handles user's logout requests.
-// It clears the access token cookie and returns a success message
-func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+// It revokes the refresh token family (from the Authorization header or cookie) server-side,
+// clears the token cookies and returns a success message
+func (a *HybridHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if tokenstr, err := TokenFromRequest(r, "refresh_token"); err == nil {
+		if claims, err := parseRefreshToken(tokenstr, true); err == nil {
+			if err := a.RevokeRefreshToken(claims); err != nil {
+				http.Error(w, "unable to revoke session", http.StatusInternalServerError)
+				return
+			}
+			go LogActivity("LOGOUT", claims.Email)
+		}
+	}
+
 	ClearAccessCookies(w)
+	ClearRefreshCookies(w)
 
 	json.NewEncoder(w).Encode(map[string]string{"message": "Logout succesful!"})
 }
+
+// LogoutAllHandler godoc
+// @Summary Logout all sessions
+// @Description Revoke every refresh token of the logged in account and clear JWT cookies
+// @Tags Authentication
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /api/logout-all [post]
+// LogoutAllHandler revokes all refresh token families of the current user
+func (a *HybridHandler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("X-User-Email")
+	if err := a.RevokeAllSessions(email); err != nil {
+		http.Error(w, "unable to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	go LogActivity("LOGOUT_ALL", email)
+	go AuditLog("LOGOUT_ALL", "USER", email, email)
+
+	ClearAccessCookies(w)
+	ClearRefreshCookies(w)
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "all sessions logged out"})
+}
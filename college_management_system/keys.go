@@ -0,0 +1,216 @@
+package collegemanagementsystem
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is one key of the key ring.
+// Private is nil for verification-only keys (retired keys kept during rotation).
+type SigningKey struct {
+	Kid     string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// KeyRing holds the key used to sign new tokens and every key accepted for validation.
+type KeyRing struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+}
+
+// Keys is the key ring used by GenerateAccessToken , GenerateRefreshToken and Validation
+var Keys = &KeyRing{keys: map[string]*SigningKey{}}
+
+// kid of the HS256 key built from JWT_SECRET
+const HMACKid = "hs256"
+
+// AddHMACKey adds the shared JWT_SECRET as an HS256 key.
+// Tokens without a kid header (issued before key rotation existed) are checked with this key.
+func (k *KeyRing) AddHMACKey(secret []byte) {
+	key := &SigningKey{Kid: HMACKid, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+	k.keys[HMACKid] = key
+	if k.signing == nil {
+		k.signing = key
+	}
+}
+
+// LoadKeyDir loads every *.pem file of dir into the key ring, the file name without extension is the kid.
+// Private keys (PKCS#8 RSA/Ed25519 or PKCS#1 RSA) can sign, public keys (PKIX) only validate.
+// The signing key is signingKid, or the last private key by name when signingKid is empty,
+// so naming key files by date makes the newest key active.
+func (k *KeyRing) LoadKeyDir(dir, signingKid string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	var lastPrivate *SigningKey
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := loadPEMKey(kid, file)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", file, err)
+		}
+		k.keys[kid] = key
+		if key.Private != nil {
+			lastPrivate = key
+		}
+	}
+
+	switch {
+	case signingKid != "":
+		key, ok := k.keys[signingKid]
+		if !ok || key.Private == nil {
+			return fmt.Errorf("signing key %q not found in %s", signingKid, dir)
+		}
+		k.signing = key
+	case lastPrivate != nil:
+		k.signing = lastPrivate
+	default:
+		return fmt.Errorf("no private key found in %s", dir)
+	}
+	return nil
+}
+
+// loadPEMKey parses one PEM encoded RSA or Ed25519 key
+func loadPEMKey(kid, file string) (*SigningKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodRS256, Private: key, Public: &key.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodRS256, Public: key}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodEdDSA, Private: key, Public: key.Public()}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{Kid: kid, Method: jwt.SigningMethodEdDSA, Public: key}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", parsed)
+}
+
+// Sign signs claims with the active signing key and sets the kid header.
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	if k.signing == nil {
+		return "", fmt.Errorf("no signing key configured")
+	}
+	token := jwt.NewWithClaims(k.signing.Method, claims)
+	token.Header["kid"] = k.signing.Kid
+	return token.SignedString(k.signing.Private)
+}
+
+// Keyfunc returns the validation key for a token based on its kid header.
+// The algorithm of the token must match the key, so an RSA public key can never be used as an HMAC secret.
+func (k *KeyRing) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		kid = HMACKid
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+// JWK is one JSON Web Key (RFC 7517) of the JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the JSON Web Key Set published at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the ring, HMAC keys are never published.
+func (k *KeyRing) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	kids := make([]string, 0, len(k.keys))
+	for kid := range k.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	b64 := base64.RawURLEncoding
+	for _, kid := range kids {
+		key := k.keys[kid]
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA", Kid: kid, Use: "sig", Alg: key.Method.Alg(),
+				N: b64.EncodeToString(pub.N.Bytes()),
+				E: b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP", Kid: kid, Use: "sig", Alg: key.Method.Alg(),
+				Crv: "Ed25519", X: b64.EncodeToString(pub),
+			})
+		}
+	}
+	return set
+}
+
+// JWKSHandler godoc
+// @Summary JSON Web Key Set
+// @Description Public keys used to verify access tokens, for other services
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} JWKS
+// @Router /.well-known/jwks.json [get]
+// JWKSHandler publishes the public keys of the key ring
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(Keys.JWKS())
+}
@@ -3,11 +3,9 @@ package collegemanagementsystem
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -20,38 +18,62 @@ type Lecturer struct {
 	Age         int    `json:"age"`
 	Email       string `json:"email"`
 	Designation string `json:"designation"`
+
+	// EmailVerified is set through the verification email and can't be written by clients
+	EmailVerified bool `json:"email_verified"`
+
+	// Version is bumped on every write and sent as the ETag, clients send it back in If-Match
+	Version int `json:"version"`
 }
 
 // validationLecturer validates incoming lecturer data
 func Validatelecturer(lecturer Lecturer) error {
+	return validateFields(lecturer, lecturerRules, nil)
+}
+
+// lecturerPatchFields are the fields a PATCH may change
+var lecturerPatchFields = []string{"name", "age", "email", "designation"}
 
+// lecturerRules validate a lecturer field by field, a PATCH only runs those of the changed fields
+var lecturerRules = []fieldRule[Lecturer]{
 	// validate name
-	if strings.TrimSpace(lecturer.Name) == "" {
-		return fmt.Errorf("name is invalid and empty")
-	}
+	{"name", func(lecturer Lecturer) error {
+		if strings.TrimSpace(lecturer.Name) == "" {
+			return fmt.Errorf("name is invalid and empty")
+		}
+		return nil
+	}},
 	// validate email
-	if lecturer.Email == "" {
-		return fmt.Errorf("email is invalid and empty")
-	}
-	if !strings.HasSuffix(lecturer.Email, "@gmail.com") {
-		return fmt.Errorf("email is invalid and does not contain @gmail.com")
-	}
-	prefix := strings.TrimSuffix(lecturer.Email, "@gmail.com")
-	if prefix == "" {
-		return fmt.Errorf("email must contains a prefix before the @gmail.com ")
-	}
+	{"email", func(lecturer Lecturer) error {
+		if lecturer.Email == "" {
+			return fmt.Errorf("email is invalid and empty")
+		}
+		if !strings.HasSuffix(lecturer.Email, "@gmail.com") {
+			return fmt.Errorf("email is invalid and does not contain @gmail.com")
+		}
+		prefix := strings.TrimSuffix(lecturer.Email, "@gmail.com")
+		if prefix == "" {
+			return fmt.Errorf("email must contains a prefix before the @gmail.com ")
+		}
+		return nil
+	}},
 	// validate age
-	if lecturer.Age <= 0 {
-		return fmt.Errorf("Invalid age , age is less than 0")
-	}
-	if lecturer.Age >= 100 {
-		return fmt.Errorf("Invalid age , age is grater than 100")
-	}
+	{"age", func(lecturer Lecturer) error {
+		if lecturer.Age <= 0 {
+			return fmt.Errorf("Invalid age , age is less than 0")
+		}
+		if lecturer.Age >= 100 {
+			return fmt.Errorf("Invalid age , age is grater than 100")
+		}
+		return nil
+	}},
 	// validate designation
-	if lecturer.Designation == "" {
-		return fmt.Errorf("Year is invalid, please enter a valid year")
-	}
-	return nil
+	{"designation", func(lecturer Lecturer) error {
+		if lecturer.Designation == "" {
+			return fmt.Errorf("Year is invalid, please enter a valid year")
+		}
+		return nil
+	}},
 }
 
 // CreateLecturerHandler godoc
@@ -73,6 +95,8 @@ func (h *HybridHandler) CreateLecturerHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	lecturers.EmailVerified = false
+
 	// Validate Requests payload
 	if err := Validatelecturer(lecturers); err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -81,24 +105,15 @@ func (h *HybridHandler) CreateLecturerHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Insert lecturer records into MySQL database
-	res, err := h.MySQL.db.Exec("INSERT INTO lecturers (name , email , age , designation) VALUES (? , ? , ? , ? )", lecturers.Name, lecturers.Email, lecturers.Age, lecturers.Designation)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// auto generated ID
-	id, err := res.LastInsertId()
-	if err != nil {
+	// Insert lecturer record, the repository sets the auto generated ID
+	if err := h.Lecturers.Create(r.Context(), &lecturers); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	lecturers.ID = int(id)
 
 	// Log activity and Audit trail
-	go LogActivity("CREATE_LECTURER", "system")
-	go AuditLog("CREATE", "LECTURER", lecturers.ID, "system")
+	go LogActivity("CREATE_LECTURER", Actor(r))
+	go AuditLog("CREATE", "LECTURER", lecturers.ID, Actor(r))
 
 	// Send succes response
 	w.Header().Set("Content-Type", "application/json")
@@ -108,37 +123,39 @@ func (h *HybridHandler) CreateLecturerHandler(w http.ResponseWriter, r *http.Req
 
 // GetLecturerHandler godoc
 // @Summary Get all lecturers
-// @Description Retrieve all lecturers
+// @Description Retrieve lecturers page by page, filtered by designation
 // @Tags Lecturers
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {array} Lecturer
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Skip this many rows, links then page by offset"
+// @Param cursor query string false "Cursor from a next/prev link"
+// @Param sort query string false "id, name, age, email or designation, prefix - for descending (default id)"
+// @Param designation query string false "Designation"
+// @Success 200 {object} Page[Lecturer]
+// @Failure 400 {object} map[string]string
 // @Router /api/lecturers [get]
 // GetLecturerHandler to get all lecturers
 func (a *HybridHandler) GetLecturerHandler(w http.ResponseWriter, r *http.Request) {
 
-	// Execute query to fetch lecturers record
-	rows, err := a.MySQL.db.Query("SELECT id , name , age , email , designation FROM lecturers")
+	// Read paging and filters from the query string
+	params, err := ParsePageParams(r.URL.Query(), lecturerSortFields, "id")
 	if err != nil {
-		http.Error(w, "unable to fetch lecturers", http.StatusInternalServerError)
+		writePageError(w, err)
 		return
 	}
-	defer rows.Close()
-
-	var lecturers []Lecturer
-	for rows.Next() {
-		var L Lecturer
-		if err := rows.Scan(&L.ID, &L.Name, &L.Age, &L.Email, &L.Designation); err != nil {
-			http.Error(w, "rows scan failed", http.StatusInternalServerError)
-			return
-		}
-		lecturers = append(lecturers, L)
+	filter := LecturerFilter{PageParams: params, Designation: r.URL.Query().Get("designation")}
 
+	// fetch lecturers record
+	lecturers, err := a.Lecturers.List(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "unable to fetch lecturers", http.StatusInternalServerError)
+		return
 	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(lecturers)
+	json.NewEncoder(w).Encode(NewPage(r, params, lecturers))
 }
 
 // GetLecturerByIDHandler godoc
@@ -147,7 +164,9 @@ func (a *HybridHandler) GetLecturerHandler(w http.ResponseWriter, r *http.Reques
 // @Security BearerAuth
 // @Produce json
 // @Param id path int true "Lecturer ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} Lecturer
+// @Success 304 "Not modified"
 // @Failure 404 {object} map[string]string
 // @Router /api/lecturers/{id} [get]
 // GetLecturerByIDHandler retrives a lecturer by id
@@ -158,40 +177,30 @@ func (h *HybridHandler) GetLecturerByIDHandler(w http.ResponseWriter, r *http.Re
 	id := vars["id"]
 
 	// LogActivity
-	go LogActivity("GET_LECTURER", "system")
-
-	// Attempt to fetch from redis cache first
-	value, err := h.Redis.Client.Get(h.Ctx, id).Result()
-	if err == nil {
-		log.Println("Cache hit!")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(value))
-		return
-	}
+	go LogActivity("GET_LECTURER", Actor(r))
 
-	// cache miss fetching from MySQL database
-	fmt.Println("Cache miss Quering MySQL ...")
-	row := h.MySQL.db.QueryRow("SELECT id , name , age , email  , designation FROM lecturers WHERE  id=?", id)
-
-	var lecturers Lecturer
-	if err := row.Scan(&lecturers.ID, &lecturers.Name, &lecturers.Age, &lecturers.Email, &lecturers.Designation); err != nil {
+	// Read through the cache, loading from the repository on a miss
+	idInt, _ := strconv.Atoi(id)
+	lecturers, err := h.Caches.Lecturers.Get(r.Context(), idInt, h.Lecturers.Get)
+	if err == ErrNotFound {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
 	}
-
-	// marshal lecturer data for caching
-	jsondata, err := json.Marshal(lecturers)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// store result in redis cache (TTL)
-	h.Redis.Client.Set(h.Ctx, id, jsondata, 10*time.Second)
+	// Conditional GET, 304 when the client has this version
+	etag := ETag(lecturers.Version)
+	if notModified(w, r, etag) {
+		return
+	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(jsondata)
+	w.Header().Set("ETag", etag)
+	json.NewEncoder(w).Encode(lecturers)
 }
 
 // UpdateLecturerHandler godoc
@@ -200,18 +209,33 @@ func (h *HybridHandler) GetLecturerByIDHandler(w http.ResponseWriter, r *http.Re
 // @Security BearerAuth
 // @Accept json
 // @Produce json
+// @Param id path int true "Lecturer ID"
+// @Param If-Match header string true "ETag of the record as read, e.g. \"3\""
 // @Param lecturer body Lecturer true "Updated Lecturer"
 // @Success 200 {object} Lecturer
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /api/lecturers/{id} [put]
 // updateLecturerHandler updates a exsisting lecturer
 func (h *HybridHandler) UpdateLecturerHandler(w http.ResponseWriter, r *http.Request) {
 
+	// Extract id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	// the client must send the ETag it read, so concurrent edits don't overwrite each other
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
 	// Decode request body
 	var lecturers Lecturer
 	if err := json.NewDecoder(r.Body).Decode(&lecturers); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	lecturers.ID, lecturers.Version = idInt, version
 
 	// validate updated data
 	if err := Validatelecturer(lecturers); err != nil {
@@ -220,41 +244,123 @@ func (h *HybridHandler) UpdateLecturerHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Execute Updated query
-	res, err := h.MySQL.db.Exec("UPDATE lecturers SET name=?,email=?,age=?,designation=? WHERE id=?", lecturers.Name, lecturers.Email, lecturers.Age, lecturers.Designation, lecturers.ID)
+	// Update the record, a changed email has to be verified again
+	err := h.Lecturers.Update(r.Context(), &lecturers)
+	if err == ErrNotFound {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Check if records exists
-	rows, err := res.RowsAffected()
+	// drop the cached copy, the next read loads the new record
+	h.Caches.Lecturers.Invalidate(r.Context(), lecturers.ID)
+
+	jsonData, err := json.Marshal(lecturers)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Log Update actions
+	go LogActivity("UPDATE_LECTURER", Actor(r))
+	go AuditLog("UPDATE", "LECTURER", lecturers.ID, Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", ETag(lecturers.Version))
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonData)
+}
+
+// PatchLecturerHandler godoc
+// @Summary Patch lecturer
+// @Description Partial update with a JSON merge patch (RFC 7396), e.g. {"designation": "HOD"}. Only the sent fields are changed and validated, null is refused.
+// @Tags Lecturers
+// @Security BearerAuth
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "Lecturer ID"
+// @Param If-Match header string false "ETag of the record as read, the patch fails with 412 if it changed since"
+// @Param patch body Lecturer true "Fields to change"
+// @Success 200 {object} Lecturer
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Router /api/lecturers/{id} [patch]
+// PatchLecturerHandler changes some fields of an existing lecturer
+func (h *HybridHandler) PatchLecturerHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	patch, version, ok := readMergePatch(w, r)
+	if !ok {
 		return
 	}
-	if rows == 0 {
+
+	// Load the current record, the patch applies to it
+	current, err := h.Lecturers.Get(r.Context(), idInt)
+	if err == ErrNotFound {
 		http.Error(w, "user not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if version != 0 && version != current.Version {
+		writeVersionConflict(w)
+		return
+	}
 
-	// Update redis cache
-	jsonData, err := json.Marshal(lecturers)
+	// Apply the patch and validate the changed fields only
+	lecturers, fields, err := applyMergePatch(current, patch, lecturerPatchFields)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writePatchError(w, err)
 		return
 	}
+	if err := validateFields(lecturers, lecturerRules, fields); err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	// update on the version the patch was applied to, a concurrent write fails with 412,
+	// a changed email has to be verified again
+	if len(fields) > 0 {
+		err = h.Lecturers.Update(r.Context(), &lecturers)
+		if err == ErrNotFound {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		if err == ErrVersionConflict {
+			writeVersionConflict(w)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	go h.Redis.Client.Set(h.Ctx, fmt.Sprint(lecturers.ID), jsonData, 10*time.Minute)
+		// drop the cached copy, the next read loads the new record
+		h.Caches.Lecturers.Invalidate(r.Context(), idInt)
 
-	// Log Update actions
-	go LogActivity("UPDATE_LECTURER", "system")
-	go AuditLog("UPDATE", "LECTURER", lecturers.ID, "system")
+		// Log patch actions
+		go LogActivity("PATCH_LECTURER", Actor(r))
+		go AuditLog("PATCH", "LECTURER", idInt, Actor(r))
+	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(jsonData)
+	w.Header().Set("ETag", ETag(lecturers.Version))
+	json.NewEncoder(w).Encode(lecturers)
 }
 
 // DeleteLecturerHandler godoc
@@ -275,30 +381,23 @@ func (h *HybridHandler) DeleteLecturerHandler(w http.ResponseWriter, r *http.Req
 	// convert id to integer
 	idInt, _ := strconv.Atoi(id)
 
-	// Execute delete query
-	res, err := h.MySQL.db.Exec("DELETE FROM lecturers WHERE id=?", idInt)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Delete the record, checking that the lecturer exists
+	err := h.Lecturers.Delete(r.Context(), idInt)
+	if err == ErrNotFound {
+		http.Error(w, "lecturer not found", http.StatusNotFound)
 		return
 	}
-
-	// Check id lecturer exists
-	rows, err := res.RowsAffected()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if rows == 0 {
-		http.Error(w, "lecturer not found", http.StatusNotFound)
-		return
-	}
 
 	// remove cache entry
-	h.Redis.Client.Del(h.Ctx, id)
+	h.Caches.Lecturers.Invalidate(r.Context(), idInt)
 
 	// Log delete response
-	go LogActivity("DELETE_LECTURER", "system")
-	go AuditLog("DELETE", "LECTURER", idInt, "system")
+	go LogActivity("DELETE_LECTURER", Actor(r))
+	go AuditLog("DELETE", "LECTURER", idInt, Actor(r))
 
 	// send response
 	w.Header().Set("Content-Type", "application/json")
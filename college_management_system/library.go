@@ -1,11 +1,10 @@
 package collegemanagementsystem
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -14,13 +13,18 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// library represents a library entity
+// library represents a library entity, Available_copies is derived from its copies.
+// Category (standard, short_loan or reference) selects the loan policy.
 type Library struct {
 	Book_id          int    `json:"book_id"`
 	Book_name        string `json:"book_name"`
 	Title            string `json:"title"`
 	Author           string `json:"author"`
+	Category         string `json:"category"`
 	Available_copies int    `json:"available_copies"`
+
+	// Version is bumped on every write and sent as the ETag, clients send it back in If-Match
+	Version int `json:"version"`
 }
 
 // borrow_records represents a borrowing transaction
@@ -31,6 +35,15 @@ type Borrow_records struct {
 	Book_id     int    `json:"book_id"`
 	Borrow_date string `json:"borrow_date"`
 	Return_date string `json:"return_date"`
+
+	// Barcode is the scanned copy, Copy_id and Book_id are looked up from it
+	Barcode string `json:"barcode"`
+	Copy_id int    `json:"copy_id"`
+
+	// Due_date and Fine_per_day (cents) come from the loan policy when the copy is borrowed,
+	// loans recorded before due dates have none
+	Due_date     string `json:"due_date"`
+	Fine_per_day int    `json:"fine_per_day"`
 }
 
 // Create struct to store one borrow record
@@ -42,35 +55,58 @@ type BorrowInfo struct {
 	BookType   string `json:"book_type"`
 	BorrowDate string `json:"borrow_date"`
 	ReturnDate string `json:"return_date"`
+	DueDate    string `json:"due_date"`
+	// Barcode is the borrowed copy, empty for loans recorded before copies were tracked
+	Barcode string `json:"barcode"`
+	// borrowedAt is the borrow date as read from the database, it is the cursor value for sort=borrow_date
+	borrowedAt time.Time
 }
 
 // validate library ensures that library input data is valid before DB operations
 func ValidateLibrary(library Library) error {
+	return validateFields(library, libraryRules, nil)
+}
+
+// libraryPatchFields are the fields a PATCH may change, available_copies follows the copies
+var libraryPatchFields = []string{"book_name", "title", "author", "category"}
 
+// libraryRules validate a book field by field, a PATCH only runs those of the changed fields
+var libraryRules = []fieldRule[Library]{
 	// validate book_name
-	if strings.TrimSpace(library.Book_name) == "" {
-		return fmt.Errorf("book_name is invalid and empty")
-	}
+	{"book_name", func(library Library) error {
+		if strings.TrimSpace(library.Book_name) == "" {
+			return fmt.Errorf("book_name is invalid and empty")
+		}
+		return nil
+	}},
 	// validate title
-	if strings.TrimSpace(library.Title) == "" {
-		return fmt.Errorf("title is invalid and empty")
-	}
+	{"title", func(library Library) error {
+		if strings.TrimSpace(library.Title) == "" {
+			return fmt.Errorf("title is invalid and empty")
+		}
+		return nil
+	}},
 	// validate author
-	if strings.TrimSpace(library.Author) == "" {
-		return fmt.Errorf("Author is invalid and empty")
-	}
-	// validate available_copies
-	if library.Available_copies <= 0 {
-		return fmt.Errorf("available_copies is less than 0")
-	}
-	return nil
+	{"author", func(library Library) error {
+		if strings.TrimSpace(library.Author) == "" {
+			return fmt.Errorf("Author is invalid and empty")
+		}
+		return nil
+	}},
+	// validate category
+	{"category", func(library Library) error {
+		if !slices.Contains(BookCategories, library.Category) {
+			return fmt.Errorf("category must be one of %s", strings.Join(BookCategories, ", "))
+		}
+		return nil
+	}},
 }
 
 // validateBorrowRecords ensures borrow record input is valid
 func ValidateBorrowRecords(BR Borrow_records) error {
-	// validate book_id
-	if BR.Book_id <= 0 {
-		return fmt.Errorf("invalid book_id")
+	// validate barcode
+	if strings.TrimSpace(BR.Barcode) == "" {
+		return fmt.Errorf("barcode of the copy is required")
 	}
 	// valkidate user_id
 	if BR.User_id <= 0 {
@@ -85,6 +121,7 @@ func ValidateBorrowRecords(BR Borrow_records) error {
 
 // CreateLibraryHandler godoc
 // @Summary Add library book
+// @Description The book starts without copies, add them with POST /api/libraries/{id}/copies. available_copies is ignored, category defaults to standard.
 // @Tags Library
 // @Security BearerAuth
 // @Accept json
@@ -102,6 +139,11 @@ func (h *HybridHandler) CreateLibraryHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// books are standard loans unless set otherwise
+	if libraries.Category == "" {
+		libraries.Category = CategoryStandard
+	}
+
 	// validate requests payload
 	if err := ValidateLibrary(libraries); err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -110,20 +152,11 @@ func (h *HybridHandler) CreateLibraryHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Insert library records into MySQL database
-	res, err := h.MySQL.db.Exec("INSERT INTO libraries (book_name , title , author , available_copies) VALUES ( ? , ? , ? , ?)", libraries.Book_name, libraries.Title, libraries.Author, libraries.Available_copies)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Auto generated id
-	id, err := res.LastInsertId()
-	if err != nil {
+	// Insert library record, the repository sets the auto generated id
+	if err := h.Libraries.Create(r.Context(), &libraries); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	libraries.Book_id = int(id)
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
@@ -137,7 +170,9 @@ func (h *HybridHandler) CreateLibraryHandler(w http.ResponseWriter, r *http.Requ
 // @Security BearerAuth
 // @Produce json
 // @Param id path int true "Library ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} Library
+// @Success 304 "Not modified"
 // @Failure 404 {object} map[string]string
 // @Router /api/libraries/{id} [get]
 // GetLibraryHandler retrives a library by id
@@ -148,49 +183,47 @@ func (h *HybridHandler) GetLibraryByIDHandler(w http.ResponseWriter, r *http.Req
 	id := vars["id"]
 
 	// Log activity
-	go LogActivity("GET_LIBRARY", "system")
+	go LogActivity("GET_LIBRARY", Actor(r))
 
-	// attempt to fetch from redis cache first
-	value, err := h.Redis.Client.Get(h.Ctx, id).Result()
-	if err == nil {
-		log.Println("Cache hit!")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(value))
+	// Read through the cache, loading from the repository on a miss
+	idInt, _ := strconv.Atoi(id)
+	libraries, err := h.Caches.Books.Get(r.Context(), idInt, h.Libraries.Get)
+	if err == ErrNotFound {
+		http.Error(w, "Book not found", http.StatusNotFound)
 		return
 	}
-
-	// cache miss querying MYSQL database
-	fmt.Println("Cache miss Quering MySQL ...")
-	row := h.MySQL.db.QueryRow("SELECT book_id ,book_name ,  title , author , available_copies FROM libraries WHERE  book_id=?", id)
-
-	var libraries Library
-	if err := row.Scan(&libraries.Book_id, &libraries.Book_name, &libraries.Title, &libraries.Author, &libraries.Available_copies); err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	//  marshal library data for caching
-	jsondata, err := json.Marshal(libraries)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Conditional GET, 304 when the client has this version
+	etag := ETag(libraries.Version)
+	if notModified(w, r, etag) {
 		return
 	}
-	go h.Redis.Client.Set(h.Ctx, id, jsondata, 10*time.Second)
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(jsondata)
+	w.Header().Set("ETag", etag)
+	json.NewEncoder(w).Encode(libraries)
 }
 
 // UpdateLibraryHandler godoc
 // @Summary Update library
+// @Description Replace a book, category included since it selects the loan policy. available_copies is derived from the copies and ignored here
 // @Tags Library
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param id path int true "Library ID"
+// @Param If-Match header string true "ETag of the record as read, e.g. \"3\""
 // @Param library body Library true "Updated Library"
 // @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /api/libraries/{id} [put]
 // UpdateLibraryHandler updates an existing library record by ID
 func (h *HybridHandler) UpdateLibraryHandler(w http.ResponseWriter, r *http.Request) {
@@ -201,6 +234,12 @@ func (h *HybridHandler) UpdateLibraryHandler(w http.ResponseWriter, r *http.Requ
 
 	IdINT, _ := strconv.Atoi(id)
 
+	// the client must send the ETag it read, so concurrent edits don't overwrite each other
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
 	// Decode incoming JSON requests body
 	var libraries Library
 	if err := json.NewDecoder(r.Body).Decode(&libraries); err != nil {
@@ -208,36 +247,127 @@ func (h *HybridHandler) UpdateLibraryHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// validate updated data
+	// validate updated data, a PUT replaces the book so the category must be sent,
+	// defaulting it would silently move the book to another loan policy
+	if libraries.Category == "" {
+		http.Error(w, "category is required, one of "+strings.Join(BookCategories, ", "), http.StatusBadRequest)
+		return
+	}
 	if err := ValidateLibrary(libraries); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// update MySQL record
-	_, err := h.MySQL.db.Exec("UPDATE libraries SET book_name=? , title=? , author=? , available_copies=? WHERE book_id=?", libraries.Book_name, libraries.Title, libraries.Author, libraries.Available_copies, IdINT)
+	// update the record
+	libraries.Book_id, libraries.Version = IdINT, version
+	err := h.Libraries.Update(r.Context(), &libraries)
+	if err == ErrNotFound {
+		http.Error(w, "Book not found", http.StatusNotFound)
+		return
+	}
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	libraries.Book_id, _ = strconv.Atoi(id)
 
-	// refresh redis cache
-	jsonData, err := json.Marshal(libraries)
+	// drop the cached copy, the next read loads the new record
+	h.Caches.Books.Invalidate(r.Context(), IdINT)
+
+	// Log update actions
+	go LogActivity("UPDATE_BOOK", Actor(r))
+	go AuditLog("UPDATE", "BOOK", libraries.Book_id, Actor(r))
+
+	// send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", ETag(libraries.Version))
+	json.NewEncoder(w).Encode(map[string]string{"status": "library updated succesfully"})
+}
+
+// PatchLibraryHandler godoc
+// @Summary Patch library book
+// @Description Partial update with a JSON merge patch (RFC 7396), e.g. {"title": "Tourist guide"}. Only the sent fields are changed and validated, null is refused.
+// @Tags Library
+// @Security BearerAuth
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "Library ID"
+// @Param If-Match header string false "ETag of the record as read, the patch fails with 412 if it changed since"
+// @Param patch body Library true "Fields to change"
+// @Success 200 {object} Library
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Router /api/libraries/{id} [patch]
+// PatchLibraryHandler changes some fields of an existing library book
+func (h *HybridHandler) PatchLibraryHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	patch, version, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	// Load the current record, the patch applies to it
+	current, err := h.Libraries.Get(r.Context(), idInt)
+	if err == ErrNotFound {
+		http.Error(w, "Book not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if version != 0 && version != current.Version {
+		writeVersionConflict(w)
+		return
+	}
 
-	// Log update actions
-	go LogActivity("UPDATE_STUDENT", "system")
-	go AuditLog("UPDATE", "STUDENT", libraries.Book_id, "system")
+	// Apply the patch and validate the changed fields only
+	libraries, fields, err := applyMergePatch(current, patch, libraryPatchFields)
+	if err != nil {
+		writePatchError(w, err)
+		return
+	}
+	if err := validateFields(libraries, libraryRules, fields); err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	// update on the version the patch was applied to, a concurrent write fails with 412
+	if len(fields) > 0 {
+		err = h.Libraries.Update(r.Context(), &libraries)
+		if err == ErrNotFound {
+			http.Error(w, "Book not found", http.StatusNotFound)
+			return
+		}
+		if err == ErrVersionConflict {
+			writeVersionConflict(w)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	go h.Redis.Client.Set(h.Ctx, id, jsonData, 10*time.Second)
+		// drop the cached copy, the next read loads the new record
+		h.Caches.Books.Invalidate(r.Context(), idInt)
 
-	// send response
+		// Log patch actions
+		go LogActivity("PATCH_BOOK", Actor(r))
+		go AuditLog("PATCH", "BOOK", idInt, Actor(r))
+	}
+
+	// Send response
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "library updated succesfully"})
+	w.Header().Set("ETag", ETag(libraries.Version))
+	json.NewEncoder(w).Encode(libraries)
 }
 
 // DeleteLibraryHandler godoc
@@ -256,37 +386,24 @@ func (h *HybridHandler) DeleteLibraryHandler(w http.ResponseWriter, r *http.Requ
 	id := vars["id"]
 
 	IdInt, _ := strconv.Atoi(id)
-	// delete borrow_records
-	_, err := h.MySQL.db.Exec("DELETE FROM borrow_records WHERE book_id=?", IdInt)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 
-	// Delete library
-	res, err := h.MySQL.db.Exec("DELETE FROM libraries WHERE book_id=?", IdInt)
-	if err != nil {
-		http.Error(w, "unable to delete", http.StatusInternalServerError)
+	// Delete library together with its borrow_records
+	err := h.Libraries.Delete(r.Context(), IdInt)
+	if err == ErrNotFound {
+		http.Error(w, "library not found", http.StatusNotFound)
 		return
 	}
-
-	// check if library exsists
-	rows, err := res.RowsAffected()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if rows == 0 {
-		http.Error(w, "library not found", http.StatusNotFound)
+		http.Error(w, "unable to delete", http.StatusInternalServerError)
 		return
 	}
 
-	// Invalid redis cache
-	go h.Redis.Client.Del(h.Ctx, id)
+	// Invalid cache
+	h.Caches.Books.Invalidate(r.Context(), IdInt)
 
 	// Log delete response
-	go LogActivity("DELETE_STUDENTS", "system")
-	go AuditLog("DELETE", "STUDENT", IdInt, "system")
+	go LogActivity("DELETE_BOOK", Actor(r))
+	go AuditLog("DELETE", "BOOK", IdInt, Actor(r))
 
 	// send response
 	w.Header().Set("Content-Type", "application/json")
@@ -300,8 +417,12 @@ func (h *HybridHandler) DeleteLibraryHandler(w http.ResponseWriter, r *http.Requ
 // @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param record body Borrow_records true "Borrow Record"
-// @Success 201 {object} map[string]string
+// @Description Lend the copy with the scanned barcode to a student or lecturer. The loan policy of the user type and book category sets the due date and fine per day. A refused borrow answers {"err", "code"} where code names the rule: no_loan_policy, reference_only, loan_limit_reached, fines_outstanding (403), copy_not_available or copy_on_hold (400). Borrowing collects the open hold of the user on the book.
+// @Param record body Borrow_records true "user_id, user_type and barcode"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
 // @Router /api/borrow [post]
 // BorrowrecordsHandler handles
 func (h *HybridHandler) BorrowRecordsHandler(w http.ResponseWriter, r *http.Request) {
@@ -324,94 +445,133 @@ func (h *HybridHandler) BorrowRecordsHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	//  Check if Book is available
-	var available int
-	err := h.MySQL.db.QueryRow("SELECT available_copies FROM libraries WHERE book_id=?", record.Book_id).Scan(&available)
+	// the category of the scanned book selects the loan policy
+	record.Barcode = strings.TrimSpace(record.Barcode)
+	bookCopy, err := h.Copies.Get(r.Context(), record.Barcode)
+	if err == ErrNotFound {
+		http.Error(w, "copy not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	book, err := h.Libraries.Get(r.Context(), bookCopy.BookID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if available <= 0 {
-		http.Error(w, " Book not available", http.StatusBadRequest)
+	policy, err := h.LoanPolicies.Get(r.Context(), record.User_type, book.Category)
+	if err == ErrNotFound {
+		writeLoanRefusal(w, http.StatusForbidden, RuleNoPolicy, "no loan policy lets a "+record.User_type+" borrow "+book.Category+" books", nil)
 		return
 	}
-	// Insert borrow record
-	_, err = h.MySQL.db.Exec("INSERT INTO borrow_records(user_id, user_type,book_id ,borrow_date)VALUES (? , ? , ? , NOW())", record.User_id, record.User_type, record.Book_id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	//  decrement available copies
-	_, err = h.MySQL.db.Exec("UPDATE libraries SET available_copies = available_copies-1 WHERE book_id=?", record.Book_id)
+
+	// enforce the policy, the loan and fine limits are checked with the copy taken
+	if policy.MaxLoans == 0 {
+		writeLoanRefusal(w, http.StatusForbidden, RuleReferenceOnly, book.Category+" books are for use in the library only", nil)
+		return
+	}
+
+	// the policy sets the due date and fine rate of the loan
+	record.Due_date = dueDate(time.Now(), policy.LoanDays).Format(time.DateOnly)
+	record.Fine_per_day = policy.FinePerDay
+
+	// Insert borrow record if the copy is available
+	limits := LoanLimits{MaxLoans: policy.MaxLoans, FineLimit: h.Loans.FineLimit}
+	err = h.Borrows.Borrow(r.Context(), &record, &limits)
+	if err == ErrNotFound {
+		http.Error(w, "copy not found", http.StatusNotFound)
+		return
+	}
+	if err == ErrBorrowerNotFound {
+		http.Error(w, record.User_type+" not found", http.StatusNotFound)
+		return
+	}
+	if err == ErrLoanLimit {
+		writeLoanRefusal(w, http.StatusForbidden, RuleLoanLimit, fmt.Sprintf("a %s may have %d %s books on loan at once", record.User_type, policy.MaxLoans, book.Category),
+			map[string]any{"open_loans": limits.OpenLoans, "max_loans": policy.MaxLoans})
+		return
+	}
+	if err == ErrFinesOutstanding {
+		writeLoanRefusal(w, http.StatusForbidden, RuleFinesOutstanding, "borrowing is blocked until fines are paid",
+			map[string]any{"owed": limits.Owed, "limit": h.Loans.FineLimit})
+		return
+	}
+	if err == ErrCopyOnHold {
+		writeLoanRefusal(w, http.StatusBadRequest, RuleCopyOnHold, "copy is kept for another user's hold", nil)
+		return
+	}
+	if err == ErrCopyNotAvailable {
+		// without a free copy the user can join the hold queue
+		msg := "copy not available, it is on loan, damaged or lost"
+		if book.Available_copies == 0 {
+			msg += ", place a hold to join the queue"
+		}
+		writeLoanRefusal(w, http.StatusBadRequest, RuleCopyNotAvailable, msg, map[string]any{"available_copies": book.Available_copies})
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// available copies changed, drop the cached book, a copy released by a collected hold goes to the queue
+	h.Caches.Books.Invalidate(r.Context(), record.Book_id)
+	h.assignHolds(r.Context(), record.Book_id)
+
 	// Log Activity and audit trails
-	go LogActivity("BORROW_RECORD", "system")
-	go AuditLog("BORROW", "RECORDS", record.Book_id, "system")
+	go LogActivity("BORROW_RECORD", Actor(r))
+	go AuditLog("BORROW", "RECORDS", record.Book_id, Actor(r))
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "Book borrowed!"})
+	json.NewEncoder(w).Encode(map[string]any{"status": "Book borrowed!", "record": record})
 }
 
 // GetBorrowRecordsHandler godoc
 // @Summary Get all borrow records
-// @Description Retrieve complete borrowing history with book details
+// @Description Retrieve the borrowing history with book details, newest first unless sorted otherwise
 // @Tags Borrow
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {array} BorrowInfo
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Skip this many rows, links then page by offset"
+// @Param cursor query string false "Cursor from a next/prev link"
+// @Param sort query string false "borrow_id, user_id, book_id or borrow_date, prefix - for descending (default -borrow_id)"
+// @Param user_type query string false "student or lecturer"
+// @Param active query bool false "true for open loans, false for returned ones"
+// @Param from query string false "Borrowed on or after this date (YYYY-MM-DD)"
+// @Param to query string false "Borrowed on or before this date (YYYY-MM-DD)"
+// @Success 200 {object} Page[BorrowInfo]
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/borrow [get]
 // Get all borrowrecords retrives all borrow history from the database
 func (h *HybridHandler) GetBorrowRecordsHandler(w http.ResponseWriter, r *http.Request) {
 
-	// Execute SQL query to fetch borrow records
-	rows, err := h.MySQL.db.Query("SELECT b.borrow_id, b.user_id, b.user_type, b.book_id, l.book_name, b.borrow_date, b.return_date FROM borrow_records b JOIN libraries l ON b.book_id=l.book_id ORDER BY b.borrow_id DESC")
-
-	// if query fails , return server error
+	// Read paging and filters from the query string
+	filter, err := ParseBorrowFilter(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writePageError(w, err)
 		return
 	}
-	// close rows when functiions ends
-	defer rows.Close()
-
-	// slice to store multiple borrow records
-	var records []BorrowInfo
-
-	// loop through all database rows
-	for rows.Next() {
-
-		var r BorrowInfo
-		var borrowdate, returndate sql.NullTime
-
-		// read column values into struct feilds
-		err := rows.Scan(&r.BorrowID, &r.UserID, &r.UserType, &r.BookID, &r.BookType, &borrowdate, &returndate)
-		// if scanning fails return error
-		if err != nil {
-			http.Error(w, err.Error(), 500)
-			return
-		}
-		if borrowdate.Valid {
-			r.BorrowDate = borrowdate.Time.Format(time.RFC3339)
-		}
-
-		if returndate.Valid {
-			r.ReturnDate = returndate.Time.Format(time.RFC3339)
-		}
 
-		// add records to slice
-		records = append(records, r)
+	// fetch borrow records with book details
+	records, err := h.Borrows.List(r.Context(), filter)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
 	}
 
 	// send response
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(records)
+	json.NewEncoder(w).Encode(NewPage(r, filter.PageParams, records))
 }
 
 // ReturnRecordsHandler godoc
@@ -420,8 +580,11 @@ func (h *HybridHandler) GetBorrowRecordsHandler(w http.ResponseWriter, r *http.R
 // @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param record body Borrow_records true "Return Record"
-// @Success 201 {object} map[string]string
+// @Description Close the open loan of the copy with the scanned barcode. A late return is charged a fine, which is returned with the record. When the book has a hold queue the copy is kept for the next hold, returned as "hold".
+// @Param record body Borrow_records true "barcode"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Router /api/return [post]
 // Return book
 func (h *HybridHandler) ReturnRecordsHandler(w http.ResponseWriter, r *http.Request) {
@@ -432,43 +595,43 @@ func (h *HybridHandler) ReturnRecordsHandler(w http.ResponseWriter, r *http.Requ
 		http.Error(w, "invalid json", http.StatusInternalServerError)
 		return
 	}
-	// validate user type
-	if record.User_type != "student" && record.User_type != "lecturer" {
-		http.Error(w, "invalid user_type, must be 'student' or 'lecturer'", http.StatusBadRequest)
-		return
-	}
-	// Update borrow_books record with return date
-	res, err := h.MySQL.db.Exec("UPDATE borrow_records SET return_date=CURDATE() WHERE user_id=? AND book_id=? AND return_date IS NULL", record.User_id, record.Book_id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// the copy identifies the loan
+	if strings.TrimSpace(record.Barcode) == "" {
+		http.Error(w, "barcode of the copy is required", http.StatusBadRequest)
 		return
 	}
-	rows, _ := res.RowsAffected()
-	if rows == 0 {
+	// set the return date and put the copy back
+	record, fine, err := h.Borrows.Return(r.Context(), strings.TrimSpace(record.Barcode))
+	if err == ErrNotFound {
 		http.Error(w, "no active borrow record found", http.StatusNotFound)
 		return
 	}
-	//  increment available copies
-	_, err = h.MySQL.db.Exec("UPDATE libraries SET available_copies = available_copies+1 WHERE book_id=?", record.Book_id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// update redis cache
-	jsonData, err := json.Marshal(record)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	go h.Redis.Client.Set(h.Ctx, fmt.Sprint(record.Book_id), jsonData, 10*time.Second)
+	// available copies changed, drop the cached book and serve the hold queue
+	h.Caches.Books.Invalidate(r.Context(), record.Book_id)
+	ready := h.assignHolds(r.Context(), record.Book_id)
 
 	// Log Activity and audit trails
-	go LogActivity("RETURN_RECORD", "system")
-	go AuditLog("RETURN", "RECORDS", record.Book_id, "system")
-
-	// Send response
+	go LogActivity("RETURN_RECORD", Actor(r))
+	go AuditLog("RETURN", "RECORDS", record.Book_id, Actor(r))
+
+	// Send response, with the fine when the copy came back late
+	response := map[string]any{"status": "Book returned!", "record": record}
+	if fine != nil {
+		go AuditLog("CHARGE", "FINE", fine.FineID, Actor(r))
+		response["fine"] = fine
+	}
+	// the desk puts the copy aside for the hold it went to
+	for _, hold := range ready {
+		if hold.Barcode == record.Barcode {
+			response["hold"] = hold
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "Book returned!"})
+	json.NewEncoder(w).Encode(response)
 
 }
@@ -0,0 +1,229 @@
+package collegemanagementsystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// A loan policy sets how a user type may borrow the books of one category: how many
+// open loans they may have, for how long, how often a loan can be renewed and the fine
+// per day late. Borrowing a book whose category has no policy for the user type is refused.
+
+// Categories of library books
+const (
+	CategoryStandard  = "standard"
+	CategoryShortLoan = "short_loan"
+	CategoryReference = "reference"
+)
+
+// BookCategories lists every book category
+var BookCategories = []string{CategoryStandard, CategoryShortLoan, CategoryReference}
+
+// Codes of the loan rules a borrow can break, sent as "code" with the error
+const (
+	RuleNoPolicy         = "no_loan_policy"
+	RuleReferenceOnly    = "reference_only"
+	RuleLoanLimit        = "loan_limit_reached"
+	RuleFinesOutstanding = "fines_outstanding"
+	RuleCopyNotAvailable = "copy_not_available"
+)
+
+// LoanPolicy is the loan policy of a user type for a book category
+type LoanPolicy struct {
+	UserType string `json:"user_type"`
+	Category string `json:"category"`
+	// MaxLoans caps the open loans of a user in the category, 0 makes the category reference-only
+	MaxLoans     int `json:"max_loans"`
+	LoanDays     int `json:"loan_days"`
+	RenewalLimit int `json:"renewal_limit"`
+	// FinePerDay is charged per day late, in cents
+	FinePerDay int `json:"fine_per_day"`
+}
+
+// DefaultLoanPolicies are the policies migration 000012 creates, the in-memory repositories start with them
+var DefaultLoanPolicies = []LoanPolicy{
+	{UserType: "student", Category: CategoryStandard, MaxLoans: 5, LoanDays: 14, RenewalLimit: 2, FinePerDay: 50},
+	{UserType: "student", Category: CategoryShortLoan, MaxLoans: 2, LoanDays: 3, RenewalLimit: 0, FinePerDay: 100},
+	{UserType: "student", Category: CategoryReference},
+	{UserType: "lecturer", Category: CategoryStandard, MaxLoans: 10, LoanDays: 30, RenewalLimit: 3, FinePerDay: 25},
+	{UserType: "lecturer", Category: CategoryShortLoan, MaxLoans: 3, LoanDays: 7, RenewalLimit: 1, FinePerDay: 50},
+	{UserType: "lecturer", Category: CategoryReference},
+}
+
+// loanPolicyRules validate a policy field by field
+var loanPolicyRules = []fieldRule[LoanPolicy]{
+	// validate user_type
+	{"user_type", func(p LoanPolicy) error {
+		if p.UserType != "student" && p.UserType != "lecturer" {
+			return fmt.Errorf("invalid user_type, must be 'student' or 'lecturer'")
+		}
+		return nil
+	}},
+	// validate category
+	{"category", func(p LoanPolicy) error {
+		if !slices.Contains(BookCategories, p.Category) {
+			return fmt.Errorf("category must be one of %s", strings.Join(BookCategories, ", "))
+		}
+		return nil
+	}},
+	// validate max_loans
+	{"max_loans", func(p LoanPolicy) error {
+		if p.MaxLoans < 0 || p.MaxLoans > 100 {
+			return fmt.Errorf("max_loans must be between 0 and 100")
+		}
+		return nil
+	}},
+	// validate loan_days
+	{"loan_days", func(p LoanPolicy) error {
+		if p.LoanDays < 0 || p.LoanDays > 365 {
+			return fmt.Errorf("loan_days must be between 0 and 365")
+		}
+		if p.MaxLoans > 0 && p.LoanDays == 0 {
+			return fmt.Errorf("loan_days must be at least 1 when loans are allowed")
+		}
+		return nil
+	}},
+	// validate renewal_limit
+	{"renewal_limit", func(p LoanPolicy) error {
+		if p.RenewalLimit < 0 || p.RenewalLimit > 20 {
+			return fmt.Errorf("renewal_limit must be between 0 and 20")
+		}
+		return nil
+	}},
+	// validate fine_per_day
+	{"fine_per_day", func(p LoanPolicy) error {
+		if p.FinePerDay < 0 || p.FinePerDay > 100000 {
+			return fmt.Errorf("fine_per_day must be between 0 and 100000")
+		}
+		return nil
+	}},
+}
+
+// ValidateLoanPolicy ensures policy input data is valid before DB operations
+func ValidateLoanPolicy(p LoanPolicy) error {
+	return validateFields(p, loanPolicyRules, nil)
+}
+
+// writeLoanRefusal answers a borrow refused by a loan rule, code names the rule
+func writeLoanRefusal(w http.ResponseWriter, status int, code, msg string, details map[string]any) {
+	body := map[string]any{"err": msg, "code": code}
+	for k, v := range details {
+		body[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// GetLoanPoliciesHandler godoc
+// @Summary List loan policies
+// @Description The loan policy of every user type and book category
+// @Tags Loan Policies
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} LoanPolicy
+// @Router /api/loan-policies [get]
+// GetLoanPoliciesHandler lists the loan policies
+func (h *HybridHandler) GetLoanPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.LoanPolicies.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policies == nil {
+		policies = []LoanPolicy{}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies)
+}
+
+// PutLoanPolicyHandler godoc
+// @Summary Set a loan policy
+// @Description Create or replace the policy of a user type for a book category. Loans already made keep their due date and fine rate.
+// @Tags Loan Policies
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param user_type path string true "student or lecturer"
+// @Param category path string true "standard, short_loan or reference"
+// @Param policy body LoanPolicy true "max_loans, loan_days, renewal_limit and fine_per_day"
+// @Success 200 {object} LoanPolicy
+// @Success 201 {object} LoanPolicy
+// @Failure 400 {object} map[string]string
+// @Router /api/loan-policies/{user_type}/{category} [put]
+// PutLoanPolicyHandler creates or replaces a loan policy
+func (h *HybridHandler) PutLoanPolicyHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Decode request body
+	var p LoanPolicy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	// user type and category come from the URL
+	vars := mux.Vars(r)
+	p.UserType, p.Category = vars["user_type"], vars["category"]
+
+	// validate policy
+	if err := ValidateLoanPolicy(p); err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	created, err := h.LoanPolicies.Put(r.Context(), p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Log policy changes
+	go LogActivity("PUT_LOAN_POLICY", Actor(r))
+	go AuditLog("PUT", "LOAN_POLICY", p.UserType+"/"+p.Category, Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// DeleteLoanPolicyHandler godoc
+// @Summary Delete a loan policy
+// @Description Without a policy the user type can't borrow books of the category
+// @Tags Loan Policies
+// @Security BearerAuth
+// @Produce json
+// @Param user_type path string true "student or lecturer"
+// @Param category path string true "standard, short_loan or reference"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/loan-policies/{user_type}/{category} [delete]
+// DeleteLoanPolicyHandler removes a loan policy
+func (h *HybridHandler) DeleteLoanPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := h.LoanPolicies.Delete(r.Context(), vars["user_type"], vars["category"])
+	if err == ErrNotFound {
+		http.Error(w, "loan policy not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Log policy changes
+	go LogActivity("DELETE_LOAN_POLICY", Actor(r))
+	go AuditLog("DELETE", "LOAN_POLICY", vars["user_type"]+"/"+vars["category"], Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "loan policy deleted"})
+}
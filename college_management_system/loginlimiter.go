@@ -0,0 +1,146 @@
+package collegemanagementsystem
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Failed logins are counted in the AuthStore (Redis) per account and per client IP.
+//
+//	login_fail:acct:<email> / login_fail:ip:<ip>  failure counters
+//	login_lock:acct:<email> / login_lock:ip:<ip>  lockout markers, the TTL is the time left
+//
+// After LOGIN_MAX_ATTEMPTS failures the account is locked for LOGIN_LOCKOUT_BASE,
+// every further failure doubles the lockout up to LOGIN_LOCKOUT_MAX.
+// The IP uses the same backoff with the higher LOGIN_MAX_ATTEMPTS_IP threshold.
+
+// LoginLimits configures the brute-force protection of LoginHandler
+type LoginLimits struct {
+	MaxAttempts   int
+	MaxAttemptsIP int
+	Window        time.Duration
+	LockoutBase   time.Duration
+	LockoutMax    time.Duration
+}
+
+// Limits used by LoginHandler, overridable through environment variables
+var LoginLimit = LoginLimits{
+	MaxAttempts:   envInt("LOGIN_MAX_ATTEMPTS", 5),
+	MaxAttemptsIP: envInt("LOGIN_MAX_ATTEMPTS_IP", 20),
+	Window:        envDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+	LockoutBase:   envDuration("LOGIN_LOCKOUT_BASE", time.Minute),
+	LockoutMax:    envDuration("LOGIN_LOCKOUT_MAX", time.Hour),
+}
+
+// envInt reads an integer environment variable with a default
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// envDuration reads a duration environment variable (e.g. "90s") with a default
+func envDuration(name string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// ClientIP returns the IP address of the client from the connection.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func loginFailKey(kind, id string) string { return "login_fail:" + kind + ":" + strings.ToLower(id) }
+func loginLockKey(kind, id string) string { return "login_lock:" + kind + ":" + strings.ToLower(id) }
+
+// lockoutFor returns how long to lock after failures, 0 below the threshold.
+func (l LoginLimits) lockoutFor(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	lockout := l.LockoutBase
+	for i := threshold; i < failures && lockout < l.LockoutMax; i++ {
+		lockout *= 2
+	}
+	return min(lockout, l.LockoutMax)
+}
+
+// LoginLockedFor returns the time left until the account or IP may try again, 0 when not locked.
+func (a *HybridHandler) LoginLockedFor(ip, email string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range []string{loginLockKey("acct", email), loginLockKey("ip", ip)} {
+		ttl, err := a.Auth.TTL(a.Ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, ttl)
+	}
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failed login and locks the account or IP when a threshold is reached.
+// It returns the lockout that was started, 0 when none.
+func (a *HybridHandler) RecordLoginFailure(ip, email string) (time.Duration, error) {
+	var lockout time.Duration
+	targets := []struct {
+		kind, id, entity string
+		threshold        int
+	}{
+		{"acct", email, "USER", LoginLimit.MaxAttempts},
+		{"ip", ip, "IP", LoginLimit.MaxAttemptsIP},
+	}
+	for _, t := range targets {
+		failures, err := a.Auth.Incr(a.Ctx, loginFailKey(t.kind, t.id))
+		if err != nil {
+			return 0, err
+		}
+		lock := LoginLimit.lockoutFor(int(failures), t.threshold)
+
+		// keep the counter alive for the window and the whole lockout
+		a.Auth.Expire(a.Ctx, loginFailKey(t.kind, t.id), LoginLimit.Window+lock)
+		if lock == 0 {
+			continue
+		}
+		if err := a.Auth.Set(a.Ctx, loginLockKey(t.kind, t.id), strconv.FormatInt(failures, 10), lock); err != nil {
+			return 0, err
+		}
+		go AuditLog("LOCKOUT", t.entity, t.id, "system")
+		lockout = max(lockout, lock)
+	}
+	return lockout, nil
+}
+
+// ClearLoginFailures resets the failure counter of an account after a successful login or an admin unlock.
+// An UNLOCK audit entry is written when the account had reached the lockout threshold.
+func (a *HybridHandler) ClearLoginFailures(email, actor string) error {
+	value, err := a.Auth.Get(a.Ctx, loginFailKey("acct", email))
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	failures, _ := strconv.Atoi(value)
+	if _, err := a.Auth.Del(a.Ctx, loginFailKey("acct", email), loginLockKey("acct", email)); err != nil {
+		return err
+	}
+	if failures >= LoginLimit.MaxAttempts {
+		go AuditLog("UNLOCK", "USER", email, actor)
+	}
+	return nil
+}
+
+// writeRetryAfter answers 429 with a Retry-After header in whole seconds
+func writeRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "too many failed login attempts, try again in "+strconv.Itoa(seconds)+"s", http.StatusTooManyRequests)
+}
@@ -0,0 +1,88 @@
+package collegemanagementsystem
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLockoutFor(t *testing.T) {
+	limits := LoginLimits{LockoutBase: time.Minute, LockoutMax: 10 * time.Minute}
+	tests := []struct {
+		failures, threshold int
+		want                time.Duration
+	}{
+		{0, 5, 0},
+		{4, 5, 0},
+		{5, 5, time.Minute},
+		{6, 5, 2 * time.Minute},
+		{7, 5, 4 * time.Minute},
+		{8, 5, 8 * time.Minute},
+		{9, 5, 10 * time.Minute},
+		{1000, 5, 10 * time.Minute},
+		{20, 20, time.Minute},
+		{21, 20, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := limits.lockoutFor(tt.failures, tt.threshold); got != tt.want {
+			t.Errorf("lockoutFor(%d, %d) = %v, want %v", tt.failures, tt.threshold, got, tt.want)
+		}
+	}
+
+	// a base above the cap is capped right away
+	capped := LoginLimits{LockoutBase: 2 * time.Hour, LockoutMax: time.Hour}
+	if got := capped.lockoutFor(5, 5); got != time.Hour {
+		t.Errorf("lockoutFor with base above max = %v, want %v", got, time.Hour)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser(t, "admin@example.com", "s3cret-pass", RoleAdmin)
+	creds := Credentials{Email: "admin@example.com", Password: "wrong"}
+
+	for i := 1; i < LoginLimit.MaxAttempts; i++ {
+		if w := s.do(t, "POST", "/login", "", creds); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d, want 401", i, w.Code)
+		}
+	}
+
+	// the failure reaching the threshold starts the lockout
+	base := strconv.Itoa(int(LoginLimit.LockoutBase / time.Second))
+	w := s.do(t, "POST", "/login", "", creds)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("failure %d: status %d, want 429", LoginLimit.MaxAttempts, w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != base {
+		t.Errorf("Retry-After = %q, want %q", got, base)
+	}
+
+	// while locked even the right password is refused
+	w = s.do(t, "POST", "/login", "", Credentials{Email: "admin@example.com", Password: "s3cret-pass"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("locked login: status %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("locked login without Retry-After")
+	}
+
+	// an admin unlock lets the account in again
+	if err := s.ClearLoginFailures("admin@example.com", "test"); err != nil {
+		t.Fatal(err)
+	}
+	s.login(t, "admin@example.com", "s3cret-pass")
+}
+
+func TestLoginLockoutUnknownEmail(t *testing.T) {
+	s := newTestServer(t)
+	creds := Credentials{Email: "nobody@example.com", Password: "guess"}
+	for i := 1; i < LoginLimit.MaxAttempts; i++ {
+		if w := s.do(t, "POST", "/login", "", creds); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d, want 401", i, w.Code)
+		}
+	}
+	if w := s.do(t, "POST", "/login", "", creds); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("failure %d: status %d with Retry-After %q, want 429 with Retry-After", LoginLimit.MaxAttempts, w.Code, w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,114 @@
+package collegemanagementsystem
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer sends plain text emails such as password reset and verification links
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer delivers mail through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// ErrHeaderInjection is returned by SMTPMailer.Send when the recipient or subject
+// holds a line break, which would start a new header
+var ErrHeaderInjection = errors.New("mail header contains a line break")
+
+// Send delivers one message, using PLAIN auth when a username is set
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return ErrHeaderInjection
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	msg := "From: " + m.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+		strings.ReplaceAll(body, "\n", "\r\n")
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer writes messages to a file, or to the log when Path is empty.
+// It is meant for local development and tests.
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Send appends the message to the mail log
+func (m *LogMailer) Send(to, subject, body string) error {
+	entry := fmt.Sprintf("[MAIL] to=%s subject=%q time=%s\n%s\n\n", to, subject, time.Now().Format(time.RFC3339), body)
+	if m.Path == "" {
+		log.Print(entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(entry)
+	return err
+}
+
+// ErrMailerNotConfigured is returned by the mailer used when no mail transport is set up
+var ErrMailerNotConfigured = errors.New("mailer not configured")
+
+// disabledMailer refuses every message, so reset and verification tokens never end up in the log
+type disabledMailer struct{}
+
+func (disabledMailer) Send(to, subject, body string) error {
+	return ErrMailerNotConfigured
+}
+
+// NewMailer builds the mailer from environment variables.
+// MAILER=smtp uses SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM,
+// MAIL_LOG_FILE writes mails to that file and MAILER=log to the server log, for development.
+// Without any of them no mail is sent.
+func NewMailer() Mailer {
+	if os.Getenv("MAILER") == "smtp" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	}
+	if path := os.Getenv("MAIL_LOG_FILE"); path != "" {
+		return &LogMailer{Path: path}
+	}
+	if os.Getenv("MAILER") == "log" {
+		return &LogMailer{}
+	}
+	log.Println("no mailer configured (MAILER=smtp, MAIL_LOG_FILE or MAILER=log), password reset and verification mails are disabled")
+	return disabledMailer{}
+}
@@ -0,0 +1,53 @@
+package collegemanagementsystem
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSMTPMailerHeaderInjection(t *testing.T) {
+	// no server is listening, a refused message never gets to dial
+	m := &SMTPMailer{Host: "127.0.0.1", Port: "1", From: "noreply@example.com"}
+	tests := []struct {
+		name, to, subject string
+	}{
+		{"to with CRLF", "a@example.com\r\nBcc: victim@example.com", "Reset your password"},
+		{"to with LF", "a@example.com\nBcc: victim@example.com", "Reset your password"},
+		{"subject with CR", "a@example.com", "Reset\rBcc: victim@example.com"},
+		{"subject with LF", "a@example.com", "Reset\nBcc: victim@example.com"},
+	}
+	for _, tt := range tests {
+		if err := m.Send(tt.to, tt.subject, "body"); err != ErrHeaderInjection {
+			t.Errorf("%s: err = %v, want ErrHeaderInjection", tt.name, err)
+		}
+	}
+}
+
+// recordingMailer keeps the recipients of the sent messages
+type recordingMailer struct{ to []string }
+
+func (m *recordingMailer) Send(to, subject, body string) error {
+	m.to = append(m.to, to)
+	return nil
+}
+
+func TestForgotPasswordMailsStoredAddress(t *testing.T) {
+	s := newTestServer(t)
+	mailer := &recordingMailer{}
+	s.Mailer = mailer
+	s.createUser(t, "ada@example.com", "s3cret-pass", RoleStudent)
+
+	for range 2 {
+		if w := s.do(t, "POST", "/password/forgot", "", ForgotPassword{Email: "ada@example.com"}); w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+	}
+	if w := s.do(t, "POST", "/password/forgot", "", ForgotPassword{Email: "nobody@example.com"}); w.Code != http.StatusOK {
+		t.Fatalf("unknown email: status %d: %s", w.Code, w.Body)
+	}
+
+	// the second request is within the cooldown, the unknown address gets nothing
+	if len(mailer.to) != 1 || mailer.to[0] != "ada@example.com" {
+		t.Errorf("mails sent to %q, want one to ada@example.com", mailer.to)
+	}
+}
@@ -0,0 +1,454 @@
+package collegemanagementsystem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// A login account can be linked to one Student or Lecturer record through
+// users.student_id / users.lecturer_id. Linked accounts use the /api/me routes
+// to read and edit their own record.
+
+// Identity is the record linked to the logged in account
+type Identity struct {
+	StudentID  int
+	LecturerID int
+}
+
+// IdentityLink is the request payload to link an account to a student or lecturer
+type IdentityLink struct {
+	StudentID  *int `json:"student_id"`
+	LecturerID *int `json:"lecturer_id"`
+}
+
+// Profile is returned by /api/me
+type Profile struct {
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	Student  *Student  `json:"student,omitempty"`
+	Lecturer *Lecturer `json:"lecturer,omitempty"`
+}
+
+// MyFines is returned by /api/me/fines, amounts are in cents
+type MyFines struct {
+	Fines []Fine `json:"fines"`
+	// Balance is unpaid on fines, Accruing is building up on overdue loans
+	Balance  int `json:"balance"`
+	Accruing int `json:"accruing"`
+	// Borrowing is blocked while Balance plus Accruing exceeds Limit
+	Limit   int  `json:"limit"`
+	Blocked bool `json:"blocked"`
+}
+
+// Course represents a course a student is enrolled in or a lecturer teaches
+type Course struct {
+	ID         int    `json:"id"`
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	LecturerID int    `json:"lecturer_id"`
+}
+
+// CurrentIdentity loads the student or lecturer linked to the account of the request
+func (a *HybridHandler) CurrentIdentity(r *http.Request) (Identity, error) {
+	u, err := a.Users.GetByEmail(r.Context(), r.Header.Get("X-User-Email"))
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{StudentID: u.StudentID, LecturerID: u.LecturerID}, nil
+}
+
+// OwnsStudent reports whether the request may access student id.
+// Only accounts with the student role are restricted to their own record.
+func (a *HybridHandler) OwnsStudent(r *http.Request, id int) (bool, error) {
+	if r.Header.Get("X-User-Role") != RoleStudent {
+		return true, nil
+	}
+	identity, err := a.CurrentIdentity(r)
+	if err != nil {
+		return false, err
+	}
+	return identity.StudentID != 0 && identity.StudentID == id, nil
+}
+
+// writeForbidden answers 403 with a JSON error like AuthorizeMiddleware
+func writeForbidden(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{"err": msg})
+}
+
+// LinkUserHandler godoc
+// @Summary Link user to student or lecturer
+// @Description Link a login account to its Student (role student) or Lecturer (role lecturer) record. Send null to unlink.
+// @Tags Users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param link body IdentityLink true "Record to link"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/link [put]
+// LinkUserHandler links a user by ID to a student or lecturer record
+func (a *HybridHandler) LinkUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	vars := mux.Vars(r)
+	idINT, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// Decode request body
+	var link IdentityLink
+	if err := json.NewDecoder(r.Body).Decode(&link); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+	if link.StudentID != nil && link.LecturerID != nil {
+		http.Error(w, "link either student_id or lecturer_id", http.StatusBadRequest)
+		return
+	}
+
+	// Check if user exsists
+	user, err := a.Users.Get(r.Context(), idINT)
+	if err == ErrNotFound {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the linked record must exist and match the role of the account
+	var recordErr error
+	switch {
+	case link.StudentID != nil && user.Role != RoleStudent:
+		http.Error(w, "only student accounts can be linked to students", http.StatusBadRequest)
+		return
+	case link.LecturerID != nil && user.Role != RoleLecturer:
+		http.Error(w, "only lecturer accounts can be linked to lecturers", http.StatusBadRequest)
+		return
+	case link.StudentID != nil:
+		_, recordErr = a.Students.Get(r.Context(), *link.StudentID)
+	case link.LecturerID != nil:
+		_, recordErr = a.Lecturers.Get(r.Context(), *link.LecturerID)
+	}
+	if recordErr == ErrNotFound {
+		http.Error(w, "student or lecturer record not found", http.StatusNotFound)
+		return
+	}
+	if recordErr != nil {
+		http.Error(w, recordErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// link the account, a record belongs to one account only
+	err = a.Users.Link(r.Context(), idINT, link.StudentID, link.LecturerID)
+	if err == ErrNotFound {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "unable to link, the record may already be linked to another account", http.StatusConflict)
+		return
+	}
+
+	// Log link action
+	go LogActivity("LINK_USER", Actor(r))
+	go AuditLog("LINK", "USER", idINT, Actor(r))
+
+	// Send success response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "user linked"})
+}
+
+// GetMeHandler godoc
+// @Summary Get my profile
+// @Description The logged in account with its linked student or lecturer record
+// @Tags Me
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} Profile
+// @Router /api/me [get]
+// GetMeHandler returns the profile of the current user
+func (a *HybridHandler) GetMeHandler(w http.ResponseWriter, r *http.Request) {
+	identity, err := a.CurrentIdentity(r)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	profile := Profile{Email: r.Header.Get("X-User-Email"), Role: r.Header.Get("X-User-Role")}
+	if identity.StudentID != 0 {
+		s, err := a.Students.Get(r.Context(), identity.StudentID)
+		if err != nil {
+			http.Error(w, "student not found", http.StatusNotFound)
+			return
+		}
+		profile.Student = &s
+	}
+	if identity.LecturerID != 0 {
+		l, err := a.Lecturers.Get(r.Context(), identity.LecturerID)
+		if err != nil {
+			http.Error(w, "lecturer not found", http.StatusNotFound)
+			return
+		}
+		profile.Lecturer = &l
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// UpdateMeHandler godoc
+// @Summary Update my record
+// @Description Update name, age and email of the linked student or lecturer record. Dept and designation are kept.
+// @Tags Me
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param record body Student true "Updated fields (name, age, email)"
+// @Success 200 {object} Profile
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/me [put]
+// UpdateMeHandler lets students and lecturers edit their own record
+func (a *HybridHandler) UpdateMeHandler(w http.ResponseWriter, r *http.Request) {
+	identity, err := a.CurrentIdentity(r)
+	if err != nil || (identity.StudentID == 0 && identity.LecturerID == 0) {
+		http.Error(w, "no student or lecturer record linked to this account", http.StatusNotFound)
+		return
+	}
+
+	// Decode request body, only name , age and email are taken
+	var input struct {
+		Name  string `json:"name"`
+		Age   int    `json:"age"`
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+
+	// dept and designation are kept, the version read here guards against concurrent edits
+	var id int
+	var validation error
+	var update func() error
+	if identity.StudentID != 0 {
+		id = identity.StudentID
+		student, err := a.Students.Get(r.Context(), id)
+		if err != nil {
+			http.Error(w, "student not found", http.StatusNotFound)
+			return
+		}
+		student.Name, student.Age, student.Email = input.Name, input.Age, input.Email
+		validation = ValidateStudent(student)
+		update = func() error { return a.Students.Update(r.Context(), &student) }
+	} else {
+		id = identity.LecturerID
+		lecturer, err := a.Lecturers.Get(r.Context(), id)
+		if err != nil {
+			http.Error(w, "lecturer not found", http.StatusNotFound)
+			return
+		}
+		lecturer.Name, lecturer.Age, lecturer.Email = input.Name, input.Age, input.Email
+		validation = Validatelecturer(lecturer)
+		update = func() error { return a.Lecturers.Update(r.Context(), &lecturer) }
+	}
+
+	// validate updated data
+	if validation != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"err": validation.Error()})
+		return
+	}
+
+	// Execute update, a changed email has to be verified again
+	switch err := update(); err {
+	case nil:
+	case ErrNotFound:
+		http.Error(w, "record not found", http.StatusNotFound)
+		return
+	case ErrVersionConflict:
+		http.Error(w, "the record was changed meanwhile, try again", http.StatusConflict)
+		return
+	default:
+		http.Error(w, "unable to update", http.StatusInternalServerError)
+		return
+	}
+
+	// drop the cached copy of the record
+	if identity.StudentID != 0 {
+		a.Caches.Students.Invalidate(r.Context(), id)
+	} else {
+		a.Caches.Lecturers.Invalidate(r.Context(), id)
+	}
+
+	// Log update actions
+	go LogActivity("UPDATE_ME", Actor(r))
+	if identity.StudentID != 0 {
+		go AuditLog("UPDATE", "STUDENT", id, Actor(r))
+	} else {
+		go AuditLog("UPDATE", "LECTURER", id, Actor(r))
+	}
+
+	a.GetMeHandler(w, r)
+}
+
+// GetMyBorrowedHandler godoc
+// @Summary My borrowed books
+// @Description Borrow records of the linked student or lecturer, newest first
+// @Tags Me
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} BorrowInfo
+// @Router /api/me/borrowed [get]
+// GetMyBorrowedHandler lists the borrow records of the current user
+func (a *HybridHandler) GetMyBorrowedHandler(w http.ResponseWriter, r *http.Request) {
+	identity, err := a.CurrentIdentity(r)
+	if err != nil || (identity.StudentID == 0 && identity.LecturerID == 0) {
+		http.Error(w, "no student or lecturer record linked to this account", http.StatusNotFound)
+		return
+	}
+	userID, userType := identity.StudentID, "student"
+	if identity.LecturerID != 0 {
+		userID, userType = identity.LecturerID, "lecturer"
+	}
+
+	records, err := a.Borrows.ListByUser(r.Context(), userType, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if records == nil {
+		records = []BorrowInfo{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+// GetMyFinesHandler godoc
+// @Summary My fines
+// @Description Fines of the linked student or lecturer with the unpaid balance, the fines accruing on overdue loans and whether borrowing is blocked. Amounts are in cents.
+// @Tags Me
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} MyFines
+// @Failure 404 {object} map[string]string
+// @Router /api/me/fines [get]
+// GetMyFinesHandler lists the fines of the current user
+func (a *HybridHandler) GetMyFinesHandler(w http.ResponseWriter, r *http.Request) {
+	identity, err := a.CurrentIdentity(r)
+	if err != nil || (identity.StudentID == 0 && identity.LecturerID == 0) {
+		http.Error(w, "no student or lecturer record linked to this account", http.StatusNotFound)
+		return
+	}
+	userID, userType := identity.StudentID, "student"
+	if identity.LecturerID != 0 {
+		userID, userType = identity.LecturerID, "lecturer"
+	}
+
+	fines, err := a.Fines.List(r.Context(), FineFilter{UserType: userType, UserID: userID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	loans, err := a.Borrows.Overdue(r.Context(), time.Now(), userType, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result := MyFines{Fines: []Fine{}, Limit: a.Loans.FineLimit}
+	for _, fine := range fines {
+		result.Fines = append(result.Fines, fine)
+		result.Balance += fine.Balance
+	}
+	for _, loan := range loans {
+		result.Accruing += loan.AccruedFine
+	}
+	result.Blocked = result.Balance+result.Accruing > result.Limit
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetMyHoldsHandler godoc
+// @Summary My holds
+// @Description Open holds of the linked student or lecturer with their queue position, ready holds have the barcode of the copy kept for them until expires_at
+// @Tags Me
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} Hold
+// @Failure 404 {object} map[string]string
+// @Router /api/me/holds [get]
+// GetMyHoldsHandler lists the holds of the current user
+func (a *HybridHandler) GetMyHoldsHandler(w http.ResponseWriter, r *http.Request) {
+	identity, err := a.CurrentIdentity(r)
+	if err != nil || (identity.StudentID == 0 && identity.LecturerID == 0) {
+		http.Error(w, "no student or lecturer record linked to this account", http.StatusNotFound)
+		return
+	}
+	userID, userType := identity.StudentID, "student"
+	if identity.LecturerID != 0 {
+		userID, userType = identity.LecturerID, "lecturer"
+	}
+
+	holds, err := a.Holds.List(r.Context(), HoldFilter{UserType: userType, UserID: userID, Open: true})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if holds == nil {
+		holds = []Hold{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holds)
+}
+
+// GetMyCoursesHandler godoc
+// @Summary My courses
+// @Description Courses the linked student is enrolled in, or the linked lecturer teaches
+// @Tags Me
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} Course
+// @Router /api/me/courses [get]
+// GetMyCoursesHandler lists the courses of the current user
+func (a *HybridHandler) GetMyCoursesHandler(w http.ResponseWriter, r *http.Request) {
+	identity, err := a.CurrentIdentity(r)
+	if err != nil || (identity.StudentID == 0 && identity.LecturerID == 0) {
+		http.Error(w, "no student or lecturer record linked to this account", http.StatusNotFound)
+		return
+	}
+
+	var courses []Course
+	if identity.StudentID != 0 {
+		courses, err = a.Courses.ListByStudent(r.Context(), identity.StudentID)
+	} else {
+		courses, err = a.Courses.ListByLecturer(r.Context(), identity.LecturerID)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if courses == nil {
+		courses = []Course{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(courses)
+}
@@ -0,0 +1,292 @@
+package collegemanagementsystem
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"college_management_system/db"
+
+	"github.com/joho/godotenv"
+)
+
+// Migrations are the db/migrations/<dialect> files embedded in the binary.
+// The applied version is kept in schema_migrations with the same layout golang-migrate uses
+// (one row: version, dirty), so either tool can be used on the same database.
+// MySQL can't roll back DDL, so a migration that fails halfway leaves the version dirty
+// until it is fixed by hand and marked with "migrate force".
+
+// Migration is one numbered pair of up and down scripts
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migration files of fsys, sorted by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		parts := migrationFile.FindStringSubmatch(e.Name())
+		if parts == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(parts[1])
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		}
+		if parts[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitStatements splits a script on ";" so it runs without multiStatements in the DSN.
+// Lines starting with "--" are comments and are dropped.
+func splitStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	var stmts []string
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// Migrator applies migrations to a database and tracks the version in schema_migrations
+type Migrator struct {
+	db         *DB
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the embedded migrations of the database dialect
+func NewMigrator(m *MySQLInstance) (*Migrator, error) {
+	sub, err := fs.Sub(db.Migrations, "migrations/"+string(m.db.Dialect))
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		return nil, err
+	}
+	mg := &Migrator{db: m.db, migrations: migrations}
+	if _, err := mg.db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)"); err != nil {
+		return nil, err
+	}
+	return mg, nil
+}
+
+// Version returns the applied version, 0 when nothing has been applied yet
+func (mg *Migrator) Version() (int, bool, error) {
+	var version int
+	var dirty bool
+	err := mg.db.QueryRow("SELECT version , dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// setVersion replaces the version row, version 0 clears the table
+func (mg *Migrator) setVersion(version int, dirty bool) error {
+	if _, err := mg.db.Exec("DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+	_, err := mg.db.Exec("INSERT INTO schema_migrations (version , dirty) VALUES (? , ?)", version, dirty)
+	return err
+}
+
+// run executes one script, marking version dirty while it runs and target when it is done
+func (mg *Migrator) run(script string, version, target int) error {
+	if err := mg.setVersion(version, true); err != nil {
+		return err
+	}
+	for _, stmt := range splitStatements(script) {
+		if _, err := mg.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return mg.setVersion(target, false)
+}
+
+// Up applies every pending migration and returns how many were applied
+func (mg *Migrator) Up() (int, error) {
+	current, dirty, err := mg.Version()
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("database is dirty at version %d, fix it and run migrate force", current)
+	}
+
+	applied := 0
+	for _, m := range mg.migrations {
+		if m.Version <= current {
+			continue
+		}
+		log.Printf("[MIGRATE] up %d_%s\n", m.Version, m.Name)
+		if err := mg.run(m.Up, m.Version, m.Version); err != nil {
+			return applied, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// Down rolls back the last n applied migrations
+func (mg *Migrator) Down(n int) error {
+	current, dirty, err := mg.Version()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("database is dirty at version %d, fix it and run migrate force", current)
+	}
+
+	for i := len(mg.migrations) - 1; i >= 0 && n > 0; i-- {
+		m := mg.migrations[i]
+		if m.Version > current {
+			continue
+		}
+		if m.Down == "" {
+			return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+		previous := 0
+		if i > 0 {
+			previous = mg.migrations[i-1].Version
+		}
+		log.Printf("[MIGRATE] down %d_%s\n", m.Version, m.Name)
+		if err := mg.run(m.Down, m.Version, previous); err != nil {
+			return fmt.Errorf("rollback of %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		n--
+	}
+	return nil
+}
+
+// Force sets the version without running anything and clears the dirty flag
+func (mg *Migrator) Force(version int) error {
+	return mg.setVersion(version, false)
+}
+
+// Status writes every migration and whether it is applied
+func (mg *Migrator) Status(w io.Writer) error {
+	current, dirty, err := mg.Version()
+	if err != nil {
+		return err
+	}
+	for _, m := range mg.migrations {
+		state := "pending"
+		if m.Version <= current {
+			state = "applied"
+		}
+		if m.Version == current && dirty {
+			state = "DIRTY"
+		}
+		fmt.Fprintf(w, "%06d  %-8s %s\n", m.Version, state, m.Name)
+	}
+	fmt.Fprintf(w, "version: %d, dirty: %t\n", current, dirty)
+	return nil
+}
+
+// MigrateCommand runs "migrate up", "migrate down N", "migrate status" or "migrate force V"
+// and exits the process with status 1 on errors.
+func MigrateCommand(args []string) {
+
+	// Load environment variables from .env file
+	godotenv.Load()
+
+	usage := "usage: migrate up | down N | status | force VERSION"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+
+	mysqlinstance, err := ConnectMySQL()
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator, err := NewMigrator(mysqlinstance)
+	if err != nil {
+		log.Fatal("unable to load migrations: ", err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("[MIGRATE] %d migrations applied\n", applied)
+	case "down":
+		if len(args) < 2 {
+			log.Fatal("usage: migrate down N")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			log.Fatal("migrate down needs a positive number of migrations")
+		}
+		if err := migrator.Down(n); err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		if err := migrator.Status(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	case "force":
+		if len(args) < 2 {
+			log.Fatal("usage: migrate force VERSION")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			log.Fatal("migrate force needs a version number")
+		}
+		if err := migrator.Force(version); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("[MIGRATE] version forced to %d\n", version)
+	default:
+		log.Fatal(usage)
+	}
+}
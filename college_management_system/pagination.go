@@ -0,0 +1,354 @@
+package collegemanagementsystem
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// List endpoints page their results in one of two ways:
+//   - cursor (default): follow the next/prev links, each carries an opaque cursor holding the
+//     sort value and id of the edge row. Pages stay stable while rows are inserted.
+//   - limit/offset: pass offset=N, the links then use offsets too.
+// sort=field sorts ascending, sort=-field descending, the id breaks ties.
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+type fieldKind int
+
+const (
+	intField fieldKind = iota
+	stringField
+	timeField
+)
+
+// SortField maps a sortable API field to its column
+type SortField struct {
+	Column string
+	Kind   fieldKind
+}
+
+// Cursor marks the edge row of a page, it is sent to clients base64 encoded
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value any    `json:"v"`
+	ID    int    `json:"id"`
+	// Prev pages backwards, to the rows before this one
+	Prev bool `json:"p,omitempty"`
+}
+
+func encodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, fields map[string]SortField) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	field, ok := fields[c.Sort]
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	// JSON loses the type of the sort value, restore it from the field
+	switch v := c.Value.(type) {
+	case float64:
+		if field.Kind != intField {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		c.Value = int(v)
+	case string:
+		if field.Kind == intField {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		if field.Kind == timeField {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cursor")
+			}
+			c.Value = t
+		}
+	default:
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+// PageParams are the paging and sorting query parameters of a list request
+type PageParams struct {
+	Limit  int
+	Offset int
+	// UseOffset is set when the client pages with offset instead of cursors
+	UseOffset bool
+	Cursor    *Cursor
+	Sort      string
+	Desc      bool
+}
+
+// ParsePageParams reads limit, offset, cursor and sort from the query string.
+// fields lists the sortable fields, defaultSort is used when sort is missing (e.g. "-id").
+func ParsePageParams(q url.Values, fields map[string]SortField, defaultSort string) (PageParams, error) {
+	p := PageParams{Limit: DefaultPageLimit}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return p, fmt.Errorf("limit must be a positive number")
+		}
+		p.Limit = min(limit, MaxPageLimit)
+	}
+
+	sort := q.Get("sort")
+	if sort == "" {
+		sort = defaultSort
+	}
+	p.Desc = strings.HasPrefix(sort, "-")
+	p.Sort = strings.TrimPrefix(sort, "-")
+	if _, ok := fields[p.Sort]; !ok {
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		return p, fmt.Errorf("sort must be one of %s (prefix - for descending)", strings.Join(keys, ", "))
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeCursor(v, fields)
+		if err != nil {
+			return p, err
+		}
+		p.Cursor = c
+		p.Sort, p.Desc = c.Sort, c.Desc
+	}
+
+	if v := q.Get("offset"); v != "" {
+		if p.Cursor != nil {
+			return p, fmt.Errorf("use either cursor or offset, not both")
+		}
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return p, fmt.Errorf("offset must be zero or a positive number")
+		}
+		p.Offset = offset
+		p.UseOffset = true
+	}
+	return p, nil
+}
+
+// backward reports whether the page is read towards the start of the list
+func (p PageParams) backward() bool {
+	return p.Cursor != nil && p.Cursor.Prev
+}
+
+// ListResult is one page as returned by a repository
+type ListResult[T any] struct {
+	Items []T
+	Total int
+	// More is set when there are more rows after the page in the direction it was read
+	More bool
+}
+
+// Page is the envelope of every list response
+type Page[T any] struct {
+	Data   []T    `json:"data"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset,omitempty"`
+	Next   string `json:"next,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+}
+
+// pageable is implemented by the types served by list endpoints
+type pageable interface {
+	pageID() int
+	sortValue(field string) any
+}
+
+// NewPage builds the envelope with next/prev links for the request
+func NewPage[T pageable](r *http.Request, p PageParams, res ListResult[T]) Page[T] {
+	page := Page[T]{Data: res.Items, Total: res.Total, Limit: p.Limit, Offset: p.Offset}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+
+	link := func(set func(q url.Values)) string {
+		q := r.URL.Query()
+		q.Del("cursor")
+		q.Del("offset")
+		set(q)
+		return r.URL.Path + "?" + q.Encode()
+	}
+
+	if p.UseOffset {
+		if p.Offset+len(res.Items) < res.Total {
+			page.Next = link(func(q url.Values) { q.Set("offset", strconv.Itoa(p.Offset+p.Limit)) })
+		}
+		if p.Offset > 0 {
+			page.Prev = link(func(q url.Values) { q.Set("offset", strconv.Itoa(max(p.Offset-p.Limit, 0))) })
+		}
+		return page
+	}
+
+	if len(res.Items) == 0 {
+		return page
+	}
+	edge := func(item T, prev bool) string {
+		c := Cursor{Sort: p.Sort, Desc: p.Desc, Value: item.sortValue(p.Sort), ID: item.pageID(), Prev: prev}
+		return link(func(q url.Values) { q.Set("cursor", encodeCursor(c)) })
+	}
+	first, last := res.Items[0], res.Items[len(res.Items)-1]
+	hasNext, hasPrev := res.More, p.Cursor != nil
+	if p.backward() {
+		hasNext, hasPrev = true, res.More
+	}
+	if hasNext {
+		page.Next = edge(last, false)
+	}
+	if hasPrev {
+		page.Prev = edge(first, true)
+	}
+	return page
+}
+
+// writePageError answers 400 for invalid list parameters
+func writePageError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"err": err.Error()})
+}
+
+// Sortable fields of each list endpoint
+
+var studentSortFields = map[string]SortField{
+	"id":    {Column: "id", Kind: intField},
+	"name":  {Column: "name", Kind: stringField},
+	"age":   {Column: "age", Kind: intField},
+	"email": {Column: "email", Kind: stringField},
+	"dept":  {Column: "dept", Kind: stringField},
+}
+
+var lecturerSortFields = map[string]SortField{
+	"id":          {Column: "id", Kind: intField},
+	"name":        {Column: "name", Kind: stringField},
+	"age":         {Column: "age", Kind: intField},
+	"email":       {Column: "email", Kind: stringField},
+	"designation": {Column: "designation", Kind: stringField},
+}
+
+var borrowSortFields = map[string]SortField{
+	"borrow_id":   {Column: "b.borrow_id", Kind: intField},
+	"user_id":     {Column: "b.user_id", Kind: intField},
+	"book_id":     {Column: "b.book_id", Kind: intField},
+	"borrow_date": {Column: "b.borrow_date", Kind: timeField},
+}
+
+func (s Student) pageID() int { return s.Id }
+
+func (s Student) sortValue(field string) any {
+	switch field {
+	case "name":
+		return s.Name
+	case "age":
+		return s.Age
+	case "email":
+		return s.Email
+	case "dept":
+		return s.Dept
+	}
+	return s.Id
+}
+
+func (l Lecturer) pageID() int { return l.ID }
+
+func (l Lecturer) sortValue(field string) any {
+	switch field {
+	case "name":
+		return l.Name
+	case "age":
+		return l.Age
+	case "email":
+		return l.Email
+	case "designation":
+		return l.Designation
+	}
+	return l.ID
+}
+
+func (b BorrowInfo) pageID() int { return b.BorrowID }
+
+func (b BorrowInfo) sortValue(field string) any {
+	switch field {
+	case "user_id":
+		return b.UserID
+	case "book_id":
+		return b.BookID
+	case "borrow_date":
+		return b.borrowedAt
+	}
+	return b.BorrowID
+}
+
+// StudentFilter selects the students of a list request
+type StudentFilter struct {
+	PageParams
+	Dept string
+}
+
+// LecturerFilter selects the lecturers of a list request
+type LecturerFilter struct {
+	PageParams
+	Designation string
+}
+
+// BorrowFilter selects the borrow records of a list request.
+// From and To limit borrow_date, To is inclusive.
+type BorrowFilter struct {
+	PageParams
+	UserType string
+	Active   *bool
+	From     time.Time
+	To       time.Time
+}
+
+// ParseBorrowFilter reads user_type, active, from and to (YYYY-MM-DD) besides the page parameters
+func ParseBorrowFilter(q url.Values) (BorrowFilter, error) {
+	p, err := ParsePageParams(q, borrowSortFields, "-borrow_id")
+	if err != nil {
+		return BorrowFilter{}, err
+	}
+	f := BorrowFilter{PageParams: p, UserType: q.Get("user_type")}
+	if v := q.Get("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("active must be true or false")
+		}
+		f.Active = &active
+	}
+	for name, dst := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+			if err != nil {
+				return f, fmt.Errorf("%s must be a date like 2026-01-31", name)
+			}
+			*dst = t
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,214 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePageParams(t *testing.T) {
+	intCursor := encodeCursor(Cursor{Sort: "age", Value: 20, ID: 3})
+	tests := []struct {
+		name  string
+		query string
+		want  PageParams
+		err   string
+	}{
+		{name: "defaults", query: "", want: PageParams{Limit: DefaultPageLimit, Sort: "id"}},
+		{name: "descending", query: "sort=-name&limit=10", want: PageParams{Limit: 10, Sort: "name", Desc: true}},
+		{name: "limit capped", query: "limit=1000", want: PageParams{Limit: MaxPageLimit, Sort: "id"}},
+		{name: "offset", query: "offset=20", want: PageParams{Limit: DefaultPageLimit, Offset: 20, UseOffset: true, Sort: "id"}},
+		{name: "cursor sets the sort", query: "sort=name&cursor=" + intCursor,
+			want: PageParams{Limit: DefaultPageLimit, Sort: "age", Cursor: &Cursor{Sort: "age", Value: 20, ID: 3}}},
+		{name: "unknown sort field", query: "sort=password", err: "sort must be one of age, dept, email, id, name"},
+		{name: "unknown descending sort field", query: "sort=-password", err: "sort must be one of"},
+		{name: "sort on another list's field", query: "sort=borrow_date", err: "sort must be one of"},
+		{name: "zero limit", query: "limit=0", err: "limit must be a positive number"},
+		{name: "negative limit", query: "limit=-5", err: "limit must be a positive number"},
+		{name: "limit not a number", query: "limit=ten", err: "limit must be a positive number"},
+		{name: "negative offset", query: "offset=-1", err: "offset must be zero or a positive number"},
+		{name: "cursor and offset", query: "offset=5&cursor=" + intCursor, err: "use either cursor or offset, not both"},
+		{name: "cursor not base64", query: "cursor=***", err: "invalid cursor"},
+		{name: "cursor not json", query: "cursor=" + "bm90IGpzb24", err: "invalid cursor"},
+		{name: "cursor with unknown sort", query: "cursor=" + encodeCursor(Cursor{Sort: "password", Value: "x", ID: 1}), err: "invalid cursor"},
+		{name: "cursor value of the wrong type", query: "cursor=" + encodeCursor(Cursor{Sort: "age", Value: "old", ID: 1}), err: "invalid cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParsePageParams(q, studentSortFields, "id")
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got.Cursor) != fmt.Sprint(tt.want.Cursor) {
+				t.Errorf("cursor = %+v, want %+v", got.Cursor, tt.want.Cursor)
+			}
+			got.Cursor, tt.want.Cursor = nil, nil
+			if got != tt.want {
+				t.Errorf("params = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 30, 15, 500, time.UTC)
+	tests := []Cursor{
+		{Sort: "borrow_id", Value: 42, ID: 42},
+		{Sort: "user_id", Desc: true, Value: 7, ID: 12, Prev: true},
+		{Sort: "borrow_date", Value: at, ID: 3},
+	}
+	for _, c := range tests {
+		got, err := decodeCursor(encodeCursor(c), borrowSortFields)
+		if err != nil {
+			t.Errorf("%+v: %v", c, err)
+			continue
+		}
+		if got.Sort != c.Sort || got.Desc != c.Desc || got.ID != c.ID || got.Prev != c.Prev {
+			t.Errorf("decoded %+v, want %+v", got, c)
+		}
+		if want, ok := c.Value.(time.Time); ok {
+			if v, ok := got.Value.(time.Time); !ok || !v.Equal(want) {
+				t.Errorf("decoded value %v, want %v", got.Value, want)
+			}
+		} else if got.Value != c.Value {
+			t.Errorf("decoded value %#v, want %#v", got.Value, c.Value)
+		}
+	}
+}
+
+// listStudents reads the page of /api/students?query from repos like GetStudentHandler
+func listStudents(t *testing.T, repos Repositories, query string) Page[Student] {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/api/students?"+query, nil)
+	p, err := ParsePageParams(r.URL.Query(), studentSortFields, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := repos.Students.List(context.Background(), StudentFilter{PageParams: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPage(r, p, res)
+}
+
+// linkQuery returns the query string of a next or prev link
+func linkQuery(t *testing.T, link string) string {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.RawQuery
+}
+
+func studentIDs(students []Student) []int {
+	ids := make([]int, len(students))
+	for i, s := range students {
+		ids[i] = s.Id
+	}
+	return ids
+}
+
+func TestPaginationRoundTrip(t *testing.T) {
+	// repeated names and ages make the id break ties
+	names := []string{"Cy", "Ab", "Bo", "Ab", "Cy", "Ab", "Dee"}
+	for name, repos := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for i, n := range names {
+				st := Student{Name: n, Age: 20 + i%2, Email: fmt.Sprintf("s%d@gmail.com", i), Dept: "CS"}
+				if err := repos.Students.Create(context.Background(), &st); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, sort := range []string{"id", "-id", "name", "-name", "age", "-age"} {
+				t.Run(sort, func(t *testing.T) {
+					all := listStudents(t, repos, "limit=200&sort="+sort)
+					want := studentIDs(all.Data)
+					if len(want) != len(names) || all.Next != "" || all.Prev != "" {
+						t.Fatalf("full list = %v, next %q, prev %q", want, all.Next, all.Prev)
+					}
+
+					// forwards with cursors
+					var forward []int
+					var pages []Page[Student]
+					page := listStudents(t, repos, "limit=3&sort="+sort)
+					for {
+						pages = append(pages, page)
+						forward = append(forward, studentIDs(page.Data)...)
+						if page.Next == "" {
+							break
+						}
+						page = listStudents(t, repos, linkQuery(t, page.Next))
+					}
+					if !slices.Equal(forward, want) {
+						t.Errorf("cursor pages = %v, want %v", forward, want)
+					}
+
+					// and back from the last page
+					for i := len(pages) - 1; i > 0; i-- {
+						if pages[i].Prev == "" {
+							t.Fatalf("page %d has no prev link", i+1)
+						}
+						prev := listStudents(t, repos, linkQuery(t, pages[i].Prev))
+						if got, want := studentIDs(prev.Data), studentIDs(pages[i-1].Data); !slices.Equal(got, want) {
+							t.Errorf("prev of page %d = %v, want %v", i+1, got, want)
+						}
+					}
+
+					// and with offsets
+					var offset []int
+					page = listStudents(t, repos, "limit=3&offset=0&sort="+sort)
+					for {
+						if page.Total != len(names) {
+							t.Errorf("total = %d, want %d", page.Total, len(names))
+						}
+						offset = append(offset, studentIDs(page.Data)...)
+						if page.Next == "" {
+							break
+						}
+						page = listStudents(t, repos, linkQuery(t, page.Next))
+					}
+					if !slices.Equal(offset, want) {
+						t.Errorf("offset pages = %v, want %v", offset, want)
+					}
+					if prev := listStudents(t, repos, linkQuery(t, page.Prev)); !slices.Equal(studentIDs(prev.Data), want[3:6]) {
+						t.Errorf("prev offset page = %v, want %v", studentIDs(prev.Data), want[3:6])
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestListInvalidSort(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser(t, "admin@example.com", "s3cret-pass", RoleAdmin)
+	token := s.login(t, "admin@example.com", "s3cret-pass").AccessToken
+	for _, path := range []string{"/api/students?sort=password", "/api/lecturers?sort=-salary", "/api/borrow?sort=name"} {
+		w := s.do(t, "GET", path, token, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", path, w.Code)
+			continue
+		}
+		if body := decode[map[string]string](t, w); !strings.HasPrefix(body["err"], "sort must be one of") {
+			t.Errorf("GET %s: err = %q", path, body["err"])
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package collegemanagementsystem
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+)
+
+// PATCH on students, lecturers and libraries takes a JSON merge patch (RFC 7396):
+// an object holding only the fields to change, e.g. {"dept": "ECE"}. Only those fields
+// are validated. Every patchable field is required, so null (remove the field) is refused.
+
+// MergePatchType is the content type of a JSON merge patch
+const MergePatchType = "application/merge-patch+json"
+
+// maxPatchSize caps the body of a PATCH request
+const maxPatchSize = 64 << 10
+
+// FieldError is a validation error of one field
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+// fieldRule validates one field of a T
+type fieldRule[T any] struct {
+	field string
+	check func(T) error
+}
+
+// validateFields runs the rules of fields in order, all rules when fields is nil.
+// The first failure is returned as a *FieldError.
+func validateFields[T any](v T, rules []fieldRule[T], fields []string) error {
+	for _, rule := range rules {
+		if fields != nil && !slices.Contains(fields, rule.field) {
+			continue
+		}
+		if err := rule.check(v); err != nil {
+			return &FieldError{Field: rule.field, Err: err}
+		}
+	}
+	return nil
+}
+
+// readMergePatch checks the content type and If-Match of a PATCH request and reads its body.
+// If-Match is optional here: the patch applies to the current record, and when the header
+// is sent the record must still have that version. It answers the error itself.
+func readMergePatch(w http.ResponseWriter, r *http.Request) (patch []byte, version int, ok bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != MergePatchType {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		json.NewEncoder(w).Encode(map[string]string{"err": "Content-Type must be " + MergePatchType})
+		return nil, 0, false
+	}
+
+	if r.Header.Get("If-Match") != "" {
+		if version, ok = ifMatchVersion(w, r); !ok {
+			return nil, 0, false
+		}
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		writePatchError(w, fmt.Errorf("unable to read the patch: %v", err))
+		return nil, 0, false
+	}
+	return patch, version, true
+}
+
+// applyMergePatch applies patch to a copy of current and returns it with the names of the
+// patched fields. Only the fields in writable can be patched.
+func applyMergePatch[T any](current T, patch []byte, writable []string) (T, []string, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return current, nil, errors.New("the patch must be a JSON object")
+	}
+
+	fields := make([]string, 0, len(members))
+	for field, value := range members {
+		if !slices.Contains(writable, field) {
+			return current, nil, &FieldError{Field: field, Err: fmt.Errorf("%s can't be patched", field)}
+		}
+		if string(bytes.TrimSpace(value)) == "null" {
+			return current, nil, &FieldError{Field: field, Err: fmt.Errorf("%s is required and can't be removed", field)}
+		}
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	// the record is flat, so merging is setting the patched members on top of it
+	patched := current
+	if err := json.Unmarshal(patch, &patched); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			kind := "a string"
+			if typeErr.Type.Kind() != reflect.String {
+				kind = "a number"
+			}
+			return current, nil, &FieldError{Field: typeErr.Field, Err: fmt.Errorf("%s must be %s", typeErr.Field, kind)}
+		}
+		return current, nil, err
+	}
+	return patched, fields, nil
+}
+
+// writePatchError answers 400 for a patch that can't be applied, naming the field when known
+func writePatchError(w http.ResponseWriter, err error) {
+	body := map[string]string{"err": err.Error()}
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		body["field"] = fieldErr.Field
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(body)
+}
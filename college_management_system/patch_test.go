@@ -0,0 +1,100 @@
+package collegemanagementsystem
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	current := Student{Id: 7, Name: "Ada", Age: 20, Email: "ada@gmail.com", Dept: "CS", Version: 3}
+	tests := []struct {
+		name   string
+		patch  string
+		want   Student
+		fields []string
+		// errField is the field named by the *FieldError, "" for an error without a field
+		errField string
+		err      string
+	}{
+		{name: "one field", patch: `{"dept": "ECE"}`,
+			want: Student{Id: 7, Name: "Ada", Age: 20, Email: "ada@gmail.com", Dept: "ECE", Version: 3}, fields: []string{"dept"}},
+		{name: "fields sorted", patch: `{"name": "Ada Lovelace", "age": 21}`,
+			want: Student{Id: 7, Name: "Ada Lovelace", Age: 21, Email: "ada@gmail.com", Dept: "CS", Version: 3}, fields: []string{"age", "name"}},
+		{name: "empty object", patch: `{}`, want: current, fields: []string{}},
+		{name: "null", patch: `{"dept": null}`, errField: "dept", err: "dept is required and can't be removed"},
+		{name: "null with spaces", patch: `{"name":  null }`, errField: "name", err: "name is required and can't be removed"},
+		{name: "read-only field", patch: `{"id": 8}`, errField: "id", err: "id can't be patched"},
+		{name: "version", patch: `{"version": 9}`, errField: "version", err: "version can't be patched"},
+		{name: "unknown field", patch: `{"dept": "ECE", "nickname": "A"}`, errField: "nickname", err: "nickname can't be patched"},
+		{name: "string for a number", patch: `{"age": "twenty"}`, errField: "age", err: "age must be a number"},
+		{name: "number for a string", patch: `{"email": 5}`, errField: "email", err: "email must be a string"},
+		{name: "array", patch: `[{"dept": "ECE"}]`, err: "the patch must be a JSON object"},
+		{name: "null patch", patch: `null`, err: "the patch must be a JSON object"},
+		{name: "invalid json", patch: `{"dept":`, err: "the patch must be a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fields, err := applyMergePatch(current, []byte(tt.patch), studentPatchFields)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("patched = %+v, want %+v", got, tt.want)
+				}
+				if !slices.Equal(fields, tt.fields) {
+					t.Errorf("fields = %v, want %v", fields, tt.fields)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			var fieldErr *FieldError
+			if errors.As(err, &fieldErr) != (tt.errField != "") || (fieldErr != nil && fieldErr.Field != tt.errField) {
+				t.Errorf("field error = %+v, want field %q", fieldErr, tt.errField)
+			}
+			if got != current {
+				t.Errorf("a failed patch returned %+v, want the current record", got)
+			}
+		})
+	}
+}
+
+func TestPatchStudentHandler(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser(t, "registrar@example.com", "s3cret-pass", RoleRegistrar)
+	token := s.login(t, "registrar@example.com", "s3cret-pass").AccessToken
+
+	w := s.do(t, "POST", "/api/students", token, Student{Name: "Ada", Age: 20, Email: "ada@gmail.com", Dept: "CS"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	path := "/api/students/" + strconv.Itoa(decode[Student](t, w).Id)
+	patch := map[string]any{"dept": "ECE"}
+
+	if w := s.do(t, "PATCH", path, token, patch); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("PATCH as application/json: status %d, want 415", w.Code)
+	}
+
+	// only the patched field is validated, a bad value names it
+	w = s.do(t, "PATCH", path, token, map[string]any{"email": "ada@example.com"}, "Content-Type", MergePatchType)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid email: status %d, want 400", w.Code)
+	}
+	if body := decode[map[string]string](t, w); body["field"] != "email" {
+		t.Errorf("invalid email: field = %q, want email", body["field"])
+	}
+
+	w = s.do(t, "PATCH", path, token, patch, "Content-Type", MergePatchType)
+	if w.Code != http.StatusOK {
+		t.Fatalf("patch: status %d: %s", w.Code, w.Body)
+	}
+	if got := decode[Student](t, w); got.Dept != "ECE" || got.Name != "Ada" || got.Age != 20 {
+		t.Errorf("patched = %+v", got)
+	}
+}
@@ -0,0 +1,230 @@
+package collegemanagementsystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Roles a user account can hold
+const (
+	RoleAdmin     = "admin"
+	RoleRegistrar = "registrar"
+	RoleLibrarian = "librarian"
+	RoleLecturer  = "lecturer"
+	RoleStudent   = "student"
+)
+
+// Roles lists every valid role
+var Roles = []string{RoleAdmin, RoleRegistrar, RoleLibrarian, RoleLecturer, RoleStudent}
+
+// ValidateRole checks that role is one of the known roles
+func ValidateRole(role string) error {
+	if !slices.Contains(Roles, role) {
+		return fmt.Errorf("invalid role %q", role)
+	}
+	return nil
+}
+
+// every role, used for read-only routes open to all accounts
+var anyRole = Roles
+
+// staff roles, e.g. the roles that may enroll a second factor
+var staffRoles = []string{RoleAdmin, RoleRegistrar, RoleLibrarian, RoleLecturer}
+
+// RoutePermissions is the permission table for the /api subrouter.
+// The key is the request method and the mux path template of the route,
+// the value is the list of roles allowed to call it.
+// Routes missing from the table are denied for everyone.
+var RoutePermissions = map[string][]string{
+	// Sessions
+	"POST /api/logout-all": anyRole,
+
+	// User accounts
+	"POST /api/users":               {RoleAdmin},
+	"GET /api/users":                {RoleAdmin},
+	"POST /api/users/{id}/disable":  {RoleAdmin},
+	"POST /api/users/{id}/password": {RoleAdmin},
+	"PUT /api/users/{id}/role":      {RoleAdmin},
+	"POST /api/users/{id}/unlock":   {RoleAdmin},
+	"DELETE /api/users/{id}/mfa":    {RoleAdmin},
+	"PUT /api/users/{id}/link":      {RoleAdmin, RoleRegistrar},
+
+	// API keys
+	"POST /api/apikeys":        {RoleAdmin},
+	"GET /api/apikeys":         {RoleAdmin},
+	"DELETE /api/apikeys/{id}": {RoleAdmin},
+
+	// Self-service for linked students and lecturers
+	"GET /api/me":          anyRole,
+	"PUT /api/me":          {RoleStudent, RoleLecturer},
+	"GET /api/me/borrowed": {RoleStudent, RoleLecturer},
+	"GET /api/me/courses":  {RoleStudent, RoleLecturer},
+	"GET /api/me/fines":    {RoleStudent, RoleLecturer},
+	"GET /api/me/holds":    {RoleStudent, RoleLecturer},
+
+	// Two-factor authentication
+	"POST /api/mfa/enroll":   staffRoles,
+	"POST /api/mfa/activate": staffRoles,
+
+	// Students
+	"POST /api/students":        {RoleAdmin, RoleRegistrar},
+	"GET /api/students":         {RoleAdmin, RoleRegistrar, RoleLecturer},
+	"GET /api/students/{id}":    {RoleAdmin, RoleRegistrar, RoleLecturer, RoleStudent}, // students: own record only
+	"PUT /api/students/{id}":    {RoleAdmin, RoleRegistrar},
+	"PATCH /api/students/{id}":  {RoleAdmin, RoleRegistrar},
+	"DELETE /api/students/{id}": {RoleAdmin, RoleRegistrar},
+
+	"POST /api/students/{id}/verify-email": {RoleAdmin, RoleRegistrar},
+
+	// Lecturers
+	"POST /api/lecturers":        {RoleAdmin, RoleRegistrar},
+	"GET /api/lecturers":         anyRole,
+	"GET /api/lecturers/{id}":    anyRole,
+	"PUT /api/lecturers/{id}":    {RoleAdmin, RoleRegistrar},
+	"PATCH /api/lecturers/{id}":  {RoleAdmin, RoleRegistrar},
+	"DELETE /api/lecturers/{id}": {RoleAdmin, RoleRegistrar},
+
+	"POST /api/lecturers/{id}/verify-email": {RoleAdmin, RoleRegistrar},
+
+	// Library
+	"POST /api/libraries":        {RoleLibrarian},
+	"GET /api/libraries/{id}":    anyRole,
+	"PUT /api/libraries/{id}":    {RoleLibrarian},
+	"PATCH /api/libraries/{id}":  {RoleLibrarian},
+	"DELETE /api/libraries/{id}": {RoleLibrarian},
+
+	// Physical copies, scanned by barcode
+	"POST /api/libraries/{id}/copies": {RoleLibrarian},
+	"GET /api/libraries/{id}/copies":  anyRole,
+	"GET /api/copies/{barcode}":       anyRole,
+	"PATCH /api/copies/{barcode}":     {RoleLibrarian},
+
+	// Hold queues, placed and cancelled at the desk
+	"POST /api/libraries/{id}/holds": {RoleLibrarian},
+	"GET /api/libraries/{id}/holds":  {RoleAdmin, RoleLibrarian},
+	"GET /api/holds/{id}":            {RoleAdmin, RoleLibrarian},
+	"DELETE /api/holds/{id}":         {RoleLibrarian},
+
+	// Borrow_records
+	"POST /api/borrow":        {RoleLibrarian},
+	"GET /api/borrow":         {RoleAdmin, RoleLibrarian},
+	"GET /api/borrow/overdue": {RoleAdmin, RoleLibrarian},
+	"POST /api/return":        {RoleLibrarian},
+
+	// Renewals, students and lecturers renew their own loans
+	"POST /api/borrow/{borrow_id}/renew":   {RoleLibrarian, RoleStudent, RoleLecturer},
+	"GET /api/borrow/{borrow_id}/renewals": {RoleAdmin, RoleLibrarian},
+
+	// Loan policies per user type and book category
+	"GET /api/loan-policies":                           anyRole,
+	"PUT /api/loan-policies/{user_type}/{category}":    {RoleAdmin, RoleLibrarian},
+	"DELETE /api/loan-policies/{user_type}/{category}": {RoleAdmin, RoleLibrarian},
+
+	// Fines for late returns, paid or waived at the desk
+	"GET /api/fines":             {RoleAdmin, RoleLibrarian},
+	"GET /api/fines/{id}":        {RoleAdmin, RoleLibrarian},
+	"POST /api/fines/{id}/pay":   {RoleLibrarian},
+	"POST /api/fines/{id}/waive": {RoleAdmin, RoleLibrarian},
+
+	// Search, results are limited to what the role can read
+	"GET /api/search": anyRole,
+
+	// Cache administration
+	"GET /api/cache":                {RoleAdmin},
+	"GET /api/cache/{entity}/{id}":  {RoleAdmin},
+	"DELETE /api/cache/{entity}":    {RoleAdmin},
+	"POST /api/cache/{entity}/warm": {RoleAdmin},
+}
+
+// RouteScopes maps the routes open to API keys to the scope they need.
+// Routes missing from the table are denied for API keys, whatever their scopes.
+// An empty scope lets every key in, the handler checks the scopes itself.
+var RouteScopes = map[string]string{
+	"POST /api/students":                    "students:write",
+	"GET /api/students":                     "students:read",
+	"GET /api/students/{id}":                "students:read",
+	"PUT /api/students/{id}":                "students:write",
+	"PATCH /api/students/{id}":              "students:write",
+	"DELETE /api/students/{id}":             "students:write",
+	"POST /api/students/{id}/verify-email":  "students:write",
+	"POST /api/lecturers":                   "lecturers:write",
+	"GET /api/lecturers":                    "lecturers:read",
+	"GET /api/lecturers/{id}":               "lecturers:read",
+	"PUT /api/lecturers/{id}":               "lecturers:write",
+	"PATCH /api/lecturers/{id}":             "lecturers:write",
+	"DELETE /api/lecturers/{id}":            "lecturers:write",
+	"POST /api/lecturers/{id}/verify-email": "lecturers:write",
+	"POST /api/libraries":                   "library:write",
+	"GET /api/libraries/{id}":               "library:read",
+	"PUT /api/libraries/{id}":               "library:write",
+	"PATCH /api/libraries/{id}":             "library:write",
+	"DELETE /api/libraries/{id}":            "library:write",
+	"POST /api/libraries/{id}/copies":       "library:write",
+	"GET /api/libraries/{id}/copies":        "library:read",
+	"GET /api/copies/{barcode}":             "library:read",
+	"PATCH /api/copies/{barcode}":           "library:write",
+	"POST /api/libraries/{id}/holds":        "library:write",
+	"GET /api/libraries/{id}/holds":         "library:read",
+	"GET /api/holds/{id}":                   "library:read",
+	"DELETE /api/holds/{id}":                "library:write",
+	"POST /api/borrow":                      "library:write",
+	"GET /api/borrow":                       "library:read",
+	"GET /api/borrow/overdue":               "library:read",
+	"POST /api/return":                      "library:write",
+	"POST /api/borrow/{borrow_id}/renew":    "library:write",
+	"GET /api/borrow/{borrow_id}/renewals":  "library:read",
+	"GET /api/fines":                        "library:read",
+	"GET /api/fines/{id}":                   "library:read",
+	"POST /api/fines/{id}/pay":              "library:write",
+	"POST /api/fines/{id}/waive":            "library:write",
+	"GET /api/search":                       "",
+
+	// Loan policies
+	"GET /api/loan-policies":                           "library:read",
+	"PUT /api/loan-policies/{user_type}/{category}":    "library:write",
+	"DELETE /api/loan-policies/{user_type}/{category}": "library:write",
+}
+
+// Allowed reports whether the caller of r may call route (e.g. "GET /api/students"),
+// by its role or, for an API key, by its scopes
+func Allowed(r *http.Request, route string) bool {
+	if r.Header.Get("X-API-Key-ID") != "" {
+		scope, ok := RouteScopes[route]
+		return ok && (scope == "" || slices.Contains(strings.Split(r.Header.Get("X-API-Key-Scopes"), ","), scope))
+	}
+	allowed, ok := RoutePermissions[route]
+	return ok && slices.Contains(allowed, r.Header.Get("X-User-Role"))
+}
+
+// AuthorizeMiddleware checks the role set by JwtMiddleware against RoutePermissions,
+// or the scopes of an API key against RouteScopes.
+// It must run after JwtMiddleware and responds 403 with a JSON error when the call is not allowed.
+func AuthorizeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// look up the matched route in the permission table
+		var key string
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				key = r.Method + " " + tpl
+			}
+		}
+
+		if !Allowed(r, key) {
+			msg := fmt.Sprintf("role %q is not allowed to %s", r.Header.Get("X-User-Role"), key)
+			if keyID := r.Header.Get("X-API-Key-ID"); keyID != "" {
+				msg = fmt.Sprintf("api key %q is not allowed to %s", keyID, key)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"err": msg})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
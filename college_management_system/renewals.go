@@ -0,0 +1,189 @@
+package collegemanagementsystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// A loan can be renewed instead of being returned and borrowed again. Each renewal moves
+// the due date by the loan days of the policy and is kept in the renewal history, up to
+// the renewal limit of the policy. A loan is not renewed while others wait for the book.
+
+// Codes of the rules a renewal can break, sent as "code" with the error
+const (
+	RuleLoanReturned = "loan_returned"
+	RuleLoanOverdue  = "loan_overdue"
+	RuleHoldsPending = "holds_pending"
+	RuleRenewalLimit = "renewal_limit_reached"
+)
+
+// BorrowRenewal is one renewal of a loan
+type BorrowRenewal struct {
+	RenewalID int `json:"renewal_id"`
+	BorrowID  int `json:"borrow_id"`
+	// OldDueDate is empty for loans made before due dates
+	OldDueDate string `json:"old_due_date,omitempty"`
+	NewDueDate string `json:"new_due_date"`
+	RenewedBy  string `json:"renewed_by"`
+	RenewedAt  string `json:"renewed_at"`
+}
+
+// ownsLoan reports whether the request may act on the loan of rec.
+// Only accounts with the student or lecturer role are restricted to their own loans.
+func (h *HybridHandler) ownsLoan(r *http.Request, rec Borrow_records) (bool, error) {
+	role := r.Header.Get("X-User-Role")
+	if role != RoleStudent && role != RoleLecturer {
+		return true, nil
+	}
+	identity, err := h.CurrentIdentity(r)
+	if err != nil {
+		return false, err
+	}
+	if rec.User_type == "student" {
+		return identity.StudentID != 0 && identity.StudentID == rec.User_id, nil
+	}
+	return identity.LecturerID != 0 && identity.LecturerID == rec.User_id, nil
+}
+
+// RenewBorrowHandler godoc
+// @Summary Renew a loan
+// @Description Move the due date of an open loan by the loan days of its policy. Students and lecturers can renew their own loans. A refused renewal answers {"err", "code"}: loan_returned (409), loan_overdue, no_loan_policy, holds_pending or renewal_limit_reached (403).
+// @Tags Borrow
+// @Security BearerAuth
+// @Produce json
+// @Param borrow_id path int true "Borrow ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/borrow/{borrow_id}/renew [post]
+// RenewBorrowHandler extends a loan
+func (h *HybridHandler) RenewBorrowHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	borrowID, _ := strconv.Atoi(mux.Vars(r)["borrow_id"])
+
+	record, err := h.Borrows.Get(r.Context(), borrowID)
+	if err == ErrNotFound {
+		http.Error(w, "borrow record not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ok, err := h.ownsLoan(r, record); err != nil || !ok {
+		writeForbidden(w, "students and lecturers can only renew their own loans")
+		return
+	}
+
+	// only open loans that are not late yet can be renewed
+	if record.Return_date != "" {
+		writeLoanRefusal(w, http.StatusConflict, RuleLoanReturned, "the loan is already returned", nil)
+		return
+	}
+	now := time.Now()
+	if due, err := time.ParseInLocation(time.DateOnly, record.Due_date, time.Local); err == nil && daysLate(due, now) > 0 {
+		writeLoanRefusal(w, http.StatusForbidden, RuleLoanOverdue, "an overdue loan can't be renewed, return the copy",
+			map[string]any{"due_date": record.Due_date})
+		return
+	}
+
+	// the policy of the user type and book category sets the loan days and renewal limit
+	book, err := h.Libraries.Get(r.Context(), record.Book_id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	policy, err := h.LoanPolicies.Get(r.Context(), record.User_type, book.Category)
+	if err == ErrNotFound {
+		writeLoanRefusal(w, http.StatusForbidden, RuleNoPolicy, "no loan policy lets a "+record.User_type+" borrow "+book.Category+" books", nil)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	renewal := BorrowRenewal{BorrowID: borrowID, RenewedBy: Actor(r)}
+	renewals, err := h.Borrows.Renew(r.Context(), &renewal, policy.LoanDays, policy.RenewalLimit)
+	switch err {
+	case nil:
+	case ErrNotFound:
+		http.Error(w, "borrow record not found", http.StatusNotFound)
+		return
+	case ErrLoanReturned:
+		writeLoanRefusal(w, http.StatusConflict, RuleLoanReturned, "the loan is already returned", nil)
+		return
+	case ErrHoldsPending:
+		// the count is the number of waiting holds here
+		writeLoanRefusal(w, http.StatusForbidden, RuleHoldsPending, "others are waiting for this book, the copy goes to the next hold",
+			map[string]any{"holds": renewals})
+		return
+	case ErrRenewalLimit:
+		writeLoanRefusal(w, http.StatusForbidden, RuleRenewalLimit, fmt.Sprintf("a %s may renew %s loans %d times", record.User_type, book.Category, policy.RenewalLimit),
+			map[string]any{"renewals": renewals, "renewal_limit": policy.RenewalLimit})
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	record.Due_date = renewal.NewDueDate
+
+	// Log Activity and audit trails
+	go LogActivity("RENEW_RECORD", Actor(r))
+	go AuditLog("RENEW", "RECORDS", borrowID, Actor(r))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":        "Loan renewed!",
+		"record":        record,
+		"renewal":       renewal,
+		"renewals":      renewals,
+		"renewal_limit": policy.RenewalLimit,
+	})
+}
+
+// GetRenewalsHandler godoc
+// @Summary Renewal history of a loan
+// @Description Every renewal of a loan with the due dates before and after, oldest first
+// @Tags Borrow
+// @Security BearerAuth
+// @Produce json
+// @Param borrow_id path int true "Borrow ID"
+// @Success 200 {array} BorrowRenewal
+// @Failure 404 {object} map[string]string
+// @Router /api/borrow/{borrow_id}/renewals [get]
+// GetRenewalsHandler lists the renewals of a loan
+func (h *HybridHandler) GetRenewalsHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	borrowID, _ := strconv.Atoi(mux.Vars(r)["borrow_id"])
+
+	if _, err := h.Borrows.Get(r.Context(), borrowID); err == ErrNotFound {
+		http.Error(w, "borrow record not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	renewals, err := h.Borrows.Renewals(r.Context(), borrowID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if renewals == nil {
+		renewals = []BorrowRenewal{}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(renewals)
+}
@@ -0,0 +1,282 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Repositories keep the handlers away from *sql.DB.
+// NewSQLRepositories is used by the server, NewMemoryRepositories keeps everything
+// in process memory for tests and demos.
+
+// ErrNotFound is returned by repositories when the record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrBorrowerNotFound is returned by Borrow and Place when the student or lecturer does not exist
+var ErrBorrowerNotFound = errors.New("borrower not found")
+
+// ErrCopyNotAvailable is returned by Borrow when the copy is on loan, damaged or lost
+var ErrCopyNotAvailable = errors.New("copy not available")
+
+// ErrCopyOnHold is returned by Borrow when the copy is kept for another user's hold
+var ErrCopyOnHold = errors.New("copy on hold")
+
+// ErrLoanLimit is returned by Borrow when the borrower has as many open loans as the policy allows
+var ErrLoanLimit = errors.New("loan limit reached")
+
+// ErrFinesOutstanding is returned by Borrow when the borrower owes more than the fine limit
+var ErrFinesOutstanding = errors.New("fines outstanding")
+
+// ErrDuplicateHold is returned when the user already has an open hold on the book
+var ErrDuplicateHold = errors.New("hold already placed")
+
+// ErrHoldClosed is returned when a hold is no longer waiting or ready
+var ErrHoldClosed = errors.New("hold closed")
+
+// ErrLoanReturned is returned by Renew when the loan is closed
+var ErrLoanReturned = errors.New("loan already returned")
+
+// ErrRenewalLimit is returned by Renew when the loan was renewed as often as its policy allows
+var ErrRenewalLimit = errors.New("renewal limit reached")
+
+// ErrHoldsPending is returned by Renew when others wait for the book of the loan
+var ErrHoldsPending = errors.New("holds pending")
+
+// ErrDuplicateBarcode is returned when another copy has the barcode
+var ErrDuplicateBarcode = errors.New("barcode already in use")
+
+// ErrFineSettled is returned when a payment or waiver is recorded on a fine without balance
+var ErrFineSettled = errors.New("fine already settled")
+
+// ErrExceedsBalance is returned when a payment or waiver is larger than the balance of the fine
+var ErrExceedsBalance = errors.New("amount exceeds the balance")
+
+// ErrDuplicateEmail is returned when another account has the email
+var ErrDuplicateEmail = errors.New("email already in use")
+
+// ErrAlreadyLinked is returned when the student or lecturer is linked to another account
+var ErrAlreadyLinked = errors.New("record already linked to another account")
+
+// ErrVersionConflict is returned by updates when the record changed since the version the caller read
+var ErrVersionConflict = errors.New("record was modified")
+
+// StudentRepository stores students
+type StudentRepository interface {
+	// Create inserts the student and sets its Id
+	Create(ctx context.Context, s *Student) error
+	// List returns one page of the students matching the filter
+	List(ctx context.Context, f StudentFilter) (ListResult[Student], error)
+	Get(ctx context.Context, id int) (Student, error)
+	// Update writes name, age, email and dept and refreshes EmailVerified,
+	// which is reset when the email changes. It only writes when the stored version
+	// equals s.Version (0 skips the check), returns ErrVersionConflict otherwise,
+	// and sets s.Version to the new version.
+	Update(ctx context.Context, s *Student) error
+	Delete(ctx context.Context, id int) error
+	// VerifyEmail marks the email of the student as verified and bumps its version.
+	// It returns ErrNotFound when the student is gone or no longer has that email.
+	VerifyEmail(ctx context.Context, id int, email string) error
+}
+
+// LecturerRepository stores lecturers
+type LecturerRepository interface {
+	// Create inserts the lecturer and sets its ID
+	Create(ctx context.Context, l *Lecturer) error
+	// List returns one page of the lecturers matching the filter
+	List(ctx context.Context, f LecturerFilter) (ListResult[Lecturer], error)
+	Get(ctx context.Context, id int) (Lecturer, error)
+	// Update writes name, age, email and designation and refreshes EmailVerified,
+	// which is reset when the email changes. Versions are checked like StudentRepository.Update.
+	Update(ctx context.Context, l *Lecturer) error
+	Delete(ctx context.Context, id int) error
+	// VerifyEmail is StudentRepository.VerifyEmail for lecturers
+	VerifyEmail(ctx context.Context, id int, email string) error
+}
+
+// LibraryRepository stores library books
+type LibraryRepository interface {
+	// Create inserts the book without copies and sets its Book_id
+	Create(ctx context.Context, b *Library) error
+	Get(ctx context.Context, id int) (Library, error)
+	// Update writes every field but Available_copies, which is derived from the copies.
+	// Versions are checked like StudentRepository.Update.
+	Update(ctx context.Context, b *Library) error
+	// Delete removes the book together with its copies and borrow records, fines are kept
+	Delete(ctx context.Context, id int) error
+}
+
+// CopyRepository stores the physical copies of library books. Every change that makes
+// a copy lendable or not recounts Available_copies of the book and bumps its version.
+type CopyRepository interface {
+	// Create adds a copy to an existing book and sets its CopyID,
+	// it returns ErrNotFound for an unknown book and ErrDuplicateBarcode for a used barcode
+	Create(ctx context.Context, c *BookCopy) error
+	// List returns the copies of a book ordered by barcode
+	List(ctx context.Context, bookID int) ([]BookCopy, error)
+	Get(ctx context.Context, barcode string) (BookCopy, error)
+	// Update writes condition and shelf location of the copy with c.Barcode.
+	// A held copy that becomes damaged or lost puts its hold back in the queue.
+	Update(ctx context.Context, c *BookCopy) error
+}
+
+// LoanLimits are the limits of a loan policy that Borrow enforces. The open loans and the
+// owed fines of the borrower are counted with the copy taken, so concurrent borrows of the
+// same user can't pass the limits together.
+type LoanLimits struct {
+	// MaxLoans is the number of open loans allowed on books of the category of the copy
+	MaxLoans int
+	// FineLimit is the most the borrower may owe, fines charged and accruing on overdue loans
+	FineLimit int
+	// OpenLoans and Owed are set by Borrow to what it counted
+	OpenLoans int
+	Owed      int
+}
+
+// BorrowRepository stores borrow records and keeps the copies in step.
+// Borrow and Return are atomic, a copy is never lent twice under concurrent requests.
+// Both recount the available copies of the book.
+type BorrowRepository interface {
+	// Borrow records a loan of the copy with rec.Barcode and sets Borrow_id, Book_id and Copy_id.
+	// Due_date (YYYY-MM-DD) and Fine_per_day are stored as given. An open hold of the user on
+	// the book is collected. It returns ErrNotFound for an unknown barcode, ErrBorrowerNotFound
+	// when the student or lecturer does not exist, ErrLoanLimit or
+	// ErrFinesOutstanding when the user is over the limits, ErrCopyOnHold when the copy is kept
+	// for another user and ErrCopyNotAvailable when it is on loan, damaged or lost.
+	Borrow(ctx context.Context, rec *Borrow_records, limits *LoanLimits) error
+	// List returns one page of the borrow records matching the filter, with their book names
+	List(ctx context.Context, f BorrowFilter) (ListResult[BorrowInfo], error)
+	// ListByUser returns every borrow record of a user, newest first
+	ListByUser(ctx context.Context, userType string, userID int) ([]BorrowInfo, error)
+	// Return closes the open loan of the copy with barcode and returns it, with the fine
+	// charged when it is returned after its due date. It returns ErrNotFound when the copy
+	// is unknown or not on loan.
+	Return(ctx context.Context, barcode string) (Borrow_records, *Fine, error)
+	// Get returns the borrow record with id and the barcode of its copy
+	Get(ctx context.Context, id int) (Borrow_records, error)
+	// Renew moves the due date of the open loan rn.BorrowID by days, records the renewal and
+	// sets the rest of rn, and returns the number of renewals of the loan. A loan without a due
+	// date becomes due days from today. It returns ErrNotFound, ErrLoanReturned, ErrHoldsPending
+	// with the number of waiting holds on the book, or ErrRenewalLimit with the number of
+	// renewals when the loan was renewed limit times.
+	Renew(ctx context.Context, rn *BorrowRenewal, days, limit int) (int, error)
+	// Renewals returns the renewals of a loan, oldest first
+	Renewals(ctx context.Context, borrowID int) ([]BorrowRenewal, error)
+	// Overdue returns the open loans due before the day of at, oldest due date first.
+	// A zero userID or empty userType matches every user.
+	Overdue(ctx context.Context, at time.Time, userType string, userID int) ([]OverdueLoan, error)
+}
+
+// HoldRepository stores the hold queues of the books. The copy kept for a ready hold is on
+// hold and no longer counts in available_copies.
+type HoldRepository interface {
+	// Place adds h to the end of the queue of h.BookID and sets HoldID, Status, Position and PlacedAt.
+	// It returns ErrNotFound for an unknown book, ErrBorrowerNotFound when the student or lecturer
+	// does not exist and ErrDuplicateHold when the user has an open hold on it.
+	Place(ctx context.Context, h *Hold) error
+	Get(ctx context.Context, id int) (Hold, error)
+	// List returns the holds matching the filter, oldest first
+	List(ctx context.Context, f HoldFilter) ([]Hold, error)
+	// Cancel closes an open hold and releases its copy. It returns ErrNotFound, or
+	// ErrHoldClosed with the hold when it is no longer open.
+	Cancel(ctx context.Context, id int, now time.Time) (Hold, error)
+	// Assign keeps the lendable copies of a book for its oldest waiting holds until
+	// now+pickup and returns the holds that became ready
+	Assign(ctx context.Context, bookID int, now time.Time, pickup time.Duration) ([]Hold, error)
+	// Expire closes the ready holds whose pickup window ended before now, releases their
+	// copies and returns the ids of the books they were on
+	Expire(ctx context.Context, now time.Time) ([]int, error)
+}
+
+// LoanPolicyRepository stores the loan policies, one per user type and book category
+type LoanPolicyRepository interface {
+	// List returns every policy ordered by user type and category
+	List(ctx context.Context) ([]LoanPolicy, error)
+	Get(ctx context.Context, userType, category string) (LoanPolicy, error)
+	// Put creates or replaces the policy of p.UserType and p.Category and reports whether it was created
+	Put(ctx context.Context, p LoanPolicy) (bool, error)
+	Delete(ctx context.Context, userType, category string) error
+}
+
+// FineRepository stores the fines charged for late returns and their payments and waivers
+type FineRepository interface {
+	// List returns the fines matching the filter, newest first
+	List(ctx context.Context, f FineFilter) ([]Fine, error)
+	// Get returns the fine with its transactions
+	Get(ctx context.Context, id int) (Fine, error)
+	// Settle records t against fine t.FineID and returns the fine. An Amount of 0 settles
+	// the whole balance. It returns ErrNotFound, ErrFineSettled or ErrExceedsBalance,
+	// with the fine and its current balance for ErrExceedsBalance.
+	Settle(ctx context.Context, t *FineTransaction) (Fine, error)
+	// Balance returns the unpaid total of the fines of a user
+	Balance(ctx context.Context, userType string, userID int) (int, error)
+}
+
+// UserRepository stores the login accounts with their password hash, second factor
+// and the student or lecturer record they are linked to
+type UserRepository interface {
+	// Create inserts the account with u.PasswordHash and sets its ID and CreatedAt.
+	// It returns ErrDuplicateEmail when another account has the email.
+	Create(ctx context.Context, u *User) error
+	// List returns every account ordered by id
+	List(ctx context.Context) ([]User, error)
+	Get(ctx context.Context, id int) (User, error)
+	GetByEmail(ctx context.Context, email string) (User, error)
+	SetPassword(ctx context.Context, id int, hash string) error
+	SetRole(ctx context.Context, id int, role string) error
+	Disable(ctx context.Context, id int) error
+	// Link links the account to a student or lecturer, nil ids unlink. It returns
+	// ErrAlreadyLinked when the record is linked to another account.
+	Link(ctx context.Context, id int, studentID, lecturerID *int) error
+	// SetTOTPSecret stores a pending secret, EnableTOTP activates it and replaces the
+	// recovery codes with the given hashes, ResetTOTP removes the secret and the codes
+	SetTOTPSecret(ctx context.Context, id int, secret string) error
+	EnableTOTP(ctx context.Context, id int, recoveryHashes []string) error
+	ResetTOTP(ctx context.Context, id int) error
+	// UseRecoveryCode marks the unused recovery code with hash as used and reports whether there was one
+	UseRecoveryCode(ctx context.Context, id int, hash string) (bool, error)
+}
+
+// APIKeyRepository stores the API keys of machine clients
+type APIKeyRepository interface {
+	// Create inserts the key with the hash of its secret and sets its ID and CreatedAt
+	Create(ctx context.Context, k *APIKey, secretHash string) error
+	// List returns every key ordered by id, without secret hashes
+	List(ctx context.Context) ([]APIKey, error)
+	// Active returns the unrevoked key with keyID and the hash of its secret, ErrNotFound otherwise
+	Active(ctx context.Context, keyID string) (APIKey, string, error)
+	// Touch records the last use of a key
+	Touch(ctx context.Context, id int, at time.Time) error
+	// Revoke revokes a key, it returns ErrNotFound when it is unknown or already revoked
+	Revoke(ctx context.Context, id int, at time.Time) error
+}
+
+// CourseRepository reads the courses and their enrollments
+type CourseRepository interface {
+	// ListByStudent returns the courses a student is enrolled in, ordered by code
+	ListByStudent(ctx context.Context, studentID int) ([]Course, error)
+	// ListByLecturer returns the courses a lecturer teaches, ordered by code
+	ListByLecturer(ctx context.Context, lecturerID int) ([]Course, error)
+}
+
+// SearchRepository searches books, students and lecturers
+type SearchRepository interface {
+	// Search returns the best matches of the query types, best first
+	Search(ctx context.Context, q SearchQuery) ([]SearchResult, error)
+}
+
+// Repositories groups the repositories used by HybridHandler
+type Repositories struct {
+	Students     StudentRepository
+	Lecturers    LecturerRepository
+	Libraries    LibraryRepository
+	Copies       CopyRepository
+	Borrows      BorrowRepository
+	Fines        FineRepository
+	Holds        HoldRepository
+	LoanPolicies LoanPolicyRepository
+	Search       SearchRepository
+	Users        UserRepository
+	APIKeys      APIKeyRepository
+	Courses      CourseRepository
+}
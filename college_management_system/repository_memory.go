@@ -0,0 +1,1223 @@
+package collegemanagementsystem
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore holds every table of the in-memory repositories behind one lock,
+// so borrowing and returning update books and loans together.
+type memoryStore struct {
+	mu sync.Mutex
+
+	students  map[int]Student
+	lecturers map[int]Lecturer
+	libraries map[int]Library
+	copies    map[string]BookCopy // by barcode
+	borrows   []Borrow_records
+	renewals  []BorrowRenewal
+	fines     []Fine                   // with their transactions
+	holds     []Hold                   // by hold id, Position is derived on read
+	policies  map[[2]string]LoanPolicy // by user type and category
+	users     map[int]User
+	recovery  map[int]map[string]bool // by user, used flag by code hash
+	apiKeys   map[int]memoryAPIKey
+	courses   []Course
+	enrolled  map[[2]int]bool // by course and student
+	nextID    map[string]int
+}
+
+// NewMemoryRepositories returns empty repositories kept in process memory.
+// They are meant for tests and demos, nothing is persisted.
+func NewMemoryRepositories() Repositories {
+	s := &memoryStore{
+		students:  map[int]Student{},
+		lecturers: map[int]Lecturer{},
+		libraries: map[int]Library{},
+		copies:    map[string]BookCopy{},
+		policies:  map[[2]string]LoanPolicy{},
+		users:     map[int]User{},
+		recovery:  map[int]map[string]bool{},
+		apiKeys:   map[int]memoryAPIKey{},
+		enrolled:  map[[2]int]bool{},
+		nextID:    map[string]int{},
+	}
+	for _, p := range DefaultLoanPolicies {
+		s.policies[[2]string{p.UserType, p.Category}] = p
+	}
+	return Repositories{
+		Students:     &memoryStudents{s},
+		Lecturers:    &memoryLecturers{s},
+		Libraries:    &memoryLibraries{s},
+		Copies:       &memoryCopies{s},
+		Borrows:      &memoryBorrows{s},
+		Fines:        &memoryFines{s},
+		Holds:        &memoryHolds{s},
+		LoanPolicies: &memoryLoanPolicies{s},
+		Search:       &memorySearch{s},
+		Users:        &memoryUsers{s},
+		APIKeys:      &memoryAPIKeys{s},
+		Courses:      &memoryCourses{s},
+	}
+}
+
+// id returns the next auto increment id of table, the caller holds the lock
+func (s *memoryStore) id(table string) int {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// compareValues orders two sort values of the same field
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return cmp.Compare(a, b.(int))
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+// memoryPage sorts, pages and counts items the way listPage does in SQL
+func memoryPage[T pageable](items []T, p PageParams) ListResult[T] {
+	res := ListResult[T]{Total: len(items)}
+	desc := p.Desc != p.backward()
+	order := func(a, b T) int {
+		c := compareValues(a.sortValue(p.Sort), b.sortValue(p.Sort))
+		if c == 0 {
+			c = cmp.Compare(a.pageID(), b.pageID())
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(items, order)
+
+	if c := p.Cursor; c != nil {
+		items = slices.DeleteFunc(items, func(item T) bool {
+			v := compareValues(item.sortValue(p.Sort), c.Value)
+			if v == 0 {
+				v = cmp.Compare(item.pageID(), c.ID)
+			}
+			if desc {
+				v = -v
+			}
+			return v <= 0
+		})
+	}
+
+	items = items[min(p.Offset, len(items)):]
+	if len(items) > p.Limit {
+		items, res.More = items[:p.Limit], true
+	}
+	if p.backward() {
+		slices.Reverse(items)
+	}
+	res.Items = items
+	return res
+}
+
+// Students
+
+type memoryStudents struct{ *memoryStore }
+
+func (m *memoryStudents) Create(ctx context.Context, st *Student) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st.Id = m.id("students")
+	st.EmailVerified = false
+	st.Version = 1
+	m.students[st.Id] = *st
+	return nil
+}
+
+func (m *memoryStudents) List(ctx context.Context, f StudentFilter) (ListResult[Student], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var students []Student
+	for _, st := range m.students {
+		if f.Dept == "" || st.Dept == f.Dept {
+			students = append(students, st)
+		}
+	}
+	return memoryPage(students, f.PageParams), nil
+}
+
+func (m *memoryStudents) Get(ctx context.Context, id int) (Student, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.students[id]
+	if !ok {
+		return st, ErrNotFound
+	}
+	return st, nil
+}
+
+func (m *memoryStudents) Update(ctx context.Context, st *Student) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.students[st.Id]
+	if !ok {
+		return ErrNotFound
+	}
+	if st.Version != 0 && st.Version != old.Version {
+		return ErrVersionConflict
+	}
+	st.Version = old.Version + 1
+	st.EmailVerified = old.EmailVerified && old.Email == st.Email
+	m.students[st.Id] = *st
+	return nil
+}
+
+func (m *memoryStudents) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.students[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.students, id)
+	for uid, u := range m.users {
+		if u.StudentID == id {
+			u.StudentID = 0
+			m.users[uid] = u
+		}
+	}
+	return nil
+}
+
+func (m *memoryStudents) VerifyEmail(ctx context.Context, id int, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.students[id]
+	if !ok || st.Email != email {
+		return ErrNotFound
+	}
+	st.EmailVerified = true
+	st.Version++
+	m.students[id] = st
+	return nil
+}
+
+// Lecturers
+
+type memoryLecturers struct{ *memoryStore }
+
+func (m *memoryLecturers) Create(ctx context.Context, l *Lecturer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l.ID = m.id("lecturers")
+	l.EmailVerified = false
+	l.Version = 1
+	m.lecturers[l.ID] = *l
+	return nil
+}
+
+func (m *memoryLecturers) List(ctx context.Context, f LecturerFilter) (ListResult[Lecturer], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var lecturers []Lecturer
+	for _, l := range m.lecturers {
+		if f.Designation == "" || l.Designation == f.Designation {
+			lecturers = append(lecturers, l)
+		}
+	}
+	return memoryPage(lecturers, f.PageParams), nil
+}
+
+func (m *memoryLecturers) Get(ctx context.Context, id int) (Lecturer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.lecturers[id]
+	if !ok {
+		return l, ErrNotFound
+	}
+	return l, nil
+}
+
+func (m *memoryLecturers) Update(ctx context.Context, l *Lecturer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.lecturers[l.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if l.Version != 0 && l.Version != old.Version {
+		return ErrVersionConflict
+	}
+	l.Version = old.Version + 1
+	l.EmailVerified = old.EmailVerified && old.Email == l.Email
+	m.lecturers[l.ID] = *l
+	return nil
+}
+
+func (m *memoryLecturers) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lecturers[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.lecturers, id)
+	for uid, u := range m.users {
+		if u.LecturerID == id {
+			u.LecturerID = 0
+			m.users[uid] = u
+		}
+	}
+	return nil
+}
+
+func (m *memoryLecturers) VerifyEmail(ctx context.Context, id int, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.lecturers[id]
+	if !ok || l.Email != email {
+		return ErrNotFound
+	}
+	l.EmailVerified = true
+	l.Version++
+	m.lecturers[id] = l
+	return nil
+}
+
+// Library
+
+type memoryLibraries struct{ *memoryStore }
+
+func (m *memoryLibraries) Create(ctx context.Context, b *Library) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b.Book_id = m.id("libraries")
+	b.Available_copies = 0
+	b.Version = 1
+	m.libraries[b.Book_id] = *b
+	return nil
+}
+
+func (m *memoryLibraries) Get(ctx context.Context, id int) (Library, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.libraries[id]
+	if !ok {
+		return b, ErrNotFound
+	}
+	return b, nil
+}
+
+func (m *memoryLibraries) Update(ctx context.Context, b *Library) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.libraries[b.Book_id]
+	if !ok {
+		return ErrNotFound
+	}
+	if b.Version != 0 && b.Version != old.Version {
+		return ErrVersionConflict
+	}
+	b.Version = old.Version + 1
+	b.Available_copies = old.Available_copies
+	m.libraries[b.Book_id] = *b
+	return nil
+}
+
+func (m *memoryLibraries) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.libraries[id]; !ok {
+		return ErrNotFound
+	}
+	m.renewals = slices.DeleteFunc(m.renewals, func(rn BorrowRenewal) bool {
+		return slices.ContainsFunc(m.borrows, func(rec Borrow_records) bool { return rec.Borrow_id == rn.BorrowID && rec.Book_id == id })
+	})
+	m.borrows = slices.DeleteFunc(m.borrows, func(rec Borrow_records) bool { return rec.Book_id == id })
+	m.holds = slices.DeleteFunc(m.holds, func(h Hold) bool { return h.BookID == id })
+	maps.DeleteFunc(m.copies, func(_ string, c BookCopy) bool { return c.BookID == id })
+	delete(m.libraries, id)
+	return nil
+}
+
+// Book copies
+
+// recount derives the available copies of a book and bumps its version, the caller holds the lock
+func (s *memoryStore) recount(bookID int) {
+	b := s.libraries[bookID]
+	b.Available_copies = 0
+	for _, c := range s.copies {
+		if c.BookID == bookID && c.Lendable() {
+			b.Available_copies++
+		}
+	}
+	b.Version++
+	s.libraries[bookID] = b
+}
+
+type memoryCopies struct{ *memoryStore }
+
+func (m *memoryCopies) Create(ctx context.Context, c *BookCopy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.libraries[c.BookID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.copies[c.Barcode]; ok {
+		return ErrDuplicateBarcode
+	}
+	c.CopyID = m.id("book_copies")
+	c.OnLoan, c.OnHold = false, false
+	m.copies[c.Barcode] = *c
+	m.recount(c.BookID)
+	return nil
+}
+
+func (m *memoryCopies) List(ctx context.Context, bookID int) ([]BookCopy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var copies []BookCopy
+	for _, c := range m.copies {
+		if c.BookID == bookID {
+			copies = append(copies, c)
+		}
+	}
+	slices.SortFunc(copies, func(a, b BookCopy) int { return strings.Compare(a.Barcode, b.Barcode) })
+	return copies, nil
+}
+
+func (m *memoryCopies) Get(ctx context.Context, barcode string) (BookCopy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.copies[barcode]
+	if !ok {
+		return c, ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *memoryCopies) Update(ctx context.Context, c *BookCopy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.copies[c.Barcode]
+	if !ok {
+		return ErrNotFound
+	}
+	old.Condition, old.ShelfLocation = c.Condition, c.ShelfLocation
+	// a damaged or lost copy can't be collected, its hold waits for another copy
+	if old.OnHold && (old.Condition == CopyDamaged || old.Condition == CopyLost) {
+		for i, h := range m.holds {
+			if h.Status == HoldReady && h.Barcode == old.Barcode {
+				m.holds[i].Status, m.holds[i].Barcode, m.holds[i].ReadyAt, m.holds[i].ExpiresAt = HoldWaiting, "", "", ""
+			}
+		}
+		old.OnHold = false
+	}
+	m.copies[c.Barcode] = old
+	*c = old
+	m.recount(c.BookID)
+	return nil
+}
+
+// Borrow_records
+
+type memoryBorrows struct{ *memoryStore }
+
+// borrowerExists reports whether the student or lecturer exists, the caller holds the lock
+func (s *memoryStore) borrowerExists(userType string, userID int) bool {
+	if userType == "lecturer" {
+		_, ok := s.lecturers[userID]
+		return ok
+	}
+	_, ok := s.students[userID]
+	return ok
+}
+
+func (m *memoryBorrows) Borrow(ctx context.Context, rec *Borrow_records, limits *LoanLimits) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.copies[rec.Barcode]
+	if !ok {
+		return ErrNotFound
+	}
+	if !m.borrowerExists(rec.User_type, rec.User_id) {
+		return ErrBorrowerNotFound
+	}
+
+	// count the open loans and owed fines of the borrower under the same lock as the loan
+	now := time.Now()
+	limits.OpenLoans, limits.Owed = 0, 0
+	for _, b := range m.borrows {
+		if b.Return_date != "" || b.User_type != rec.User_type || b.User_id != rec.User_id {
+			continue
+		}
+		if m.libraries[b.Book_id].Category == m.libraries[c.BookID].Category {
+			limits.OpenLoans++
+		}
+		if due, err := time.ParseInLocation(time.DateOnly, b.Due_date, time.Local); err == nil {
+			limits.Owed += daysLate(due, now) * b.Fine_per_day
+		}
+	}
+	if limits.OpenLoans >= limits.MaxLoans {
+		return ErrLoanLimit
+	}
+	for _, fine := range m.fines {
+		if fine.UserType == rec.User_type && fine.UserID == rec.User_id {
+			limits.Owed += fine.Balance
+		}
+	}
+	if limits.Owed > limits.FineLimit {
+		return ErrFinesOutstanding
+	}
+
+	// the open hold of the borrower on the book is collected, a copy on hold only goes to its holder
+	i := slices.IndexFunc(m.holds, func(h Hold) bool {
+		return h.BookID == c.BookID && h.UserType == rec.User_type && h.UserID == rec.User_id && h.open()
+	})
+	if c.OnHold && (i < 0 || m.holds[i].Barcode != c.Barcode) {
+		return ErrCopyOnHold
+	}
+	c.OnHold = false
+	if !c.Lendable() {
+		return ErrCopyNotAvailable
+	}
+	if i >= 0 {
+		m.holds[i].Status, m.holds[i].ClosedAt = HoldCollected, now.Format(time.RFC3339)
+		m.release(m.holds[i].Barcode)
+	}
+
+	rec.Borrow_id = m.id("borrow_records")
+	rec.Book_id, rec.Copy_id = c.BookID, c.CopyID
+	rec.Borrow_date = now.Format(time.RFC3339)
+	rec.Return_date = ""
+	m.borrows = append(m.borrows, *rec)
+	c.OnLoan = true
+	m.copies[c.Barcode] = c
+	m.recount(c.BookID)
+	return nil
+}
+
+func (m *memoryBorrows) List(ctx context.Context, f BorrowFilter) (ListResult[BorrowInfo], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var records []BorrowInfo
+	for _, rec := range m.borrows {
+		borrowedAt, _ := time.Parse(time.RFC3339, rec.Borrow_date)
+		switch {
+		case f.UserType != "" && rec.User_type != f.UserType,
+			f.Active != nil && *f.Active != (rec.Return_date == ""),
+			!f.From.IsZero() && borrowedAt.Before(f.From),
+			!f.To.IsZero() && !borrowedAt.Before(f.To.AddDate(0, 0, 1)):
+			continue
+		}
+		records = append(records, BorrowInfo{
+			BorrowID:   rec.Borrow_id,
+			UserID:     rec.User_id,
+			UserType:   rec.User_type,
+			BookID:     rec.Book_id,
+			BookType:   m.libraries[rec.Book_id].Book_name,
+			Barcode:    rec.Barcode,
+			BorrowDate: rec.Borrow_date,
+			ReturnDate: rec.Return_date,
+			DueDate:    rec.Due_date,
+			borrowedAt: borrowedAt,
+		})
+	}
+	return memoryPage(records, f.PageParams), nil
+}
+
+func (m *memoryBorrows) ListByUser(ctx context.Context, userType string, userID int) ([]BorrowInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var records []BorrowInfo
+	for _, rec := range slices.Backward(m.borrows) {
+		if rec.User_type != userType || rec.User_id != userID {
+			continue
+		}
+		records = append(records, BorrowInfo{
+			BorrowID:   rec.Borrow_id,
+			UserID:     rec.User_id,
+			UserType:   rec.User_type,
+			BookID:     rec.Book_id,
+			BookType:   m.libraries[rec.Book_id].Book_name,
+			Barcode:    rec.Barcode,
+			BorrowDate: rec.Borrow_date,
+			ReturnDate: rec.Return_date,
+			DueDate:    rec.Due_date,
+		})
+	}
+	return records, nil
+}
+
+func (m *memoryBorrows) Return(ctx context.Context, barcode string) (Borrow_records, *Fine, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.borrows, func(rec Borrow_records) bool {
+		return rec.Barcode == barcode && rec.Return_date == ""
+	})
+	if i < 0 {
+		return Borrow_records{Barcode: barcode}, nil, ErrNotFound
+	}
+	now := time.Now()
+	m.borrows[i].Return_date = now.Format(time.RFC3339)
+	c := m.copies[barcode]
+	c.OnLoan = false
+	m.copies[barcode] = c
+	m.recount(c.BookID)
+
+	// charge the days past the due date
+	rec := m.borrows[i]
+	due, err := time.ParseInLocation(time.DateOnly, rec.Due_date, time.Local)
+	if days := daysLate(due, now); err == nil && days > 0 && rec.Fine_per_day > 0 {
+		fine := Fine{FineID: m.id("fines"), BorrowID: rec.Borrow_id, UserID: rec.User_id, UserType: rec.User_type, BookID: rec.Book_id, DaysLate: days, Amount: days * rec.Fine_per_day}
+		fine.Balance, fine.CreatedAt = fine.Amount, now.Format(time.RFC3339)
+		m.fines = append(m.fines, fine)
+		return rec, &fine, nil
+	}
+	return rec, nil, nil
+}
+
+func (m *memoryBorrows) Get(ctx context.Context, id int) (Borrow_records, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.borrows, func(rec Borrow_records) bool { return rec.Borrow_id == id })
+	if i < 0 {
+		return Borrow_records{}, ErrNotFound
+	}
+	return m.borrows[i], nil
+}
+
+func (m *memoryBorrows) Renew(ctx context.Context, rn *BorrowRenewal, days, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.borrows, func(rec Borrow_records) bool { return rec.Borrow_id == rn.BorrowID })
+	if i < 0 {
+		return 0, ErrNotFound
+	}
+	if m.borrows[i].Return_date != "" {
+		return 0, ErrLoanReturned
+	}
+
+	// the copy goes back to the queue when others wait for the book
+	waiting := 0
+	for _, h := range m.holds {
+		if h.BookID == m.borrows[i].Book_id && h.Status == HoldWaiting {
+			waiting++
+		}
+	}
+	if waiting > 0 {
+		return waiting, ErrHoldsPending
+	}
+	renewals := 0
+	for _, r := range m.renewals {
+		if r.BorrowID == rn.BorrowID {
+			renewals++
+		}
+	}
+	if renewals >= limit {
+		return renewals, ErrRenewalLimit
+	}
+
+	// extend the loan from its due date
+	now := time.Now()
+	from, err := time.ParseInLocation(time.DateOnly, m.borrows[i].Due_date, time.Local)
+	if err != nil {
+		from = now
+	}
+	rn.RenewalID = m.id("borrow_renewals")
+	rn.OldDueDate = m.borrows[i].Due_date
+	rn.NewDueDate = dueDate(from, days).Format(time.DateOnly)
+	rn.RenewedAt = now.Format(time.RFC3339)
+	m.borrows[i].Due_date = rn.NewDueDate
+	m.renewals = append(m.renewals, *rn)
+	return renewals + 1, nil
+}
+
+func (m *memoryBorrows) Renewals(ctx context.Context, borrowID int) ([]BorrowRenewal, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var renewals []BorrowRenewal
+	for _, rn := range m.renewals {
+		if rn.BorrowID == borrowID {
+			renewals = append(renewals, rn)
+		}
+	}
+	return renewals, nil
+}
+
+func (m *memoryBorrows) Overdue(ctx context.Context, at time.Time, userType string, userID int) ([]OverdueLoan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var loans []OverdueLoan
+	for _, rec := range m.borrows {
+		due, err := time.ParseInLocation(time.DateOnly, rec.Due_date, time.Local)
+		switch {
+		case rec.Return_date != "", err != nil, daysLate(due, at) == 0,
+			userType != "" && rec.User_type != userType,
+			userID != 0 && rec.User_id != userID:
+			continue
+		}
+		loan := OverdueLoan{BorrowInfo: BorrowInfo{
+			BorrowID:   rec.Borrow_id,
+			UserID:     rec.User_id,
+			UserType:   rec.User_type,
+			BookID:     rec.Book_id,
+			BookType:   m.libraries[rec.Book_id].Book_name,
+			Barcode:    rec.Barcode,
+			BorrowDate: rec.Borrow_date,
+			DueDate:    rec.Due_date,
+		}, DaysLate: daysLate(due, at)}
+		loan.AccruedFine = loan.DaysLate * rec.Fine_per_day
+		loans = append(loans, loan)
+	}
+	// like the SQL version, loans due the same day keep borrow order
+	slices.SortFunc(loans, func(a, b OverdueLoan) int {
+		return cmp.Or(strings.Compare(a.DueDate, b.DueDate), cmp.Compare(a.BorrowID, b.BorrowID))
+	})
+	return loans, nil
+}
+
+// Holds
+
+// open reports whether the hold is waiting or ready
+func (h Hold) open() bool {
+	return h.Status == HoldWaiting || h.Status == HoldReady
+}
+
+// release takes the copy with barcode off hold, the caller holds the lock
+func (s *memoryStore) release(barcode string) {
+	if c, ok := s.copies[barcode]; ok {
+		c.OnHold = false
+		s.copies[barcode] = c
+	}
+}
+
+// hold returns the hold at index i with its queue position, the caller holds the lock
+func (s *memoryStore) hold(i int) Hold {
+	h := s.holds[i]
+	h.Position = 0
+	if h.Status == HoldWaiting {
+		for _, q := range s.holds[:i+1] {
+			if q.BookID == h.BookID && q.Status == HoldWaiting {
+				h.Position++
+			}
+		}
+	}
+	return h
+}
+
+type memoryHolds struct{ *memoryStore }
+
+func (m *memoryHolds) Place(ctx context.Context, h *Hold) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.libraries[h.BookID]; !ok {
+		return ErrNotFound
+	}
+	if !m.borrowerExists(h.UserType, h.UserID) {
+		return ErrBorrowerNotFound
+	}
+	if slices.ContainsFunc(m.holds, func(q Hold) bool {
+		return q.BookID == h.BookID && q.UserType == h.UserType && q.UserID == h.UserID && q.open()
+	}) {
+		return ErrDuplicateHold
+	}
+	m.holds = append(m.holds, Hold{
+		HoldID:   m.id("holds"),
+		BookID:   h.BookID,
+		UserID:   h.UserID,
+		UserType: h.UserType,
+		Status:   HoldWaiting,
+		PlacedAt: time.Now().Format(time.RFC3339),
+	})
+	*h = m.hold(len(m.holds) - 1)
+	return nil
+}
+
+func (m *memoryHolds) Get(ctx context.Context, id int) (Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.holds, func(h Hold) bool { return h.HoldID == id })
+	if i < 0 {
+		return Hold{}, ErrNotFound
+	}
+	return m.hold(i), nil
+}
+
+func (m *memoryHolds) List(ctx context.Context, f HoldFilter) ([]Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var holds []Hold
+	for i, h := range m.holds {
+		switch {
+		case f.BookID != 0 && h.BookID != f.BookID,
+			f.UserType != "" && h.UserType != f.UserType,
+			f.UserID != 0 && h.UserID != f.UserID,
+			f.Open && !h.open():
+			continue
+		}
+		holds = append(holds, m.hold(i))
+	}
+	return holds, nil
+}
+
+func (m *memoryHolds) Cancel(ctx context.Context, id int, now time.Time) (Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.holds, func(h Hold) bool { return h.HoldID == id })
+	if i < 0 {
+		return Hold{}, ErrNotFound
+	}
+	h := m.holds[i]
+	if !h.open() {
+		return m.hold(i), ErrHoldClosed
+	}
+	// release the copy kept for a ready hold
+	if h.Status == HoldReady {
+		m.release(h.Barcode)
+		m.recount(h.BookID)
+	}
+	m.holds[i].Status, m.holds[i].ClosedAt = HoldCancelled, now.Format(time.RFC3339)
+	return m.hold(i), nil
+}
+
+func (m *memoryHolds) Assign(ctx context.Context, bookID int, now time.Time, pickup time.Duration) ([]Hold, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// lendable copies in copy id order, like the SQL repository
+	var free []BookCopy
+	for _, c := range m.copies {
+		if c.BookID == bookID && c.Lendable() {
+			free = append(free, c)
+		}
+	}
+	slices.SortFunc(free, func(a, b BookCopy) int { return cmp.Compare(a.CopyID, b.CopyID) })
+
+	// pair the oldest waiting hold with a lendable copy until either runs out
+	var ready []Hold
+	for i, h := range m.holds {
+		if len(free) == 0 {
+			break
+		}
+		if h.BookID != bookID || h.Status != HoldWaiting {
+			continue
+		}
+		c := free[0]
+		free = free[1:]
+		c.OnHold = true
+		m.copies[c.Barcode] = c
+		m.holds[i].Status, m.holds[i].Barcode = HoldReady, c.Barcode
+		m.holds[i].ReadyAt, m.holds[i].ExpiresAt = now.Format(time.RFC3339), now.Add(pickup).Format(time.RFC3339)
+		ready = append(ready, m.hold(i))
+	}
+	if len(ready) > 0 {
+		m.recount(bookID)
+	}
+	return ready, nil
+}
+
+func (m *memoryHolds) Expire(ctx context.Context, now time.Time) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var books []int
+	for i, h := range m.holds {
+		expires, err := time.Parse(time.RFC3339, h.ExpiresAt)
+		if h.Status != HoldReady || err != nil || !expires.Before(now) {
+			continue
+		}
+		m.holds[i].Status, m.holds[i].ClosedAt = HoldExpired, now.Format(time.RFC3339)
+		m.release(h.Barcode)
+		if !slices.Contains(books, h.BookID) {
+			books = append(books, h.BookID)
+		}
+	}
+	for _, bookID := range books {
+		m.recount(bookID)
+	}
+	return books, nil
+}
+
+// Loan policies
+
+type memoryLoanPolicies struct{ *memoryStore }
+
+func (m *memoryLoanPolicies) List(ctx context.Context) ([]LoanPolicy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	policies := slices.Collect(maps.Values(m.policies))
+	slices.SortFunc(policies, func(a, b LoanPolicy) int {
+		return cmp.Or(strings.Compare(a.UserType, b.UserType), strings.Compare(a.Category, b.Category))
+	})
+	return policies, nil
+}
+
+func (m *memoryLoanPolicies) Get(ctx context.Context, userType, category string) (LoanPolicy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.policies[[2]string{userType, category}]
+	if !ok {
+		return p, ErrNotFound
+	}
+	return p, nil
+}
+
+func (m *memoryLoanPolicies) Put(ctx context.Context, p LoanPolicy) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]string{p.UserType, p.Category}
+	_, exists := m.policies[key]
+	m.policies[key] = p
+	return !exists, nil
+}
+
+func (m *memoryLoanPolicies) Delete(ctx context.Context, userType, category string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]string{userType, category}
+	if _, ok := m.policies[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.policies, key)
+	return nil
+}
+
+// Fines
+
+type memoryFines struct{ *memoryStore }
+
+func (m *memoryFines) List(ctx context.Context, f FineFilter) ([]Fine, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var fines []Fine
+	for _, fine := range slices.Backward(m.fines) {
+		switch {
+		case f.UserType != "" && fine.UserType != f.UserType,
+			f.UserID != 0 && fine.UserID != f.UserID,
+			f.Unpaid && fine.Balance == 0:
+			continue
+		}
+		fine.Transactions = nil
+		fines = append(fines, fine)
+	}
+	return fines, nil
+}
+
+func (m *memoryFines) Get(ctx context.Context, id int) (Fine, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.fines, func(f Fine) bool { return f.FineID == id })
+	if i < 0 {
+		return Fine{}, ErrNotFound
+	}
+	fine := m.fines[i]
+	fine.Transactions = slices.Clone(fine.Transactions)
+	return fine, nil
+}
+
+func (m *memoryFines) Settle(ctx context.Context, t *FineTransaction) (Fine, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.fines, func(f Fine) bool { return f.FineID == t.FineID })
+	if i < 0 {
+		return Fine{}, ErrNotFound
+	}
+	fine := &m.fines[i]
+	if fine.Balance == 0 {
+		return *fine, ErrFineSettled
+	}
+	if t.Amount > fine.Balance {
+		return *fine, ErrExceedsBalance
+	}
+	if t.Amount == 0 {
+		t.Amount = fine.Balance
+	}
+	t.TransactionID, t.RecordedAt = m.id("fine_transactions"), time.Now().Format(time.RFC3339)
+	if t.Kind == FineWaiver {
+		fine.Waived += t.Amount
+	} else {
+		fine.Paid += t.Amount
+	}
+	fine.Balance -= t.Amount
+	fine.Transactions = append(fine.Transactions, *t)
+	result := *fine
+	result.Transactions = slices.Clone(fine.Transactions)
+	return result, nil
+}
+
+func (m *memoryFines) Balance(ctx context.Context, userType string, userID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	balance := 0
+	for _, fine := range m.fines {
+		if fine.UserType == userType && fine.UserID == userID {
+			balance += fine.Balance
+		}
+	}
+	return balance, nil
+}
+
+// Users
+
+type memoryUsers struct{ *memoryStore }
+
+func (m *memoryUsers) Create(ctx context.Context, u *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.users {
+		if other.Email == u.Email {
+			return ErrDuplicateEmail
+		}
+	}
+	u.ID = m.id("users")
+	u.CreatedAt = time.Now().Format(time.RFC3339)
+	m.users[u.ID] = *u
+	return nil
+}
+
+func (m *memoryUsers) List(ctx context.Context) ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := slices.Collect(maps.Values(m.users))
+	slices.SortFunc(users, func(a, b User) int { return cmp.Compare(a.ID, b.ID) })
+	return users, nil
+}
+
+func (m *memoryUsers) Get(ctx context.Context, id int) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return u, ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *memoryUsers) GetByEmail(ctx context.Context, email string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+// update applies change to the account id under the lock
+func (m *memoryUsers) update(id int, change func(u *User) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := change(&u); err != nil {
+		return err
+	}
+	m.users[id] = u
+	return nil
+}
+
+func (m *memoryUsers) SetPassword(ctx context.Context, id int, hash string) error {
+	return m.update(id, func(u *User) error {
+		u.PasswordHash = hash
+		return nil
+	})
+}
+
+func (m *memoryUsers) SetRole(ctx context.Context, id int, role string) error {
+	return m.update(id, func(u *User) error {
+		u.Role = role
+		return nil
+	})
+}
+
+func (m *memoryUsers) Disable(ctx context.Context, id int) error {
+	return m.update(id, func(u *User) error {
+		u.Disabled = true
+		return nil
+	})
+}
+
+func (m *memoryUsers) Link(ctx context.Context, id int, studentID, lecturerID *int) error {
+	return m.update(id, func(u *User) error {
+		for _, other := range m.users {
+			if other.ID != id && ((studentID != nil && other.StudentID == *studentID) || (lecturerID != nil && other.LecturerID == *lecturerID)) {
+				return ErrAlreadyLinked
+			}
+		}
+		u.StudentID, u.LecturerID = 0, 0
+		if studentID != nil {
+			u.StudentID = *studentID
+		}
+		if lecturerID != nil {
+			u.LecturerID = *lecturerID
+		}
+		return nil
+	})
+}
+
+func (m *memoryUsers) SetTOTPSecret(ctx context.Context, id int, secret string) error {
+	return m.update(id, func(u *User) error {
+		u.TOTPSecret = secret
+		return nil
+	})
+}
+
+func (m *memoryUsers) EnableTOTP(ctx context.Context, id int, recoveryHashes []string) error {
+	return m.update(id, func(u *User) error {
+		u.TOTPEnabled = true
+		m.recovery[id] = map[string]bool{}
+		for _, hash := range recoveryHashes {
+			m.recovery[id][hash] = false
+		}
+		return nil
+	})
+}
+
+func (m *memoryUsers) ResetTOTP(ctx context.Context, id int) error {
+	return m.update(id, func(u *User) error {
+		u.TOTPSecret, u.TOTPEnabled = "", false
+		delete(m.recovery, id)
+		return nil
+	})
+}
+
+func (m *memoryUsers) UseRecoveryCode(ctx context.Context, id int, hash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	used, ok := m.recovery[id][hash]
+	if !ok || used {
+		return false, nil
+	}
+	m.recovery[id][hash] = true
+	return true, nil
+}
+
+// API keys
+
+// memoryAPIKey is a stored key with the hash of its secret
+type memoryAPIKey struct {
+	APIKey
+	hash string
+}
+
+type memoryAPIKeys struct{ *memoryStore }
+
+func (m *memoryAPIKeys) Create(ctx context.Context, k *APIKey, secretHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k.ID = m.id("api_keys")
+	k.CreatedAt = time.Now().Format(time.RFC3339)
+	stored := *k
+	stored.Key = ""
+	m.apiKeys[k.ID] = memoryAPIKey{APIKey: stored, hash: secretHash}
+	return nil
+}
+
+func (m *memoryAPIKeys) List(ctx context.Context) ([]APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []APIKey
+	for _, k := range m.apiKeys {
+		keys = append(keys, k.APIKey)
+	}
+	slices.SortFunc(keys, func(a, b APIKey) int { return cmp.Compare(a.ID, b.ID) })
+	return keys, nil
+}
+
+func (m *memoryAPIKeys) Active(ctx context.Context, keyID string) (APIKey, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.apiKeys {
+		if k.KeyID == keyID && k.RevokedAt == "" {
+			return k.APIKey, k.hash, nil
+		}
+	}
+	return APIKey{KeyID: keyID}, "", ErrNotFound
+}
+
+func (m *memoryAPIKeys) Touch(ctx context.Context, id int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.apiKeys[id]; ok {
+		k.LastUsedAt = at.Format(time.RFC3339)
+		m.apiKeys[id] = k
+	}
+	return nil
+}
+
+func (m *memoryAPIKeys) Revoke(ctx context.Context, id int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.apiKeys[id]
+	if !ok || k.RevokedAt != "" {
+		return ErrNotFound
+	}
+	k.RevokedAt = at.Format(time.RFC3339)
+	m.apiKeys[id] = k
+	return nil
+}
+
+// Courses
+
+type memoryCourses struct{ *memoryStore }
+
+func (m *memoryCourses) ListByStudent(ctx context.Context, studentID int) ([]Course, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.coursesWhere(func(c Course) bool { return m.enrolled[[2]int{c.ID, studentID}] }), nil
+}
+
+func (m *memoryCourses) ListByLecturer(ctx context.Context, lecturerID int) ([]Course, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.coursesWhere(func(c Course) bool { return c.LecturerID == lecturerID }), nil
+}
+
+// coursesWhere returns the matching courses ordered by code, the caller holds the lock
+func (m *memoryCourses) coursesWhere(match func(Course) bool) []Course {
+	var courses []Course
+	for _, c := range m.courses {
+		if match(c) {
+			courses = append(courses, c)
+		}
+	}
+	slices.SortFunc(courses, func(a, b Course) int { return strings.Compare(a.Code, b.Code) })
+	return courses
+}
+
+// Search
+
+type memorySearch struct{ *memoryStore }
+
+func (m *memorySearch) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var results []SearchResult
+	add := func(typ string, id int, title, detail string, score float64) {
+		if score > 0 {
+			results = append(results, SearchResult{Type: typ, ID: id, Title: title, Detail: detail, Score: score})
+		}
+	}
+	if slices.Contains(q.Types, SearchBook) {
+		for _, b := range m.libraries {
+			add(SearchBook, b.Book_id, cmp.Or(b.Title, b.Book_name), b.Author, bookScore(q.Terms, b))
+		}
+	}
+	if slices.Contains(q.Types, SearchStudent) {
+		for _, st := range m.students {
+			add(SearchStudent, st.Id, st.Name, st.Email, studentScore(q.Terms, st))
+		}
+	}
+	if slices.Contains(q.Types, SearchLecturer) {
+		for _, l := range m.lecturers {
+			add(SearchLecturer, l.ID, l.Name, l.Designation, lecturerScore(q.Terms, l))
+		}
+	}
+	return rankSearchResults(results, q.Limit), nil
+}
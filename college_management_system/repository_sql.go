@@ -0,0 +1,1743 @@
+package collegemanagementsystem
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// NewSQLRepositories returns the repositories backed by the SQL database (MySQL, PostgreSQL or SQLite)
+func NewSQLRepositories(m *MySQLInstance) Repositories {
+	return Repositories{
+		Students:     &sqlStudents{db: m.db},
+		Lecturers:    &sqlLecturers{db: m.db},
+		Libraries:    &sqlLibraries{db: m.db},
+		Copies:       &sqlCopies{db: m.db},
+		Borrows:      &sqlBorrows{db: m.db},
+		Fines:        &sqlFines{db: m.db},
+		Holds:        &sqlHolds{db: m.db},
+		LoanPolicies: &sqlLoanPolicies{db: m.db},
+		Search:       &sqlSearch{db: m.db},
+		Users:        &sqlUsers{db: m.db},
+		APIKeys:      &sqlAPIKeys{db: m.db},
+		Courses:      &sqlCourses{db: m.db},
+	}
+}
+
+// checkAffected turns an update or delete that matched no row into ErrNotFound
+func checkAffected(res sql.Result) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// sqlList is the FROM and WHERE of a paged list query
+type sqlList struct {
+	columns  string
+	from     string
+	idColumn string
+	fields   map[string]SortField
+	where    []string
+	args     []any
+}
+
+// filter adds a condition to the WHERE clause
+func (l *sqlList) filter(cond string, args ...any) {
+	l.where = append(l.where, cond)
+	l.args = append(l.args, args...)
+}
+
+func (l *sqlList) whereClause(extra ...string) string {
+	conds := append(slices.Clone(l.where), extra...)
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// listPage counts the filtered rows and reads one page of them.
+// One row more than the limit is read to find out whether another page follows.
+func listPage[T any](ctx context.Context, db *DB, l sqlList, p PageParams, scan func(*sql.Rows) (T, error)) (ListResult[T], error) {
+	var res ListResult[T]
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+l.from+l.whereClause(), l.args...).Scan(&res.Total); err != nil {
+		return res, err
+	}
+
+	column := l.fields[p.Sort].Column
+	// a backward page is read in reverse order and flipped afterwards
+	desc := p.Desc != p.backward()
+	dir, cmp := "ASC", ">"
+	if desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	args := slices.Clone(l.args)
+	var keyset []string
+	if c := p.Cursor; c != nil {
+		keyset = append(keyset, fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", column, cmp, column, l.idColumn, cmp))
+		args = append(args, c.Value, c.Value, c.ID)
+	}
+	query := "SELECT " + l.columns + " FROM " + l.from + l.whereClause(keyset...) +
+		fmt.Sprintf(" ORDER BY %s %s, %s %s LIMIT ? OFFSET ?", column, dir, l.idColumn, dir)
+	args = append(args, p.Limit+1, p.Offset)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return res, err
+		}
+		res.Items = append(res.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return res, err
+	}
+
+	if len(res.Items) > p.Limit {
+		res.Items, res.More = res.Items[:p.Limit], true
+	}
+	if p.backward() {
+		slices.Reverse(res.Items)
+	}
+	return res, nil
+}
+
+// versionedUpdate runs an UPDATE that bumps version, adding the version check when version is not 0.
+// When no row changed it tells ErrNotFound from ErrVersionConflict.
+func versionedUpdate(ctx context.Context, db *DB, table, idColumn string, id, version int, query string, args ...any) error {
+	query += " WHERE " + idColumn + "=?"
+	args = append(args, id)
+	if version != 0 {
+		query += " AND version=?"
+		args = append(args, version)
+	}
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != ErrNotFound {
+		return err
+	}
+	var exists int
+	err = db.QueryRowContext(ctx, "SELECT 1 FROM "+table+" WHERE "+idColumn+"=?", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
+
+// Students
+
+type sqlStudents struct {
+	db *DB
+}
+
+func (m *sqlStudents) Create(ctx context.Context, s *Student) error {
+	id, err := m.db.InsertID(ctx, "INSERT INTO students (name , age , email , dept) VALUES (? , ? , ? , ?)", "id", s.Name, s.Age, s.Email, s.Dept)
+	if err != nil {
+		return err
+	}
+	s.Id = int(id)
+	s.Version = 1
+	return nil
+}
+
+func (m *sqlStudents) List(ctx context.Context, f StudentFilter) (ListResult[Student], error) {
+	l := sqlList{columns: "id , name , age , email , dept , email_verified , version", from: "students", idColumn: "id", fields: studentSortFields}
+	if f.Dept != "" {
+		l.filter("dept=?", f.Dept)
+	}
+	return listPage(ctx, m.db, l, f.PageParams, func(rows *sql.Rows) (Student, error) {
+		var s Student
+		err := rows.Scan(&s.Id, &s.Name, &s.Age, &s.Email, &s.Dept, &s.EmailVerified, &s.Version)
+		return s, err
+	})
+}
+
+func (m *sqlStudents) Get(ctx context.Context, id int) (Student, error) {
+	var s Student
+	err := m.db.QueryRowContext(ctx, "SELECT id ,name , age , email , dept , email_verified , version FROM students WHERE id=?", id).Scan(&s.Id, &s.Name, &s.Age, &s.Email, &s.Dept, &s.EmailVerified, &s.Version)
+	if err == sql.ErrNoRows {
+		return s, ErrNotFound
+	}
+	return s, err
+}
+
+func (m *sqlStudents) Update(ctx context.Context, s *Student) error {
+	// a changed email has to be verified again
+	err := versionedUpdate(ctx, m.db, "students", "id", s.Id, s.Version,
+		"UPDATE students SET email_verified = CASE WHEN email=? THEN email_verified ELSE FALSE END , name=? , age=? , email=? , dept=? , version=version+1",
+		s.Email, s.Name, s.Age, s.Email, s.Dept)
+	if err != nil {
+		return err
+	}
+	return m.db.QueryRowContext(ctx, "SELECT email_verified , version FROM students WHERE id=?", s.Id).Scan(&s.EmailVerified, &s.Version)
+}
+
+func (m *sqlStudents) Delete(ctx context.Context, id int) error {
+	res, err := m.db.ExecContext(ctx, "DELETE FROM students WHERE id=?", id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (m *sqlStudents) VerifyEmail(ctx context.Context, id int, email string) error {
+	res, err := m.db.ExecContext(ctx, "UPDATE students SET email_verified=TRUE , version=version+1 WHERE id=? AND email=?", id, email)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// Lecturers
+
+type sqlLecturers struct {
+	db *DB
+}
+
+func (m *sqlLecturers) Create(ctx context.Context, l *Lecturer) error {
+	id, err := m.db.InsertID(ctx, "INSERT INTO lecturers (name , email , age , designation) VALUES (? , ? , ? , ? )", "id", l.Name, l.Email, l.Age, l.Designation)
+	if err != nil {
+		return err
+	}
+	l.ID = int(id)
+	l.Version = 1
+	return nil
+}
+
+func (m *sqlLecturers) List(ctx context.Context, f LecturerFilter) (ListResult[Lecturer], error) {
+	l := sqlList{columns: "id , name , age , email , designation , email_verified , version", from: "lecturers", idColumn: "id", fields: lecturerSortFields}
+	if f.Designation != "" {
+		l.filter("designation=?", f.Designation)
+	}
+	return listPage(ctx, m.db, l, f.PageParams, func(rows *sql.Rows) (Lecturer, error) {
+		var l Lecturer
+		err := rows.Scan(&l.ID, &l.Name, &l.Age, &l.Email, &l.Designation, &l.EmailVerified, &l.Version)
+		return l, err
+	})
+}
+
+func (m *sqlLecturers) Get(ctx context.Context, id int) (Lecturer, error) {
+	var l Lecturer
+	err := m.db.QueryRowContext(ctx, "SELECT id , name , age , email  , designation , email_verified , version FROM lecturers WHERE  id=?", id).Scan(&l.ID, &l.Name, &l.Age, &l.Email, &l.Designation, &l.EmailVerified, &l.Version)
+	if err == sql.ErrNoRows {
+		return l, ErrNotFound
+	}
+	return l, err
+}
+
+func (m *sqlLecturers) Update(ctx context.Context, l *Lecturer) error {
+	// a changed email has to be verified again
+	err := versionedUpdate(ctx, m.db, "lecturers", "id", l.ID, l.Version,
+		"UPDATE lecturers SET email_verified = CASE WHEN email=? THEN email_verified ELSE FALSE END , name=?,email=?,age=?,designation=? , version=version+1",
+		l.Email, l.Name, l.Email, l.Age, l.Designation)
+	if err != nil {
+		return err
+	}
+	return m.db.QueryRowContext(ctx, "SELECT email_verified , version FROM lecturers WHERE id=?", l.ID).Scan(&l.EmailVerified, &l.Version)
+}
+
+func (m *sqlLecturers) Delete(ctx context.Context, id int) error {
+	res, err := m.db.ExecContext(ctx, "DELETE FROM lecturers WHERE id=?", id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (m *sqlLecturers) VerifyEmail(ctx context.Context, id int, email string) error {
+	res, err := m.db.ExecContext(ctx, "UPDATE lecturers SET email_verified=TRUE , version=version+1 WHERE id=? AND email=?", id, email)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// Library
+
+type sqlLibraries struct {
+	db *DB
+}
+
+func (m *sqlLibraries) Create(ctx context.Context, b *Library) error {
+	// a new book has no copies yet
+	id, err := m.db.InsertID(ctx, "INSERT INTO libraries (book_name , title , author , category , available_copies) VALUES ( ? , ? , ? , ? , 0)", "book_id", b.Book_name, b.Title, b.Author, b.Category)
+	if err != nil {
+		return err
+	}
+	b.Book_id = int(id)
+	b.Available_copies = 0
+	b.Version = 1
+	return nil
+}
+
+func (m *sqlLibraries) Get(ctx context.Context, id int) (Library, error) {
+	var b Library
+	err := m.db.QueryRowContext(ctx, "SELECT book_id ,book_name ,  title , author , category , available_copies , version FROM libraries WHERE  book_id=?", id).Scan(&b.Book_id, &b.Book_name, &b.Title, &b.Author, &b.Category, &b.Available_copies, &b.Version)
+	if err == sql.ErrNoRows {
+		return b, ErrNotFound
+	}
+	return b, err
+}
+
+func (m *sqlLibraries) Update(ctx context.Context, b *Library) error {
+	err := versionedUpdate(ctx, m.db, "libraries", "book_id", b.Book_id, b.Version,
+		"UPDATE libraries SET book_name=? , title=? , author=? , category=? , version=version+1",
+		b.Book_name, b.Title, b.Author, b.Category)
+	if err != nil {
+		return err
+	}
+	return m.db.QueryRowContext(ctx, "SELECT available_copies , version FROM libraries WHERE book_id=?", b.Book_id).Scan(&b.Available_copies, &b.Version)
+}
+
+func (m *sqlLibraries) Delete(ctx context.Context, id int) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// delete borrow_records and holds first, they reference the copies, and the copies reference the book
+	if _, err := tx.ExecContext(ctx, "DELETE FROM borrow_renewals WHERE borrow_id IN (SELECT borrow_id FROM borrow_records WHERE book_id=?)", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM borrow_records WHERE book_id=?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM holds WHERE book_id=?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM book_copies WHERE book_id=?", id); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM libraries WHERE book_id=?", id)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Book copies
+
+// lendableCopy matches the copies counted in available_copies, see BookCopy.Lendable
+const lendableCopy = "on_loan=FALSE AND on_hold=FALSE AND copy_condition NOT IN ('damaged' , 'lost')"
+
+// recountCopies derives available_copies of a book from its copies and bumps the book version
+func recountCopies(ctx context.Context, tx *Tx, bookID int) error {
+	_, err := tx.ExecContext(ctx, "UPDATE libraries SET available_copies = (SELECT COUNT(*) FROM book_copies WHERE book_id=? AND "+lendableCopy+") , version=version+1 WHERE book_id=?", bookID, bookID)
+	return err
+}
+
+type sqlCopies struct {
+	db *DB
+}
+
+func (m *sqlCopies) Create(ctx context.Context, c *BookCopy) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, "SELECT 1 FROM libraries WHERE book_id=?", c.BookID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	// the UNIQUE index catches a concurrent insert of the same barcode
+	err = tx.QueryRowContext(ctx, "SELECT 1 FROM book_copies WHERE barcode=?", c.Barcode).Scan(&exists)
+	if err == nil {
+		return ErrDuplicateBarcode
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	id, err := tx.InsertID(ctx, "INSERT INTO book_copies (book_id , barcode , copy_condition , shelf_location , on_loan , on_hold) VALUES (? , ? , ? , ? , FALSE , FALSE)", "copy_id", c.BookID, c.Barcode, c.Condition, c.ShelfLocation)
+	if err != nil {
+		return err
+	}
+	c.CopyID = int(id)
+	if err := recountCopies(ctx, tx, c.BookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *sqlCopies) List(ctx context.Context, bookID int) ([]BookCopy, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT copy_id , book_id , barcode , copy_condition , shelf_location , on_loan , on_hold FROM book_copies WHERE book_id=? ORDER BY barcode", bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var copies []BookCopy
+	for rows.Next() {
+		var c BookCopy
+		if err := rows.Scan(&c.CopyID, &c.BookID, &c.Barcode, &c.Condition, &c.ShelfLocation, &c.OnLoan, &c.OnHold); err != nil {
+			return nil, err
+		}
+		copies = append(copies, c)
+	}
+	return copies, rows.Err()
+}
+
+func (m *sqlCopies) Get(ctx context.Context, barcode string) (BookCopy, error) {
+	var c BookCopy
+	err := m.db.QueryRowContext(ctx, "SELECT copy_id , book_id , barcode , copy_condition , shelf_location , on_loan , on_hold FROM book_copies WHERE barcode=?", barcode).Scan(&c.CopyID, &c.BookID, &c.Barcode, &c.Condition, &c.ShelfLocation, &c.OnLoan, &c.OnHold)
+	if err == sql.ErrNoRows {
+		return c, ErrNotFound
+	}
+	return c, err
+}
+
+func (m *sqlCopies) Update(ctx context.Context, c *BookCopy) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE book_copies SET copy_condition=? , shelf_location=? WHERE barcode=?", c.Condition, c.ShelfLocation, c.Barcode)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, "SELECT copy_id , book_id , on_loan , on_hold FROM book_copies WHERE barcode=?", c.Barcode).Scan(&c.CopyID, &c.BookID, &c.OnLoan, &c.OnHold)
+	if err != nil {
+		return err
+	}
+	// a damaged or lost copy can't be collected, its hold waits for another copy
+	if c.OnHold && (c.Condition == CopyDamaged || c.Condition == CopyLost) {
+		if _, err := tx.ExecContext(ctx, "UPDATE holds SET status='waiting' , copy_id=NULL , ready_at=NULL , expires_at=NULL WHERE copy_id=? AND status='ready'", c.CopyID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE book_copies SET on_hold=FALSE WHERE copy_id=?", c.CopyID); err != nil {
+			return err
+		}
+		c.OnHold = false
+	}
+	if err := recountCopies(ctx, tx, c.BookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Borrow_records
+
+type sqlBorrows struct {
+	db *DB
+}
+
+func (m *sqlBorrows) Borrow(ctx context.Context, rec *Borrow_records, limits *LoanLimits) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var onHold bool
+	var category string
+	err = tx.QueryRowContext(ctx, "SELECT c.copy_id , c.book_id , c.on_hold , l.category FROM book_copies c JOIN libraries l ON c.book_id=l.book_id WHERE c.barcode=?", rec.Barcode).Scan(&rec.Copy_id, &rec.Book_id, &onHold, &category)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := m.checkLimits(ctx, tx, rec, category, now, limits); err != nil {
+		return err
+	}
+
+	// the open hold of the borrower on the book is collected, a copy on hold only goes to its holder
+	var holdID int
+	var holdCopy sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT hold_id , copy_id FROM holds WHERE book_id=? AND user_type=? AND user_id=? AND status IN ('waiting' , 'ready')"+m.db.Dialect.ForUpdate(), rec.Book_id, rec.User_type, rec.User_id).Scan(&holdID, &holdCopy)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if onHold && (holdID == 0 || holdCopy.Int64 != int64(rec.Copy_id)) {
+		return ErrCopyOnHold
+	}
+	if holdID != 0 {
+		if _, err := tx.ExecContext(ctx, "UPDATE holds SET status='collected' , closed_at=? WHERE hold_id=?", now, holdID); err != nil {
+			return err
+		}
+		if holdCopy.Valid {
+			if _, err := tx.ExecContext(ctx, "UPDATE book_copies SET on_hold=FALSE WHERE copy_id=?", holdCopy.Int64); err != nil {
+				return err
+			}
+		}
+	}
+
+	// take the copy, the condition stops concurrent borrows from lending it twice
+	res, err := tx.ExecContext(ctx, "UPDATE book_copies SET on_loan=TRUE WHERE copy_id=? AND "+lendableCopy, rec.Copy_id)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return ErrCopyNotAvailable
+	}
+
+	// Insert borrow record
+	var due sql.NullTime
+	if rec.Due_date != "" {
+		if due.Time, err = time.ParseInLocation(time.DateOnly, rec.Due_date, time.Local); err != nil {
+			return err
+		}
+		due.Valid = true
+	}
+	id, err := tx.InsertID(ctx, "INSERT INTO borrow_records(user_id, user_type,book_id , copy_id ,borrow_date , due_date , fine_per_day)VALUES (? , ? , ? , ? , ? , ? , ?)", "borrow_id", rec.User_id, rec.User_type, rec.Book_id, rec.Copy_id, now, due, rec.Fine_per_day)
+	if err != nil {
+		return err
+	}
+	rec.Borrow_id = int(id)
+	rec.Borrow_date, rec.Return_date = now.Format(time.RFC3339), ""
+	if err := recountCopies(ctx, tx, rec.Book_id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockBorrower locks the student or lecturer row of a borrower in tx,
+// it returns ErrBorrowerNotFound when there is none
+func lockBorrower(ctx context.Context, tx *Tx, userType string, userID int) error {
+	table := "students"
+	if userType == "lecturer" {
+		table = "lecturers"
+	}
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM "+table+" WHERE id=?"+tx.Dialect.ForUpdate(), userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrBorrowerNotFound
+	}
+	return err
+}
+
+// checkLimits counts the open loans and owed fines of the borrower in tx and checks them
+// against limits. The borrower's row and open loans are locked first, a concurrent borrow
+// of the same user waits here and then counts the loan made by this one.
+func (m *sqlBorrows) checkLimits(ctx context.Context, tx *Tx, rec *Borrow_records, category string, now time.Time, limits *LoanLimits) error {
+	if err := lockBorrower(ctx, tx, rec.User_type, rec.User_id); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT b.due_date , b.fine_per_day , (SELECT l.category FROM libraries l WHERE l.book_id=b.book_id) FROM borrow_records b WHERE b.user_type=? AND b.user_id=? AND b.return_date IS NULL"+m.db.Dialect.ForUpdate(), rec.User_type, rec.User_id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	limits.OpenLoans, limits.Owed = 0, 0
+	for rows.Next() {
+		var duedate sql.NullTime
+		var finePerDay int
+		var loanCategory string
+		if err := rows.Scan(&duedate, &finePerDay, &loanCategory); err != nil {
+			return err
+		}
+		if loanCategory == category {
+			limits.OpenLoans++
+		}
+		if duedate.Valid {
+			limits.Owed += daysLate(duedate.Time, now) * finePerDay
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if limits.OpenLoans >= limits.MaxLoans {
+		return ErrLoanLimit
+	}
+
+	var balance int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount - paid - waived), 0) FROM fines WHERE user_type=? AND user_id=?", rec.User_type, rec.User_id).Scan(&balance); err != nil {
+		return err
+	}
+	limits.Owed += balance
+	if limits.Owed > limits.FineLimit {
+		return ErrFinesOutstanding
+	}
+	return nil
+}
+
+func (m *sqlBorrows) List(ctx context.Context, f BorrowFilter) (ListResult[BorrowInfo], error) {
+	l := sqlList{
+		columns:  "b.borrow_id, b.user_id, b.user_type, b.book_id, l.book_name, c.barcode, b.borrow_date, b.return_date, b.due_date",
+		from:     "borrow_records b JOIN libraries l ON b.book_id=l.book_id LEFT JOIN book_copies c ON b.copy_id=c.copy_id",
+		idColumn: "b.borrow_id",
+		fields:   borrowSortFields,
+	}
+	if f.UserType != "" {
+		l.filter("b.user_type=?", f.UserType)
+	}
+	if f.Active != nil {
+		if *f.Active {
+			l.filter("b.return_date IS NULL")
+		} else {
+			l.filter("b.return_date IS NOT NULL")
+		}
+	}
+	if !f.From.IsZero() {
+		l.filter("b.borrow_date >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		l.filter("b.borrow_date < ?", f.To.AddDate(0, 0, 1))
+	}
+	return listPage(ctx, m.db, l, f.PageParams, scanBorrowInfo)
+}
+
+func (m *sqlBorrows) ListByUser(ctx context.Context, userType string, userID int) ([]BorrowInfo, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT b.borrow_id, b.user_id, b.user_type, b.book_id, l.book_name, c.barcode, b.borrow_date, b.return_date, b.due_date FROM borrow_records b JOIN libraries l ON b.book_id=l.book_id LEFT JOIN book_copies c ON b.copy_id=c.copy_id WHERE b.user_id=? AND b.user_type=? ORDER BY b.borrow_id DESC", userID, userType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []BorrowInfo
+	for rows.Next() {
+		b, err := scanBorrowInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, b)
+	}
+	return records, rows.Err()
+}
+
+// scanBorrowInfo scans the columns of sqlBorrows.List and ListByUser
+func scanBorrowInfo(rows *sql.Rows) (BorrowInfo, error) {
+	var r BorrowInfo
+	var barcode sql.NullString
+	var borrowdate, returndate, duedate sql.NullTime
+	if err := rows.Scan(&r.BorrowID, &r.UserID, &r.UserType, &r.BookID, &r.BookType, &barcode, &borrowdate, &returndate, &duedate); err != nil {
+		return r, err
+	}
+	r.Barcode = barcode.String
+	if borrowdate.Valid {
+		r.borrowedAt = borrowdate.Time
+		r.BorrowDate = borrowdate.Time.Format(time.RFC3339)
+	}
+	if returndate.Valid {
+		r.ReturnDate = returndate.Time.Format(time.RFC3339)
+	}
+	if duedate.Valid {
+		r.DueDate = duedate.Time.Format(time.DateOnly)
+	}
+	return r, nil
+}
+
+func (m *sqlBorrows) Return(ctx context.Context, barcode string) (Borrow_records, *Fine, error) {
+	rec := Borrow_records{Barcode: barcode}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return rec, nil, err
+	}
+	defer tx.Rollback()
+
+	// find the open loan of the copy
+	var borrowdate, duedate sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT b.borrow_id , b.user_id , b.user_type , b.book_id , b.copy_id , b.borrow_date , b.due_date , b.fine_per_day FROM borrow_records b JOIN book_copies c ON b.copy_id=c.copy_id WHERE c.barcode=? AND b.return_date IS NULL ORDER BY b.borrow_id LIMIT 1"+m.db.Dialect.ForUpdate(), barcode).
+		Scan(&rec.Borrow_id, &rec.User_id, &rec.User_type, &rec.Book_id, &rec.Copy_id, &borrowdate, &duedate, &rec.Fine_per_day)
+	if err == sql.ErrNoRows {
+		return rec, nil, ErrNotFound
+	}
+	if err != nil {
+		return rec, nil, err
+	}
+
+	// close it, a concurrent return of the same loan matches no row here
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, "UPDATE borrow_records SET return_date=? WHERE borrow_id=? AND return_date IS NULL", now, rec.Borrow_id)
+	if err != nil {
+		return rec, nil, err
+	}
+	if err := checkAffected(res); err != nil {
+		return rec, nil, err
+	}
+
+	// give the copy back
+	if _, err := tx.ExecContext(ctx, "UPDATE book_copies SET on_loan=FALSE WHERE copy_id=?", rec.Copy_id); err != nil {
+		return rec, nil, err
+	}
+	if err := recountCopies(ctx, tx, rec.Book_id); err != nil {
+		return rec, nil, err
+	}
+	if borrowdate.Valid {
+		rec.Borrow_date = borrowdate.Time.Format(time.RFC3339)
+	}
+	rec.Return_date = now.Format(time.RFC3339)
+
+	// charge the days past the due date
+	var fine *Fine
+	if duedate.Valid {
+		rec.Due_date = duedate.Time.Format(time.DateOnly)
+		if days := daysLate(duedate.Time, now); days > 0 && rec.Fine_per_day > 0 {
+			fine = &Fine{BorrowID: rec.Borrow_id, UserID: rec.User_id, UserType: rec.User_type, BookID: rec.Book_id, DaysLate: days, Amount: days * rec.Fine_per_day}
+			fine.Balance, fine.CreatedAt = fine.Amount, now.Format(time.RFC3339)
+			id, err := tx.InsertID(ctx, "INSERT INTO fines(borrow_id , user_id , user_type , book_id , days_late , amount , created_at)VALUES (? , ? , ? , ? , ? , ? , ?)", "fine_id",
+				fine.BorrowID, fine.UserID, fine.UserType, fine.BookID, fine.DaysLate, fine.Amount, now)
+			if err != nil {
+				return rec, nil, err
+			}
+			fine.FineID = int(id)
+		}
+	}
+	return rec, fine, tx.Commit()
+}
+
+func (m *sqlBorrows) Get(ctx context.Context, id int) (Borrow_records, error) {
+	var rec Borrow_records
+	var copyID sql.NullInt64
+	var barcode sql.NullString
+	var borrowdate, returndate, duedate sql.NullTime
+	err := m.db.QueryRowContext(ctx, "SELECT b.borrow_id , b.user_id , b.user_type , b.book_id , b.copy_id , c.barcode , b.borrow_date , b.return_date , b.due_date , b.fine_per_day FROM borrow_records b LEFT JOIN book_copies c ON b.copy_id=c.copy_id WHERE b.borrow_id=?", id).
+		Scan(&rec.Borrow_id, &rec.User_id, &rec.User_type, &rec.Book_id, &copyID, &barcode, &borrowdate, &returndate, &duedate, &rec.Fine_per_day)
+	if err == sql.ErrNoRows {
+		return rec, ErrNotFound
+	}
+	if err != nil {
+		return rec, err
+	}
+	rec.Copy_id, rec.Barcode = int(copyID.Int64), barcode.String
+	rec.Borrow_date, rec.Return_date = formatNullTime(borrowdate), formatNullTime(returndate)
+	if duedate.Valid {
+		rec.Due_date = duedate.Time.Format(time.DateOnly)
+	}
+	return rec, nil
+}
+
+func (m *sqlBorrows) Renew(ctx context.Context, rn *BorrowRenewal, days, limit int) (int, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// lock the loan, concurrent renewals then count each other
+	var bookID int
+	var returndate, duedate sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT book_id , return_date , due_date FROM borrow_records WHERE borrow_id=?"+m.db.Dialect.ForUpdate(), rn.BorrowID).Scan(&bookID, &returndate, &duedate)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if returndate.Valid {
+		return 0, ErrLoanReturned
+	}
+
+	// the copy goes back to the queue when others wait for the book
+	var waiting int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM holds WHERE book_id=? AND status='waiting'", bookID).Scan(&waiting); err != nil {
+		return 0, err
+	}
+	if waiting > 0 {
+		return waiting, ErrHoldsPending
+	}
+	var renewals int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM borrow_renewals WHERE borrow_id=?", rn.BorrowID).Scan(&renewals); err != nil {
+		return 0, err
+	}
+	if renewals >= limit {
+		return renewals, ErrRenewalLimit
+	}
+
+	// extend the loan from its due date
+	now := time.Now()
+	from := now
+	rn.OldDueDate = ""
+	if duedate.Valid {
+		y, mo, d := duedate.Time.Date()
+		from = time.Date(y, mo, d, 0, 0, 0, 0, time.Local)
+		rn.OldDueDate = from.Format(time.DateOnly)
+	}
+	due := dueDate(from, days)
+	if _, err := tx.ExecContext(ctx, "UPDATE borrow_records SET due_date=? WHERE borrow_id=?", due, rn.BorrowID); err != nil {
+		return 0, err
+	}
+	id, err := tx.InsertID(ctx, "INSERT INTO borrow_renewals (borrow_id , old_due_date , new_due_date , renewed_by , renewed_at) VALUES (? , ? , ? , ? , ?)", "renewal_id",
+		rn.BorrowID, sql.NullTime{Time: from, Valid: duedate.Valid}, due, rn.RenewedBy, now)
+	if err != nil {
+		return 0, err
+	}
+	rn.RenewalID = int(id)
+	rn.NewDueDate, rn.RenewedAt = due.Format(time.DateOnly), now.Format(time.RFC3339)
+	return renewals + 1, tx.Commit()
+}
+
+func (m *sqlBorrows) Renewals(ctx context.Context, borrowID int) ([]BorrowRenewal, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT renewal_id , borrow_id , old_due_date , new_due_date , renewed_by , renewed_at FROM borrow_renewals WHERE borrow_id=? ORDER BY renewal_id", borrowID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var renewals []BorrowRenewal
+	for rows.Next() {
+		var rn BorrowRenewal
+		var olddue, renewedat sql.NullTime
+		var newdue time.Time
+		if err := rows.Scan(&rn.RenewalID, &rn.BorrowID, &olddue, &newdue, &rn.RenewedBy, &renewedat); err != nil {
+			return nil, err
+		}
+		if olddue.Valid {
+			rn.OldDueDate = olddue.Time.Format(time.DateOnly)
+		}
+		rn.NewDueDate, rn.RenewedAt = newdue.Format(time.DateOnly), formatNullTime(renewedat)
+		renewals = append(renewals, rn)
+	}
+	return renewals, rows.Err()
+}
+
+func (m *sqlBorrows) Overdue(ctx context.Context, at time.Time, userType string, userID int) ([]OverdueLoan, error) {
+	y, mo, d := at.Date()
+	query := "SELECT b.borrow_id, b.user_id, b.user_type, b.book_id, l.book_name, c.barcode, b.borrow_date, b.return_date, b.due_date, b.fine_per_day FROM borrow_records b JOIN libraries l ON b.book_id=l.book_id LEFT JOIN book_copies c ON b.copy_id=c.copy_id WHERE b.return_date IS NULL AND b.due_date < ?"
+	args := []any{time.Date(y, mo, d, 0, 0, 0, 0, time.Local)}
+	if userType != "" {
+		query += " AND b.user_type=?"
+		args = append(args, userType)
+	}
+	if userID != 0 {
+		query += " AND b.user_id=?"
+		args = append(args, userID)
+	}
+	rows, err := m.db.QueryContext(ctx, query+" ORDER BY b.due_date, b.borrow_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loans []OverdueLoan
+	for rows.Next() {
+		var loan OverdueLoan
+		var barcode sql.NullString
+		var borrowdate, returndate, duedate sql.NullTime
+		var finePerDay int
+		if err := rows.Scan(&loan.BorrowID, &loan.UserID, &loan.UserType, &loan.BookID, &loan.BookType, &barcode, &borrowdate, &returndate, &duedate, &finePerDay); err != nil {
+			return nil, err
+		}
+		loan.Barcode = barcode.String
+		if borrowdate.Valid {
+			loan.BorrowDate = borrowdate.Time.Format(time.RFC3339)
+		}
+		loan.DueDate = duedate.Time.Format(time.DateOnly)
+		loan.DaysLate = daysLate(duedate.Time, at)
+		loan.AccruedFine = loan.DaysLate * finePerDay
+		loans = append(loans, loan)
+	}
+	return loans, rows.Err()
+}
+
+// Holds
+
+type sqlHolds struct {
+	db *DB
+}
+
+// holdColumns are scanned by scanHold, the position counts the waiting holds up to this one
+const holdColumns = "h.hold_id , h.book_id , h.user_id , h.user_type , h.status , c.barcode , h.placed_at , h.ready_at , h.expires_at , h.closed_at , " +
+	"CASE WHEN h.status='waiting' THEN (SELECT COUNT(*) FROM holds q WHERE q.book_id=h.book_id AND q.status='waiting' AND q.hold_id<=h.hold_id) ELSE 0 END " +
+	"FROM holds h LEFT JOIN book_copies c ON h.copy_id=c.copy_id"
+
+func scanHold(scan func(dest ...any) error) (Hold, error) {
+	var h Hold
+	var barcode sql.NullString
+	var placed, ready, expires, closed sql.NullTime
+	if err := scan(&h.HoldID, &h.BookID, &h.UserID, &h.UserType, &h.Status, &barcode, &placed, &ready, &expires, &closed, &h.Position); err != nil {
+		return h, err
+	}
+	h.Barcode = barcode.String
+	h.PlacedAt, h.ReadyAt, h.ExpiresAt, h.ClosedAt = formatNullTime(placed), formatNullTime(ready), formatNullTime(expires), formatNullTime(closed)
+	return h, nil
+}
+
+// formatNullTime formats a nullable timestamp as RFC 3339, NULL as ""
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339)
+}
+
+func (m *sqlHolds) Place(ctx context.Context, h *Hold) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx, "SELECT 1 FROM libraries WHERE book_id=?", h.BookID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := lockBorrower(ctx, tx, h.UserType, h.UserID); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, "SELECT 1 FROM holds WHERE book_id=? AND user_type=? AND user_id=? AND status IN ('waiting' , 'ready')", h.BookID, h.UserType, h.UserID).Scan(&exists)
+	if err == nil {
+		return ErrDuplicateHold
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	id, err := tx.InsertID(ctx, "INSERT INTO holds (book_id , user_id , user_type , status , placed_at) VALUES (? , ? , ? , 'waiting' , ?)", "hold_id", h.BookID, h.UserID, h.UserType, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	*h, err = m.Get(ctx, int(id))
+	return err
+}
+
+func (m *sqlHolds) Get(ctx context.Context, id int) (Hold, error) {
+	h, err := scanHold(m.db.QueryRowContext(ctx, "SELECT "+holdColumns+" WHERE h.hold_id=?", id).Scan)
+	if err == sql.ErrNoRows {
+		return h, ErrNotFound
+	}
+	return h, err
+}
+
+func (m *sqlHolds) List(ctx context.Context, f HoldFilter) ([]Hold, error) {
+	var where []string
+	var args []any
+	if f.BookID != 0 {
+		where, args = append(where, "h.book_id=?"), append(args, f.BookID)
+	}
+	if f.UserType != "" {
+		where, args = append(where, "h.user_type=?"), append(args, f.UserType)
+	}
+	if f.UserID != 0 {
+		where, args = append(where, "h.user_id=?"), append(args, f.UserID)
+	}
+	if f.Open {
+		where = append(where, "h.status IN ('waiting' , 'ready')")
+	}
+	query := "SELECT " + holdColumns
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := m.db.QueryContext(ctx, query+" ORDER BY h.hold_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var holds []Hold
+	for rows.Next() {
+		h, err := scanHold(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
+
+func (m *sqlHolds) Cancel(ctx context.Context, id int, now time.Time) (Hold, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Hold{}, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var bookID int
+	var copyID sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT status , book_id , copy_id FROM holds WHERE hold_id=?"+m.db.Dialect.ForUpdate(), id).Scan(&status, &bookID, &copyID)
+	if err == sql.ErrNoRows {
+		return Hold{}, ErrNotFound
+	}
+	if err != nil {
+		return Hold{}, err
+	}
+	if status != HoldWaiting && status != HoldReady {
+		h, err := m.Get(ctx, id)
+		if err != nil {
+			return h, err
+		}
+		return h, ErrHoldClosed
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE holds SET status='cancelled' , closed_at=? WHERE hold_id=?", now, id); err != nil {
+		return Hold{}, err
+	}
+	// release the copy kept for a ready hold
+	if status == HoldReady && copyID.Valid {
+		if _, err := tx.ExecContext(ctx, "UPDATE book_copies SET on_hold=FALSE WHERE copy_id=?", copyID.Int64); err != nil {
+			return Hold{}, err
+		}
+		if err := recountCopies(ctx, tx, bookID); err != nil {
+			return Hold{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Hold{}, err
+	}
+	return m.Get(ctx, id)
+}
+
+func (m *sqlHolds) Assign(ctx context.Context, bookID int, now time.Time, pickup time.Duration) ([]Hold, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// pair the oldest waiting hold with a lendable copy until either runs out
+	var ids []int
+	for {
+		var holdID, copyID int
+		err := tx.QueryRowContext(ctx, "SELECT hold_id FROM holds WHERE book_id=? AND status='waiting' ORDER BY hold_id LIMIT 1"+m.db.Dialect.ForUpdate(), bookID).Scan(&holdID)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = tx.QueryRowContext(ctx, "SELECT copy_id FROM book_copies WHERE book_id=? AND "+lendableCopy+" ORDER BY copy_id LIMIT 1"+m.db.Dialect.ForUpdate(), bookID).Scan(&copyID)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE book_copies SET on_hold=TRUE WHERE copy_id=?", copyID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE holds SET status='ready' , copy_id=? , ready_at=? , expires_at=? WHERE hold_id=?", copyID, now, now.Add(pickup), holdID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, holdID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := recountCopies(ctx, tx, bookID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	holds := make([]Hold, 0, len(ids))
+	for _, id := range ids {
+		h, err := m.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, nil
+}
+
+func (m *sqlHolds) Expire(ctx context.Context, now time.Time) ([]int, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT hold_id , book_id , copy_id FROM holds WHERE status='ready' AND expires_at < ?"+m.db.Dialect.ForUpdate(), now)
+	if err != nil {
+		return nil, err
+	}
+	type expired struct {
+		holdID, bookID int
+		copyID         sql.NullInt64
+	}
+	var holds []expired
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.holdID, &e.bookID, &e.copyID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		holds = append(holds, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var books []int
+	for _, e := range holds {
+		if _, err := tx.ExecContext(ctx, "UPDATE holds SET status='expired' , closed_at=? WHERE hold_id=?", now, e.holdID); err != nil {
+			return nil, err
+		}
+		if e.copyID.Valid {
+			if _, err := tx.ExecContext(ctx, "UPDATE book_copies SET on_hold=FALSE WHERE copy_id=?", e.copyID.Int64); err != nil {
+				return nil, err
+			}
+		}
+		if !slices.Contains(books, e.bookID) {
+			books = append(books, e.bookID)
+		}
+	}
+	for _, bookID := range books {
+		if err := recountCopies(ctx, tx, bookID); err != nil {
+			return nil, err
+		}
+	}
+	return books, tx.Commit()
+}
+
+// Loan policies
+
+type sqlLoanPolicies struct {
+	db *DB
+}
+
+const loanPolicyColumns = "user_type , category , max_loans , loan_days , renewal_limit , fine_per_day"
+
+func (m *sqlLoanPolicies) List(ctx context.Context) ([]LoanPolicy, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT "+loanPolicyColumns+" FROM loan_policies ORDER BY user_type , category")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []LoanPolicy
+	for rows.Next() {
+		var p LoanPolicy
+		if err := rows.Scan(&p.UserType, &p.Category, &p.MaxLoans, &p.LoanDays, &p.RenewalLimit, &p.FinePerDay); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+func (m *sqlLoanPolicies) Get(ctx context.Context, userType, category string) (LoanPolicy, error) {
+	var p LoanPolicy
+	err := m.db.QueryRowContext(ctx, "SELECT "+loanPolicyColumns+" FROM loan_policies WHERE user_type=? AND category=?", userType, category).
+		Scan(&p.UserType, &p.Category, &p.MaxLoans, &p.LoanDays, &p.RenewalLimit, &p.FinePerDay)
+	if err == sql.ErrNoRows {
+		return p, ErrNotFound
+	}
+	return p, err
+}
+
+func (m *sqlLoanPolicies) Put(ctx context.Context, p LoanPolicy) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// MySQL counts unchanged rows as not affected, so check for the row instead
+	var exists int
+	err = tx.QueryRowContext(ctx, "SELECT 1 FROM loan_policies WHERE user_type=? AND category=?"+m.db.Dialect.ForUpdate(), p.UserType, p.Category).Scan(&exists)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	created := err == sql.ErrNoRows
+	if created {
+		_, err = tx.ExecContext(ctx, "INSERT INTO loan_policies ("+loanPolicyColumns+") VALUES (? , ? , ? , ? , ? , ?)",
+			p.UserType, p.Category, p.MaxLoans, p.LoanDays, p.RenewalLimit, p.FinePerDay)
+	} else {
+		_, err = tx.ExecContext(ctx, "UPDATE loan_policies SET max_loans=? , loan_days=? , renewal_limit=? , fine_per_day=? WHERE user_type=? AND category=?",
+			p.MaxLoans, p.LoanDays, p.RenewalLimit, p.FinePerDay, p.UserType, p.Category)
+	}
+	if err != nil {
+		return false, err
+	}
+	return created, tx.Commit()
+}
+
+func (m *sqlLoanPolicies) Delete(ctx context.Context, userType, category string) error {
+	res, err := m.db.ExecContext(ctx, "DELETE FROM loan_policies WHERE user_type=? AND category=?", userType, category)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// Fines
+
+type sqlFines struct {
+	db *DB
+}
+
+const fineColumns = "fine_id , borrow_id , user_id , user_type , book_id , days_late , amount , paid , waived , created_at"
+
+// scanFine scans fineColumns
+func scanFine(scan func(dest ...any) error) (Fine, error) {
+	var f Fine
+	var created sql.NullTime
+	if err := scan(&f.FineID, &f.BorrowID, &f.UserID, &f.UserType, &f.BookID, &f.DaysLate, &f.Amount, &f.Paid, &f.Waived, &created); err != nil {
+		return f, err
+	}
+	f.Balance = f.Amount - f.Paid - f.Waived
+	if created.Valid {
+		f.CreatedAt = created.Time.Format(time.RFC3339)
+	}
+	return f, nil
+}
+
+func (m *sqlFines) List(ctx context.Context, f FineFilter) ([]Fine, error) {
+	query := "SELECT " + fineColumns + " FROM fines WHERE 1=1"
+	var args []any
+	if f.UserType != "" {
+		query += " AND user_type=?"
+		args = append(args, f.UserType)
+	}
+	if f.UserID != 0 {
+		query += " AND user_id=?"
+		args = append(args, f.UserID)
+	}
+	if f.Unpaid {
+		query += " AND amount > paid + waived"
+	}
+	rows, err := m.db.QueryContext(ctx, query+" ORDER BY fine_id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fines []Fine
+	for rows.Next() {
+		fine, err := scanFine(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		fines = append(fines, fine)
+	}
+	return fines, rows.Err()
+}
+
+func (m *sqlFines) Get(ctx context.Context, id int) (Fine, error) {
+	fine, err := scanFine(m.db.QueryRowContext(ctx, "SELECT "+fineColumns+" FROM fines WHERE fine_id=?", id).Scan)
+	if err == sql.ErrNoRows {
+		return fine, ErrNotFound
+	}
+	if err != nil {
+		return fine, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT transaction_id , fine_id , kind , amount , note , recorded_by , recorded_at FROM fine_transactions WHERE fine_id=? ORDER BY transaction_id", id)
+	if err != nil {
+		return fine, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t FineTransaction
+		var recorded sql.NullTime
+		if err := rows.Scan(&t.TransactionID, &t.FineID, &t.Kind, &t.Amount, &t.Note, &t.RecordedBy, &recorded); err != nil {
+			return fine, err
+		}
+		if recorded.Valid {
+			t.RecordedAt = recorded.Time.Format(time.RFC3339)
+		}
+		fine.Transactions = append(fine.Transactions, t)
+	}
+	return fine, rows.Err()
+}
+
+func (m *sqlFines) Settle(ctx context.Context, t *FineTransaction) (Fine, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Fine{}, err
+	}
+	defer tx.Rollback()
+
+	// lock the fine, concurrent payments must not overpay it
+	fine, err := scanFine(tx.QueryRowContext(ctx, "SELECT "+fineColumns+" FROM fines WHERE fine_id=?"+m.db.Dialect.ForUpdate(), t.FineID).Scan)
+	if err == sql.ErrNoRows {
+		return fine, ErrNotFound
+	}
+	if err != nil {
+		return fine, err
+	}
+	if fine.Balance == 0 {
+		return fine, ErrFineSettled
+	}
+	if t.Amount > fine.Balance {
+		return fine, ErrExceedsBalance
+	}
+	if t.Amount == 0 {
+		t.Amount = fine.Balance
+	}
+
+	// add the transaction to the ledger and to the paid or waived total
+	now := time.Now()
+	id, err := tx.InsertID(ctx, "INSERT INTO fine_transactions(fine_id , kind , amount , note , recorded_by , recorded_at)VALUES (? , ? , ? , ? , ? , ?)", "transaction_id",
+		t.FineID, t.Kind, t.Amount, t.Note, t.RecordedBy, now)
+	if err != nil {
+		return fine, err
+	}
+	t.TransactionID, t.RecordedAt = int(id), now.Format(time.RFC3339)
+	column := "paid"
+	if t.Kind == FineWaiver {
+		column = "waived"
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE fines SET "+column+"="+column+"+? WHERE fine_id=?", t.Amount, t.FineID); err != nil {
+		return fine, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fine, err
+	}
+	return m.Get(ctx, t.FineID)
+}
+
+func (m *sqlFines) Balance(ctx context.Context, userType string, userID int) (int, error) {
+	var balance int
+	err := m.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount - paid - waived), 0) FROM fines WHERE user_type=? AND user_id=?", userType, userID).Scan(&balance)
+	return balance, err
+}
+
+// Users
+
+type sqlUsers struct {
+	db *DB
+}
+
+// userColumns are scanned by scanUser
+const userColumns = "id , email , role , disabled , created_at , password_hash , totp_secret , totp_enabled , student_id , lecturer_id FROM users"
+
+func scanUser(scan func(dest ...any) error) (User, error) {
+	var u User
+	var createdAt sql.NullTime
+	var secret sql.NullString
+	var student, lecturer sql.NullInt64
+	if err := scan(&u.ID, &u.Email, &u.Role, &u.Disabled, &createdAt, &u.PasswordHash, &secret, &u.TOTPEnabled, &student, &lecturer); err != nil {
+		return u, err
+	}
+	u.CreatedAt, u.TOTPSecret = formatNullTime(createdAt), secret.String
+	u.StudentID, u.LecturerID = int(student.Int64), int(lecturer.Int64)
+	return u, nil
+}
+
+func (m *sqlUsers) Create(ctx context.Context, u *User) error {
+	var exists int
+	if err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE email=?", u.Email).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return ErrDuplicateEmail
+	}
+	id, err := m.db.InsertID(ctx, "INSERT INTO users (email , password_hash , role) VALUES (? , ? , ?)", "id", u.Email, u.PasswordHash, u.Role)
+	if err != nil {
+		return err
+	}
+	u.ID = int(id)
+	u.CreatedAt = time.Now().Format(time.RFC3339)
+	return nil
+}
+
+func (m *sqlUsers) List(ctx context.Context) ([]User, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT "+userColumns+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []User
+	for rows.Next() {
+		u, err := scanUser(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (m *sqlUsers) Get(ctx context.Context, id int) (User, error) {
+	u, err := scanUser(m.db.QueryRowContext(ctx, "SELECT "+userColumns+" WHERE id=?", id).Scan)
+	if err == sql.ErrNoRows {
+		return u, ErrNotFound
+	}
+	return u, err
+}
+
+func (m *sqlUsers) GetByEmail(ctx context.Context, email string) (User, error) {
+	u, err := scanUser(m.db.QueryRowContext(ctx, "SELECT "+userColumns+" WHERE email=?", email).Scan)
+	if err == sql.ErrNoRows {
+		return u, ErrNotFound
+	}
+	return u, err
+}
+
+// update runs a query that ends in "WHERE id=?" on the account id. MySQL only counts
+// changed rows, so an update that changed nothing looks the account up before ErrNotFound.
+func (m *sqlUsers) update(ctx context.Context, id int, query string, args ...any) error {
+	res, err := m.db.ExecContext(ctx, query, append(args, id)...)
+	if err != nil {
+		return err
+	}
+	if rows, err := res.RowsAffected(); err != nil || rows > 0 {
+		return err
+	}
+	_, err = m.Get(ctx, id)
+	return err
+}
+
+func (m *sqlUsers) SetPassword(ctx context.Context, id int, hash string) error {
+	return m.update(ctx, id, "UPDATE users SET password_hash=? WHERE id=?", hash)
+}
+
+func (m *sqlUsers) SetRole(ctx context.Context, id int, role string) error {
+	return m.update(ctx, id, "UPDATE users SET role=? WHERE id=?", role)
+}
+
+func (m *sqlUsers) Disable(ctx context.Context, id int) error {
+	return m.update(ctx, id, "UPDATE users SET disabled=TRUE WHERE id=?")
+}
+
+func (m *sqlUsers) SetTOTPSecret(ctx context.Context, id int, secret string) error {
+	return m.update(ctx, id, "UPDATE users SET totp_secret=? WHERE id=?", secret)
+}
+
+// lock locks the account id in tx, it returns ErrNotFound for an unknown account
+func (m *sqlUsers) lock(ctx context.Context, tx *Tx, id int) error {
+	err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id=?"+m.db.Dialect.ForUpdate(), id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (m *sqlUsers) Link(ctx context.Context, id int, studentID, lecturerID *int) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.lock(ctx, tx, id); err != nil {
+		return err
+	}
+
+	// the unique indexes on student_id and lecturer_id back this check up
+	var linked int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE id<>? AND (student_id=? OR lecturer_id=?)", id, studentID, lecturerID).Scan(&linked); err != nil {
+		return err
+	}
+	if linked > 0 {
+		return ErrAlreadyLinked
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET student_id=? , lecturer_id=? WHERE id=?", studentID, lecturerID, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *sqlUsers) EnableTOTP(ctx context.Context, id int, recoveryHashes []string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.lock(ctx, tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_enabled=TRUE WHERE id=?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id=?", id); err != nil {
+		return err
+	}
+	for _, hash := range recoveryHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO user_recovery_codes (user_id , code_hash) VALUES (? , ?)", id, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *sqlUsers) ResetTOTP(ctx context.Context, id int) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.lock(ctx, tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_secret=NULL , totp_enabled=FALSE WHERE id=?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id=?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *sqlUsers) UseRecoveryCode(ctx context.Context, id int, hash string) (bool, error) {
+	res, err := m.db.ExecContext(ctx, "UPDATE user_recovery_codes SET used_at=? WHERE user_id=? AND code_hash=? AND used_at IS NULL", time.Now(), id, hash)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// API keys
+
+type sqlAPIKeys struct {
+	db *DB
+}
+
+func (m *sqlAPIKeys) Create(ctx context.Context, k *APIKey, secretHash string) error {
+	id, err := m.db.InsertID(ctx, "INSERT INTO api_keys (key_id , name , key_hash , scopes , created_by) VALUES (? , ? , ? , ? , ?)", "id", k.KeyID, k.Name, secretHash, strings.Join(k.Scopes, ","), k.CreatedBy)
+	if err != nil {
+		return err
+	}
+	k.ID = int(id)
+	k.CreatedAt = time.Now().Format(time.RFC3339)
+	return nil
+}
+
+func (m *sqlAPIKeys) List(ctx context.Context) ([]APIKey, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT id , key_id , name , scopes , created_by , created_at , last_used_at , revoked_at FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []APIKey
+	for rows.Next() {
+		var k APIKey
+		var scopes string
+		var createdAt, lastUsed, revoked sql.NullTime
+		if err := rows.Scan(&k.ID, &k.KeyID, &k.Name, &scopes, &k.CreatedBy, &createdAt, &lastUsed, &revoked); err != nil {
+			return nil, err
+		}
+		k.Scopes = strings.Split(scopes, ",")
+		k.CreatedAt, k.LastUsedAt, k.RevokedAt = formatNullTime(createdAt), formatNullTime(lastUsed), formatNullTime(revoked)
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (m *sqlAPIKeys) Active(ctx context.Context, keyID string) (APIKey, string, error) {
+	k := APIKey{KeyID: keyID}
+	var hash, scopes string
+	err := m.db.QueryRowContext(ctx, "SELECT id , name , key_hash , scopes , created_by FROM api_keys WHERE key_id=? AND revoked_at IS NULL", keyID).Scan(&k.ID, &k.Name, &hash, &scopes, &k.CreatedBy)
+	if err == sql.ErrNoRows {
+		return k, "", ErrNotFound
+	}
+	if err != nil {
+		return k, "", err
+	}
+	k.Scopes = strings.Split(scopes, ",")
+	return k, hash, nil
+}
+
+func (m *sqlAPIKeys) Touch(ctx context.Context, id int, at time.Time) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at=? WHERE id=?", at, id)
+	return err
+}
+
+func (m *sqlAPIKeys) Revoke(ctx context.Context, id int, at time.Time) error {
+	res, err := m.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at=? WHERE id=? AND revoked_at IS NULL", at, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+// Courses
+
+type sqlCourses struct {
+	db *DB
+}
+
+func (m *sqlCourses) ListByStudent(ctx context.Context, studentID int) ([]Course, error) {
+	return m.list(ctx, "SELECT c.id, c.code, c.name, c.lecturer_id FROM courses c JOIN course_enrollments e ON e.course_id=c.id WHERE e.student_id=? ORDER BY c.code", studentID)
+}
+
+func (m *sqlCourses) ListByLecturer(ctx context.Context, lecturerID int) ([]Course, error) {
+	return m.list(ctx, "SELECT id, code, name, lecturer_id FROM courses WHERE lecturer_id=? ORDER BY code", lecturerID)
+}
+
+func (m *sqlCourses) list(ctx context.Context, query string, args ...any) ([]Course, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var courses []Course
+	for rows.Next() {
+		var c Course
+		var lecturer sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Code, &c.Name, &lecturer); err != nil {
+			return nil, err
+		}
+		c.LecturerID = int(lecturer.Int64)
+		courses = append(courses, c)
+	}
+	return courses, rows.Err()
+}
+
+// Search
+
+type sqlSearch struct {
+	db *DB
+}
+
+// searchTable describes how one result type is searched
+type searchTable struct {
+	typ     string
+	query   string   // SELECT of id, title, detail and the scored columns, without WHERE
+	columns []string // searched columns, the FULLTEXT index columns on MySQL
+	score   func(terms []string, scan func(dest ...any) error) (SearchResult, error)
+}
+
+var searchTables = []searchTable{
+	{
+		typ:     SearchBook,
+		query:   "SELECT book_id , book_name , title , author FROM libraries",
+		columns: []string{"title", "book_name", "author"},
+		score: func(terms []string, scan func(dest ...any) error) (SearchResult, error) {
+			var b Library
+			if err := scan(&b.Book_id, &b.Book_name, &b.Title, &b.Author); err != nil {
+				return SearchResult{}, err
+			}
+			return SearchResult{Type: SearchBook, ID: b.Book_id, Title: cmp.Or(b.Title, b.Book_name), Detail: b.Author, Score: bookScore(terms, b)}, nil
+		},
+	},
+	{
+		typ:     SearchStudent,
+		query:   "SELECT id , name , email FROM students",
+		columns: []string{"name", "email"},
+		score: func(terms []string, scan func(dest ...any) error) (SearchResult, error) {
+			var st Student
+			if err := scan(&st.Id, &st.Name, &st.Email); err != nil {
+				return SearchResult{}, err
+			}
+			return SearchResult{Type: SearchStudent, ID: st.Id, Title: st.Name, Detail: st.Email, Score: studentScore(terms, st)}, nil
+		},
+	},
+	{
+		typ:     SearchLecturer,
+		query:   "SELECT id , name , designation FROM lecturers",
+		columns: []string{"name"},
+		score: func(terms []string, scan func(dest ...any) error) (SearchResult, error) {
+			var l Lecturer
+			if err := scan(&l.ID, &l.Name, &l.Designation); err != nil {
+				return SearchResult{}, err
+			}
+			return SearchResult{Type: SearchLecturer, ID: l.ID, Title: l.Name, Detail: l.Designation, Score: lecturerScore(terms, l)}, nil
+		},
+	},
+}
+
+// likeEscaper escapes LIKE wildcards with "!", which needs no quoting in any dialect
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func (m *sqlSearch) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	fulltext := m.db.Dialect == DialectMySQL && !slices.ContainsFunc(q.Terms, func(t string) bool {
+		return len([]rune(t)) < mysqlMinTokenSize
+	})
+
+	var results []SearchResult
+	for _, t := range searchTables {
+		if !slices.Contains(q.Types, t.typ) {
+			continue
+		}
+		var found []SearchResult
+		var err error
+		if fulltext {
+			found, err = m.fulltext(ctx, t, q)
+		} else {
+			found, err = m.like(ctx, t, q)
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, found...)
+	}
+	return rankSearchResults(results, q.Limit), nil
+}
+
+// fulltext ranks with MATCH ... AGAINST, every term is required and matches word prefixes
+func (m *sqlSearch) fulltext(ctx context.Context, t searchTable, q SearchQuery) ([]SearchResult, error) {
+	var boolean strings.Builder
+	for _, term := range q.Terms {
+		boolean.WriteString("+" + term + "* ")
+	}
+	match := "MATCH(" + strings.Join(t.columns, ", ") + ") AGAINST (? IN BOOLEAN MODE)"
+	query := strings.Replace(t.query, " FROM ", " , "+match+" AS score FROM ", 1) + " WHERE " + match + " ORDER BY score DESC LIMIT ?"
+
+	rows, err := m.db.QueryContext(ctx, query, boolean.String(), boolean.String(), q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		// the MATCH score is the last column
+		var score float64
+		res, err := t.score(q.Terms, func(dest ...any) error { return rows.Scan(append(dest, &score)...) })
+		if err != nil {
+			return nil, err
+		}
+		res.Score = score
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// like finds rows containing every term in one of the columns and ranks them with searchScore
+func (m *sqlSearch) like(ctx context.Context, t searchTable, q SearchQuery) ([]SearchResult, error) {
+	var where []string
+	var args []any
+	for _, term := range q.Terms {
+		var anyColumn []string
+		for _, c := range t.columns {
+			anyColumn = append(anyColumn, "LOWER("+c+") LIKE ? ESCAPE '!'")
+			args = append(args, "%"+likeEscaper.Replace(term)+"%")
+		}
+		where = append(where, "("+strings.Join(anyColumn, " OR ")+")")
+	}
+	args = append(args, searchCandidates)
+
+	rows, err := m.db.QueryContext(ctx, t.query+" WHERE "+strings.Join(where, " AND ")+" LIMIT ?", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		res, err := t.score(q.Terms, rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		if res.Score > 0 {
+			results = append(results, res)
+		}
+	}
+	return results, rows.Err()
+}
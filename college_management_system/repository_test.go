@@ -0,0 +1,267 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRepositories returns the memory repositories and the SQL ones on a migrated SQLite database
+func testRepositories(t *testing.T) map[string]Repositories {
+	t.Helper()
+	db, err := OpenDB("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	inst := &MySQLInstance{db: db}
+	mg, err := NewMigrator(inst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mg.Up(); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Repositories{
+		"memory": NewMemoryRepositories(),
+		"sqlite": NewSQLRepositories(inst),
+	}
+}
+
+// createBook adds a standard book with copies barcoded prefix-0 to prefix-(copies-1)
+func createBook(t *testing.T, repos Repositories, prefix string, copies int) Library {
+	t.Helper()
+	ctx := context.Background()
+	book := Library{Book_name: "Go", Title: "The Go Programming Language", Author: "Donovan", Category: CategoryStandard}
+	if err := repos.Libraries.Create(ctx, &book); err != nil {
+		t.Fatal(err)
+	}
+	for i := range copies {
+		c := BookCopy{BookID: book.Book_id, Barcode: fmt.Sprintf("%s-%d", prefix, i), Condition: CopyGood}
+		if err := repos.Copies.Create(ctx, &c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return book
+}
+
+// createStudents adds n students and returns their ids
+func createStudents(t *testing.T, repos Repositories, n int) []int {
+	t.Helper()
+	ids := make([]int, n)
+	for i := range n {
+		st := Student{Name: fmt.Sprintf("Student %d", i+1), Age: 20, Email: fmt.Sprintf("student%d@example.com", i+1), Dept: "CS"}
+		if err := repos.Students.Create(context.Background(), &st); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = st.Id
+	}
+	return ids
+}
+
+// watchAvailable reads the available copies of a book until stop is closed and
+// reports the lowest count it saw
+func watchAvailable(repos Repositories, bookID int, stop <-chan struct{}) <-chan int {
+	lowest := make(chan int, 1)
+	go func() {
+		low := math.MaxInt
+		for {
+			select {
+			case <-stop:
+				lowest <- low
+				return
+			default:
+			}
+			if book, err := repos.Libraries.Get(context.Background(), bookID); err == nil {
+				low = min(low, book.Available_copies)
+			}
+		}
+	}()
+	return lowest
+}
+
+func TestConcurrentBorrow(t *testing.T) {
+	const copies, borrowers = 3, 24
+	for name, repos := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			book := createBook(t, repos, "borrow", copies)
+			students := createStudents(t, repos, borrowers)
+			stop := make(chan struct{})
+			lowest := watchAvailable(repos, book.Book_id, stop)
+
+			// every borrower scans one of the copies, each copy is wanted by several of them
+			errs := make([]error, borrowers)
+			var wg sync.WaitGroup
+			for i := range borrowers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					rec := Borrow_records{User_id: students[i], User_type: "student", Barcode: fmt.Sprintf("borrow-%d", i%copies)}
+					errs[i] = repos.Borrows.Borrow(ctx, &rec, &LoanLimits{MaxLoans: 1})
+				}()
+			}
+			wg.Wait()
+			close(stop)
+
+			lent := 0
+			for i, err := range errs {
+				switch err {
+				case nil:
+					lent++
+				case ErrCopyNotAvailable:
+				default:
+					t.Errorf("borrower %d: %v", i+1, err)
+				}
+			}
+			if lent != copies {
+				t.Errorf("%d borrows succeeded, want %d", lent, copies)
+			}
+			if low := <-lowest; low < 0 {
+				t.Errorf("available copies went down to %d", low)
+			}
+			got, err := repos.Libraries.Get(ctx, book.Book_id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Available_copies != 0 {
+				t.Errorf("available copies = %d, want 0", got.Available_copies)
+			}
+		})
+	}
+}
+
+func TestConcurrentReturn(t *testing.T) {
+	const copies, returners = 3, 24
+	for name, repos := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			book := createBook(t, repos, "return", copies)
+			students := createStudents(t, repos, copies)
+			for i := range copies {
+				rec := Borrow_records{User_id: students[i], User_type: "student", Barcode: fmt.Sprintf("return-%d", i)}
+				if err := repos.Borrows.Borrow(ctx, &rec, &LoanLimits{MaxLoans: 1}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stop := make(chan struct{})
+			lowest := watchAvailable(repos, book.Book_id, stop)
+
+			// every copy is returned by several desks at once, only one closes its loan
+			errs := make([]error, returners)
+			var wg sync.WaitGroup
+			for i := range returners {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _, errs[i] = repos.Borrows.Return(ctx, fmt.Sprintf("return-%d", i%copies))
+				}()
+			}
+			wg.Wait()
+			close(stop)
+
+			returned := 0
+			for i, err := range errs {
+				switch err {
+				case nil:
+					returned++
+				case ErrNotFound:
+				default:
+					t.Errorf("returner %d: %v", i+1, err)
+				}
+			}
+			if returned != copies {
+				t.Errorf("%d returns succeeded, want %d", returned, copies)
+			}
+			if low := <-lowest; low < 0 {
+				t.Errorf("available copies went down to %d", low)
+			}
+			got, err := repos.Libraries.Get(ctx, book.Book_id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Available_copies != copies {
+				t.Errorf("available copies = %d, want %d", got.Available_copies, copies)
+			}
+		})
+	}
+}
+
+func TestBorrowUnknownBorrower(t *testing.T) {
+	for name, repos := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			book := createBook(t, repos, "unknown", 1)
+			for _, userType := range []string{"student", "lecturer"} {
+				rec := Borrow_records{User_id: 999, User_type: userType, Barcode: "unknown-0"}
+				if err := repos.Borrows.Borrow(ctx, &rec, &LoanLimits{MaxLoans: 1}); err != ErrBorrowerNotFound {
+					t.Errorf("%s: err = %v, want ErrBorrowerNotFound", userType, err)
+				}
+			}
+			got, err := repos.Libraries.Get(ctx, book.Book_id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Available_copies != 1 {
+				t.Errorf("available copies = %d, want 1", got.Available_copies)
+			}
+		})
+	}
+}
+
+func TestPlaceHoldUnknownBorrower(t *testing.T) {
+	for name, repos := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			book := createBook(t, repos, "hold", 0)
+			hold := Hold{BookID: book.Book_id, UserID: 999, UserType: "lecturer"}
+			if err := repos.Holds.Place(ctx, &hold); err != ErrBorrowerNotFound {
+				t.Errorf("err = %v, want ErrBorrowerNotFound", err)
+			}
+			holds, err := repos.Holds.List(ctx, HoldFilter{BookID: book.Book_id})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(holds) != 0 {
+				t.Errorf("%d holds placed, want 0", len(holds))
+			}
+		})
+	}
+}
+
+func TestOverdueOrder(t *testing.T) {
+	for name, repos := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			createBook(t, repos, "overdue", 3)
+			students := createStudents(t, repos, 3)
+			now := time.Now()
+			dues := []time.Time{now.AddDate(0, 0, -2), now.AddDate(0, 0, -5), now.AddDate(0, 0, -5)}
+			var ids []int
+			for i, due := range dues {
+				rec := Borrow_records{User_id: students[i], User_type: "student", Barcode: fmt.Sprintf("overdue-%d", i), Due_date: due.Format(time.DateOnly), Fine_per_day: 10}
+				if err := repos.Borrows.Borrow(ctx, &rec, &LoanLimits{MaxLoans: 1}); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, rec.Borrow_id)
+			}
+
+			loans, err := repos.Borrows.Overdue(ctx, now, "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, loan := range loans {
+				got = append(got, loan.BorrowID)
+			}
+			if want := []int{ids[1], ids[2], ids[0]}; !slices.Equal(got, want) {
+				t.Errorf("overdue loans = %v, want %v", got, want)
+			}
+		})
+	}
+}
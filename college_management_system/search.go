@@ -0,0 +1,212 @@
+package collegemanagementsystem
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Search looks for books by title, book name and author, students by name and email
+// and lecturers by name. MySQL ranks with the FULLTEXT indexes of migration 8,
+// the other backends (and MySQL for terms shorter than its minimum token size)
+// match the terms with LIKE and rank the rows with searchScore.
+
+// Types of search results
+const (
+	SearchBook     = "book"
+	SearchStudent  = "student"
+	SearchLecturer = "lecturer"
+)
+
+// SearchTypes lists every search result type
+var SearchTypes = []string{SearchBook, SearchStudent, SearchLecturer}
+
+// searchRoutes is the read route a caller needs for each result type
+var searchRoutes = map[string]string{
+	SearchBook:     "GET /api/libraries/{id}",
+	SearchStudent:  "GET /api/students",
+	SearchLecturer: "GET /api/lecturers",
+}
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+
+	// searchCandidates caps the rows read per type before ranking on the LIKE fallback
+	searchCandidates = 500
+
+	// mysqlMinTokenSize is innodb_ft_min_token_size, shorter words are not in the FULLTEXT index
+	mysqlMinTokenSize = 3
+)
+
+// SearchResult is one match of a search
+type SearchResult struct {
+	Type string `json:"type"`
+	ID   int    `json:"id"`
+	// Title is the book title or the person's name
+	Title string `json:"title"`
+	// Detail is the author of a book, the email of a student or the designation of a lecturer
+	Detail string  `json:"detail"`
+	Score  float64 `json:"score"`
+}
+
+// SearchQuery is a parsed search request
+type SearchQuery struct {
+	// Terms are the lower cased words of q
+	Terms []string
+	Types []string
+	Limit int
+}
+
+// searchTerms splits q into lower cased words, dropping punctuation and search operators
+func searchTerms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchField is a column value and how much a match in it counts
+type searchField struct {
+	value  string
+	weight float64
+}
+
+// searchScore ranks a row for the LIKE fallback. Every term has to be found in some field,
+// an exact field match counts 3, a match at the start of a word 2 and any other match 1,
+// times the field weight. It returns 0 when a term is missing.
+func searchScore(terms []string, fields ...searchField) float64 {
+	var score float64
+	for _, term := range terms {
+		best := 0.0
+		for _, f := range fields {
+			v := strings.ToLower(f.value)
+			s := 0.0
+			switch {
+			case v == term:
+				s = 3
+			case strings.HasPrefix(v, term) || slices.ContainsFunc(searchTerms(v), func(w string) bool { return strings.HasPrefix(w, term) }):
+				s = 2
+			case strings.Contains(v, term):
+				s = 1
+			}
+			best = max(best, s*f.weight)
+		}
+		if best == 0 {
+			return 0
+		}
+		score += best
+	}
+	return score
+}
+
+func bookScore(terms []string, b Library) float64 {
+	return searchScore(terms, searchField{b.Title, 3}, searchField{b.Book_name, 2}, searchField{b.Author, 2})
+}
+
+func studentScore(terms []string, s Student) float64 {
+	return searchScore(terms, searchField{s.Name, 3}, searchField{s.Email, 1})
+}
+
+func lecturerScore(terms []string, l Lecturer) float64 {
+	return searchScore(terms, searchField{l.Name, 3})
+}
+
+// rankSearchResults sorts results best first and keeps at most limit of them
+func rankSearchResults(results []SearchResult, limit int) []SearchResult {
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Type, b.Type); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// SearchHandler godoc
+// @Summary Search books, students and lecturers
+// @Description Ranked search over book title, book name and author, student name and email and lecturer name.
+// @Description Only the types the caller may read are searched.
+// @Tags Search
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param q query string true "Search text"
+// @Param type query string false "Comma separated types: book, student, lecturer (default all readable)"
+// @Param limit query int false "Max results (default 20, max 100)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/search [get]
+// SearchHandler searches the library, students and lecturers
+func (a *HybridHandler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse the query
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	query := SearchQuery{Terms: searchTerms(q), Types: []string{}, Limit: DefaultSearchLimit}
+	if len(query.Terms) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"err": "q is required"})
+		return
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"err": "limit must be a positive number"})
+			return
+		}
+		query.Limit = min(limit, MaxSearchLimit)
+	}
+
+	// Requested types must be readable, by default every readable type is searched
+	if v := r.URL.Query().Get("type"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(SearchTypes, t) {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"err": fmt.Sprintf("type must be one of %s", strings.Join(SearchTypes, ", "))})
+				return
+			}
+			if !Allowed(r, searchRoutes[t]) {
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{"err": fmt.Sprintf("not allowed to search %s records", t)})
+				return
+			}
+			if !slices.Contains(query.Types, t) {
+				query.Types = append(query.Types, t)
+			}
+		}
+	} else {
+		for _, t := range SearchTypes {
+			if Allowed(r, searchRoutes[t]) {
+				query.Types = append(query.Types, t)
+			}
+		}
+	}
+
+	results := []SearchResult{}
+	if len(query.Types) > 0 {
+		found, err := a.Search.Search(r.Context(), query)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"err": "unable to search"})
+			return
+		}
+		results = append(results, found...)
+	}
+
+	// Send response
+	json.NewEncoder(w).Encode(map[string]any{"query": q, "types": query.Types, "data": results})
+}
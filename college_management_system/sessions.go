@@ -0,0 +1,126 @@
+package collegemanagementsystem
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Refresh tokens are tracked in the AuthStore (Redis) so they can be rotated and revoked.
+//
+//	refresh:<jti>            hash {family, email, used} for every issued refresh token
+//	refresh_family:<family>  set of jtis issued in one login session
+//	user_sessions:<email>    set of families (sessions) of an account
+//
+// A token family starts at login and gets a new jti on every refresh.
+// Presenting a jti that was already used means the token was replayed,
+// so the whole family is revoked.
+
+// errors returned by RotateRefreshToken
+var (
+	ErrRefreshRevoked = errors.New("refresh token revoked")
+	ErrRefreshReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+// randomID returns a random 128 bit hex string used for jtis and token families.
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func refreshKey(jti string) string          { return "refresh:" + jti }
+func refreshFamilyKey(family string) string { return "refresh_family:" + family }
+func userSessionsKey(email string) string   { return "user_sessions:" + email }
+
+// IssueRefreshToken creates a refresh token for email in the given family and stores its jti in the AuthStore.
+// An empty family starts a new session.
+func (a *HybridHandler) IssueRefreshToken(email, family string) (string, error) {
+	if family == "" {
+		family = randomID()
+	}
+	jti := randomID()
+
+	if err := a.Auth.AddRefresh(a.Ctx, email, family, jti, RefreshTokenTTL); err != nil {
+		return "", err
+	}
+	return GenerateRefreshToken(email, jti)
+}
+
+// RotateRefreshToken marks the jti of claims as used and issues the next token of its family.
+// An unknown jti returns ErrRefreshRevoked, a jti that was already used
+// revokes the whole family and returns ErrRefreshReused.
+func (a *HybridHandler) RotateRefreshToken(claims *Claims) (string, error) {
+	family, err := a.Auth.HGet(a.Ctx, refreshKey(claims.ID), "family")
+	if err == ErrNotFound {
+		return "", ErrRefreshRevoked
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// HINCRBY is atomic, only the first caller sees 1
+	used, err := a.Auth.HIncrBy(a.Ctx, refreshKey(claims.ID), "used", 1)
+	if err != nil {
+		return "", err
+	}
+	if used > 1 {
+		if err := a.RevokeFamily(claims.Email, family); err != nil {
+			return "", err
+		}
+		go AuditLog("REUSE_DETECTED", "REFRESH_TOKEN", family, claims.Email)
+		return "", ErrRefreshReused
+	}
+	return a.IssueRefreshToken(claims.Email, family)
+}
+
+// RevokeFamily deletes every jti of a token family.
+func (a *HybridHandler) RevokeFamily(email, family string) error {
+	return a.Auth.RevokeFamily(a.Ctx, email, family)
+}
+
+// RevokeRefreshToken revokes the family the given refresh token belongs to.
+func (a *HybridHandler) RevokeRefreshToken(claims *Claims) error {
+	family, err := a.Auth.HGet(a.Ctx, refreshKey(claims.ID), "family")
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return a.RevokeFamily(claims.Email, family)
+}
+
+// RevokeAllSessions revokes every refresh token family of an account.
+func (a *HybridHandler) RevokeAllSessions(email string) error {
+	families, err := a.Auth.SMembers(a.Ctx, userSessionsKey(email))
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if err := a.RevokeFamily(email, family); err != nil {
+			return err
+		}
+	}
+	_, err = a.Auth.Del(a.Ctx, userSessionsKey(email))
+	return err
+}
+
+// parseRefreshToken parses a refresh token, optionally accepting expired ones
+// so that logout still works with an old cookie.
+func parseRefreshToken(tokenstr string, allowExpired bool) (*Claims, error) {
+	opts := []jwt.ParserOption{}
+	if allowExpired {
+		opts = append(opts, jwt.WithoutClaimsValidation())
+	}
+	claims, err := parseToken(tokenstr, opts...)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+	if claims.TokenType != "refresh" || claims.ID == "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,68 @@
+package collegemanagementsystem
+
+import "testing"
+
+// refreshClaims parses a refresh token
+func refreshClaims(t *testing.T, token string) *Claims {
+	t.Helper()
+	claims, err := parseRefreshToken(token, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestRotateRefreshTokenReuse(t *testing.T) {
+	h := NewMemoryHandler()
+	const email = "admin@example.com"
+
+	first, err := h.IssueRefreshToken(email, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a second session of the same account must survive the reuse in the first
+	other, err := h.IssueRefreshToken(email, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := h.RotateRefreshToken(refreshClaims(t, first))
+	if err != nil {
+		t.Fatalf("first rotation: %v", err)
+	}
+	third, err := h.RotateRefreshToken(refreshClaims(t, second))
+	if err != nil {
+		t.Fatalf("second rotation: %v", err)
+	}
+
+	// the family holds every jti issued in the session
+	family, err := h.Auth.HGet(h.Ctx, refreshKey(refreshClaims(t, third).ID), "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jtis, _ := h.Auth.SMembers(h.Ctx, refreshFamilyKey(family)); len(jtis) != 3 {
+		t.Fatalf("family has %d jtis, want 3", len(jtis))
+	}
+
+	// replaying a used token revokes the whole family
+	if _, err := h.RotateRefreshToken(refreshClaims(t, first)); err != ErrRefreshReused {
+		t.Fatalf("replay: err = %v, want ErrRefreshReused", err)
+	}
+	if _, err := h.RotateRefreshToken(refreshClaims(t, third)); err != ErrRefreshRevoked {
+		t.Errorf("latest token after reuse: err = %v, want ErrRefreshRevoked", err)
+	}
+	if jtis, _ := h.Auth.SMembers(h.Ctx, refreshFamilyKey(family)); len(jtis) != 0 {
+		t.Errorf("family still has %d jtis", len(jtis))
+	}
+	sessions, err := h.Auth.SMembers(h.Ctx, userSessionsKey(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0] == family {
+		t.Errorf("sessions = %v, want only the other session", sessions)
+	}
+
+	if _, err := h.RotateRefreshToken(refreshClaims(t, other)); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+}
@@ -3,11 +3,9 @@ package collegemanagementsystem
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -20,37 +18,62 @@ type Student struct {
 	Age   int    `json:"age"`
 	Email string `json:"email"`
 	Dept  string `json:"dept"`
+
+	// EmailVerified is set through the verification email and can't be written by clients
+	EmailVerified bool `json:"email_verified"`
+
+	// Version is bumped on every write and sent as the ETag, clients send it back in If-Match
+	Version int `json:"version"`
 }
 
-// Validatestudent validates incoming student data
-func ValidateStudent(student Student) error {
+// studentPatchFields are the fields a PATCH may change
+var studentPatchFields = []string{"name", "age", "email", "dept"}
+
+// studentRules validate a student field by field, a PATCH only runs those of the changed fields
+var studentRules = []fieldRule[Student]{
 	// Name validation
-	if strings.TrimSpace(student.Name) == "" {
-		return fmt.Errorf("Empty name or invalid name")
-	}
+	{"name", func(student Student) error {
+		if strings.TrimSpace(student.Name) == "" {
+			return fmt.Errorf("Empty name or invalid name")
+		}
+		return nil
+	}},
 	// Email validation
-	if strings.TrimSpace(student.Email) == "" {
-		return fmt.Errorf("Empty email or invalid email")
-	}
-	if !strings.HasSuffix(student.Email, "@gmail.com") {
-		return fmt.Errorf("email is invalid and does not contains @gmail.com")
-	}
-	prefix := strings.TrimSuffix(student.Email, "@gmail.com")
-	if prefix == "" {
-		return fmt.Errorf("email must contains a prefix before @gmail.com")
-	}
+	{"email", func(student Student) error {
+		if strings.TrimSpace(student.Email) == "" {
+			return fmt.Errorf("Empty email or invalid email")
+		}
+		if !strings.HasSuffix(student.Email, "@gmail.com") {
+			return fmt.Errorf("email is invalid and does not contains @gmail.com")
+		}
+		prefix := strings.TrimSuffix(student.Email, "@gmail.com")
+		if prefix == "" {
+			return fmt.Errorf("email must contains a prefix before @gmail.com")
+		}
+		return nil
+	}},
 	// Department validation
-	if strings.TrimSpace(student.Dept) == "" {
-		return fmt.Errorf("Empty dept or invalid dept")
-	}
+	{"dept", func(student Student) error {
+		if strings.TrimSpace(student.Dept) == "" {
+			return fmt.Errorf("Empty dept or invalid dept")
+		}
+		return nil
+	}},
 	// Age validation
-	if student.Age <= 0 {
-		return fmt.Errorf("Age is less than 0 ")
-	}
-	if student.Age >= 100 {
-		return fmt.Errorf("Age is grater than 0")
-	}
-	return nil
+	{"age", func(student Student) error {
+		if student.Age <= 0 {
+			return fmt.Errorf("Age is less than 0 ")
+		}
+		if student.Age >= 100 {
+			return fmt.Errorf("Age is grater than 0")
+		}
+		return nil
+	}},
+}
+
+// Validatestudent validates incoming student data
+func ValidateStudent(student Student) error {
+	return validateFields(student, studentRules, nil)
 }
 
 // CreateStudentHandler godoc
@@ -74,6 +97,8 @@ func (a *HybridHandler) CreateStudentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	students.EmailVerified = false
+
 	// validate requests payload
 	if err := ValidateStudent(students); err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -82,23 +107,15 @@ func (a *HybridHandler) CreateStudentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Insert student record into MySQL database
-	res, err := a.MySQL.db.Exec("INSERT INTO students (name , age , email , dept) VALUES (? , ? , ? , ?)", students.Name, students.Age, students.Email, students.Dept)
-	if err != nil {
+	// Insert student record, the repository sets the auto_generated id
+	if err := a.Students.Create(r.Context(), &students); err != nil {
 		http.Error(w, "Unable to insert", http.StatusInternalServerError)
 		return
 	}
 
-	// auto_generated id
-	id, err := res.LastInsertId()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-	students.Id = int(id)
-
 	// Lod activity and Audit trail
-	go LogActivity("CREATE_EMPLOYEE", "system")
-	go AuditLog("CREATE", "EMPLOYEE", students.Id, "system")
+	go LogActivity("CREATE_STUDENT", Actor(r))
+	go AuditLog("CREATE", "STUDENT", students.Id, Actor(r))
 
 	// send success response
 	w.Header().Set("Content-Type", "application/json")
@@ -108,37 +125,39 @@ func (a *HybridHandler) CreateStudentHandler(w http.ResponseWriter, r *http.Requ
 
 // GetStudentHandler godoc
 // @Summary Get all students
-// @Description Retrieve all students
+// @Description Retrieve students page by page, filtered by department
 // @Tags Students
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {array} Student
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Skip this many rows, links then page by offset"
+// @Param cursor query string false "Cursor from a next/prev link"
+// @Param sort query string false "id, name, age, email or dept, prefix - for descending (default id)"
+// @Param dept query string false "Department"
+// @Success 200 {object} Page[Student]
+// @Failure 400 {object} map[string]string
 // @Router /api/students [get]
 // GetStudentHandler to get all students
 func (a *HybridHandler) GetStudentHandler(w http.ResponseWriter, r *http.Request) {
 
-	// Execute query to fetch student records
-	rows, err := a.MySQL.db.Query("SELECT id , name , age , email , dept FROM students")
+	// Read paging and filters from the query string
+	params, err := ParsePageParams(r.URL.Query(), studentSortFields, "id")
 	if err != nil {
-		http.Error(w, "unable to fetch students", http.StatusInternalServerError)
+		writePageError(w, err)
 		return
 	}
-	defer rows.Close()
-
-	var students []Student
-	for rows.Next() {
-		var s Student
-		if err := rows.Scan(&s.Id, &s.Name, &s.Age, &s.Email, &s.Dept); err != nil {
-			http.Error(w, "rows scan failed", http.StatusInternalServerError)
-			return
-		}
-		students = append(students, s)
+	filter := StudentFilter{PageParams: params, Dept: r.URL.Query().Get("dept")}
 
+	// fetch student records
+	students, err := a.Students.List(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "unable to fetch students", http.StatusInternalServerError)
+		return
 	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(students)
+	json.NewEncoder(w).Encode(NewPage(r, params, students))
 }
 
 // GetstudentByIDHandler godoc
@@ -147,7 +166,9 @@ func (a *HybridHandler) GetStudentHandler(w http.ResponseWriter, r *http.Request
 // @Security BearerAuth
 // @Produce json
 // @Param id path int true "Student ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} Student
+// @Success 304 "Not modified"
 // @Failure 404 {object} map[string]string
 // @Router /api/students/{id} [get]
 // GetStudentByIDHandler retrives a student by id
@@ -156,40 +177,37 @@ func (a *HybridHandler) GetstudentByIDHandler(w http.ResponseWriter, r *http.Req
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	// Log Get activity
-	go LogActivity("GET_EMPLOYEE", "system")
-
-	// Attempt to fetch from Redis cache first
-	value, err := a.Redis.Client.Get(a.Ctx, id).Result()
-	if err == nil {
-		log.Println("Cache Hit...")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(value))
+	// students may only read their own record
+	idINT, _ := strconv.Atoi(id)
+	if ok, err := a.OwnsStudent(r, idINT); err != nil || !ok {
+		writeForbidden(w, "students can only access their own record")
 		return
 	}
-	// cache miss fetching from MySQL database
-	fmt.Println("cache miss querying MySQL...")
-	row := a.MySQL.db.QueryRow("SELECT id ,name , age , email , dept FROM students WHERE id=?", id)
 
-	var students Student
-	if err := row.Scan(&students.Id, &students.Name, &students.Age, &students.Email, &students.Dept); err != nil {
+	// Log Get activity
+	go LogActivity("GET_EMPLOYEE", Actor(r))
+
+	// Read through the cache, loading from the repository on a miss
+	students, err := a.Caches.Students.Get(r.Context(), idINT, a.Students.Get)
+	if err == ErrNotFound {
 		http.Error(w, "student not found ", http.StatusNotFound)
 		return
 	}
-
-	// Marshal student data for caching
-	jsonData, err := json.Marshal(students)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// store result in a redis cache (short TTL)
-	go a.Redis.Client.Set(a.Ctx, id, jsonData, 10*time.Second)
+	// Conditional GET, 304 when the client has this version
+	etag := ETag(students.Version)
+	if notModified(w, r, etag) {
+		return
+	}
 
 	//  send response
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(jsonData)
+	w.Header().Set("ETag", etag)
+	json.NewEncoder(w).Encode(students)
 }
 
 // UpdateStudentHandler godoc
@@ -198,18 +216,33 @@ func (a *HybridHandler) GetstudentByIDHandler(w http.ResponseWriter, r *http.Req
 // @Security BearerAuth
 // @Accept json
 // @Produce json
+// @Param id path int true "Student ID"
+// @Param If-Match header string true "ETag of the record as read, e.g. \"3\""
 // @Param student body Student true "Updated Student"
 // @Success 200 {object} Student
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
 // @Router /api/students/{id} [put]
 // UpdateStudentHandler updates a exsisting student
 func (a *HybridHandler) UpdateStudentHandler(w http.ResponseWriter, r *http.Request) {
 
+	// Extract id from URL
+	idINT, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	// the client must send the ETag it read, so concurrent edits don't overwrite each other
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
 	// Decode request Body
 	var students Student
 	if err := json.NewDecoder(r.Body).Decode(&students); err != nil {
 		http.Error(w, "Failed to decode response", http.StatusInternalServerError)
 		return
 	}
+	students.Id, students.Version = idINT, version
 
 	// validate updated data
 	if err := ValidateStudent(students); err != nil {
@@ -219,42 +252,125 @@ func (a *HybridHandler) UpdateStudentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Execute updated query
-	res, err := a.MySQL.db.Exec("UPDATE students SET name=? , age=? , email=? , dept=? WHERE id=?", students.Name, students.Age, students.Email, students.Dept, students.Id)
-	if err != nil {
-		http.Error(w, "unable to update", http.StatusInternalServerError)
+	// Update the record, a changed email has to be verified again
+	err := a.Students.Update(r.Context(), &students)
+	if err == ErrNotFound {
+		http.Error(w, "user not found ", http.StatusNotFound)
 		return
 	}
-
-	//  check if record exsists
-	rows, err := res.RowsAffected()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
 		return
 	}
-	if rows == 0 {
-		http.Error(w, "user not found ", http.StatusNotFound)
+	if err != nil {
+		http.Error(w, "unable to update", http.StatusInternalServerError)
 		return
 	}
 
-	// update redis cache
+	// drop the cached copy, the next read loads the new record
+	a.Caches.Students.Invalidate(r.Context(), students.Id)
+
 	jsonData, err := json.Marshal(students)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	go a.Redis.Client.Set(a.Ctx, fmt.Sprint(students.Id), jsonData, 10*time.Second)
 
 	// Log update actions
-	go LogActivity("UPDATE_STUDENT", "system")
-	go AuditLog("UPDATE", "STUDENT", students.Id, "system")
+	go LogActivity("UPDATE_STUDENT", Actor(r))
+	go AuditLog("UPDATE", "STUDENT", students.Id, Actor(r))
 
 	//  send response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", ETag(students.Version))
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonData)
 }
 
+// PatchStudentHandler godoc
+// @Summary Patch student
+// @Description Partial update with a JSON merge patch (RFC 7396), e.g. {"dept": "ECE"}. Only the sent fields are changed and validated, null is refused.
+// @Tags Students
+// @Security BearerAuth
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path int true "Student ID"
+// @Param If-Match header string false "ETag of the record as read, the patch fails with 412 if it changed since"
+// @Param patch body Student true "Fields to change"
+// @Success 200 {object} Student
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Router /api/students/{id} [patch]
+// PatchStudentHandler changes some fields of an existing student
+func (a *HybridHandler) PatchStudentHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	idInt, _ := strconv.Atoi(mux.Vars(r)["id"])
+
+	patch, version, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	// Load the current record, the patch applies to it
+	current, err := a.Students.Get(r.Context(), idInt)
+	if err == ErrNotFound {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if version != 0 && version != current.Version {
+		writeVersionConflict(w)
+		return
+	}
+
+	// Apply the patch and validate the changed fields only
+	students, fields, err := applyMergePatch(current, patch, studentPatchFields)
+	if err != nil {
+		writePatchError(w, err)
+		return
+	}
+	if err := validateFields(students, studentRules, fields); err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	// update on the version the patch was applied to, a concurrent write fails with 412,
+	// a changed email has to be verified again
+	if len(fields) > 0 {
+		err = a.Students.Update(r.Context(), &students)
+		if err == ErrNotFound {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		if err == ErrVersionConflict {
+			writeVersionConflict(w)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// drop the cached copy, the next read loads the new record
+		a.Caches.Students.Invalidate(r.Context(), idInt)
+
+		// Log patch actions
+		go LogActivity("PATCH_STUDENT", Actor(r))
+		go AuditLog("PATCH", "STUDENT", idInt, Actor(r))
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", ETag(students.Version))
+	json.NewEncoder(w).Encode(students)
+}
+
 // DeleteStudentHandler godoc
 // @Summary Delete student
 // @Tags Students
@@ -273,30 +389,23 @@ func (a *HybridHandler) DeleteStudentHandler(w http.ResponseWriter, r *http.Requ
 	// Convert id to integer
 	idINT, _ := strconv.Atoi(id)
 
-	// Execute delete query
-	res, err := a.MySQL.db.Exec("DELETE FROM students WHERE id=?", idINT)
-	if err != nil {
-		http.Error(w, "unable to delete", http.StatusInternalServerError)
+	// Delete the record, checking that the student exsists
+	err := a.Students.Delete(r.Context(), idINT)
+	if err == ErrNotFound {
+		http.Error(w, "student not found", http.StatusNotFound)
 		return
 	}
-
-	// Check if student exsists
-	rows, err := res.RowsAffected()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if rows == 0 {
-		http.Error(w, "student not found", http.StatusNotFound)
+		http.Error(w, "unable to delete", http.StatusInternalServerError)
 		return
 	}
 
 	// Remove cache entry
-	go a.Redis.Client.Del(a.Ctx, id)
+	a.Caches.Students.Invalidate(r.Context(), idINT)
 
 	// Log delete response
-	go LogActivity("DELETE_STUDENTS", "system")
-	go AuditLog("DELETE", "STUDENT", idINT, "system")
+	go LogActivity("DELETE_STUDENTS", Actor(r))
+	go AuditLog("DELETE", "STUDENT", idINT, Actor(r))
 
 	// Send success response
 	w.Header().Set("Content-Type", "application/json")
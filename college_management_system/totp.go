@@ -0,0 +1,384 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+// TOTP (RFC 6238) parameters, the defaults every authenticator app supports
+const (
+	TOTPPeriod         = 30 * time.Second
+	TOTPDigits         = 6
+	TOTPSkew           = 1 // accepted steps before and after the current one
+	MFAPendingTTL      = 5 * time.Minute
+	RecoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+// MFAEnrollment is returned when a user starts TOTP enrollment.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFACode is the payload to activate TOTP with a code from the authenticator app.
+type MFACode struct {
+	Code string `json:"code"`
+}
+
+// MFALogin is the second login step payload.
+// It carries the mfa_token from /login and either a TOTP code or a recovery code.
+type MFALogin struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// RecoveryCodes is returned once when TOTP is activated.
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// GenerateTOTPSecret returns a random 160 bit base32 secret
+func GenerateTOTPSecret() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+}
+
+// TOTPCode computes the code of secret for the time step containing t.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(t.Unix()/int64(TOTPPeriod.Seconds()))), nil
+}
+
+// hotp is the HMAC-SHA1 one-time password of RFC 4226
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, code%pow10(TOTPDigits))
+}
+
+// pow10 returns 10^n, the modulus that truncates a code to n digits
+func pow10(n int) uint32 {
+	p := uint32(1)
+	for range n {
+		p *= 10
+	}
+	return p
+}
+
+// ValidateTOTP checks code against secret allowing TOTPSkew steps of clock drift.
+// It returns the matched time step so callers can refuse replays of the same code.
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != TOTPDigits {
+		return 0, false
+	}
+	step := now.Unix() / int64(TOTPPeriod.Seconds())
+	for i := int64(-TOTPSkew); i <= TOTPSkew; i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(step+i))), []byte(code)) == 1 {
+			return step + i, true
+		}
+	}
+	return 0, false
+}
+
+// ProvisioningURI returns the otpauth:// URI authenticator apps read from a QR code.
+func ProvisioningURI(email, secret string) string {
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "College Management System"
+	}
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", strconv.Itoa(TOTPDigits))
+	v.Set("period", strconv.Itoa(int(TOTPPeriod.Seconds())))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+email) + "?" + v.Encode()
+}
+
+// hashRecoveryCode hashes a recovery code for storage, the codes are random so SHA-256 is enough
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.ReplaceAll(code, "-", ""))))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRecoveryCodes returns RecoveryCodeCount random codes formatted as xxxxx-xxxxx
+func newRecoveryCodes() []string {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeLength/2)
+		rand.Read(b)
+		h := hex.EncodeToString(b)
+		codes[i] = h[:recoveryCodeLength/2] + "-" + h[recoveryCodeLength/2:]
+	}
+	return codes
+}
+
+// GenerateMFAPendingToken creates the short-lived token returned by /login when a second factor is required.
+// It is only accepted by /login/mfa.
+func GenerateMFAPendingToken(email string) (string, error) {
+	claims := &Claims{
+		Email:     email,
+		TokenType: "mfa_pending",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(MFAPendingTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return Keys.Sign(claims)
+}
+
+// useTOTPStep records a used time step so a code can't be replayed within its validity window.
+func (a *HybridHandler) useTOTPStep(userID int, step int64) (bool, error) {
+	key := fmt.Sprintf("mfa_used:%d:%d", userID, step)
+	return a.Auth.SetNX(a.Ctx, key, "1", TOTPPeriod*time.Duration(2*TOTPSkew+1))
+}
+
+// useRecoveryCode marks a matching unused recovery code as used.
+func (a *HybridHandler) useRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	return a.Users.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+}
+
+// LoginMFAHandler godoc
+// @Summary Login second step
+// @Description Exchange the mfa_token from /login and a TOTP or recovery code for JWT tokens
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param mfa body MFALogin true "MFA token and code"
+// @Param token_in_body query bool false "Also return the tokens in the JSON body"
+// @Success 200 {object} TokenResponse
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /login/mfa [post]
+// LoginMFAHandler verifies the second factor and completes the login
+func (a *HybridHandler) LoginMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req MFALogin
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := Validation(req.MFAToken)
+	if err != nil || claims.TokenType != "mfa_pending" {
+		http.Error(w, "invalid or expired mfa token", http.StatusUnauthorized)
+		return
+	}
+
+	// codes are rate limited like passwords
+	ip := ClientIP(r)
+	wait, err := a.LoginLockedFor(ip, claims.Email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeRetryAfter(w, wait)
+		return
+	}
+
+	// load the account
+	user, err := a.Users.GetByEmail(r.Context(), claims.Email)
+	if err != nil && err != ErrNotFound {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err == ErrNotFound || user.Disabled || !user.TOTPEnabled {
+		http.Error(w, "invalid or expired mfa token", http.StatusUnauthorized)
+		return
+	}
+
+	// check the TOTP code, or a recovery code
+	var ok bool
+	if req.RecoveryCode != "" {
+		ok, err = a.useRecoveryCode(r.Context(), user.ID, req.RecoveryCode)
+	} else if step, valid := ValidateTOTP(user.TOTPSecret, req.Code, time.Now()); valid {
+		ok, err = a.useTOTPStep(user.ID, step)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		lockout, err := a.RecordLoginFailure(ip, claims.Email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if lockout > 0 {
+			writeRetryAfter(w, lockout)
+			return
+		}
+		http.Error(w, "invalid code", http.StatusUnauthorized)
+		return
+	}
+	if req.RecoveryCode != "" {
+		go AuditLog("USE_RECOVERY_CODE", "USER", user.ID, claims.Email)
+	}
+
+	a.completeLogin(w, r, claims.Email, user.Role)
+}
+
+// EnrollMFAHandler godoc
+// @Summary Start TOTP enrollment
+// @Description Generate a TOTP secret and an otpauth:// provisioning URI to show as a QR code. Activate it with /api/mfa/activate.
+// @Tags MFA
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} MFAEnrollment
+// @Failure 409 {object} map[string]string
+// @Router /api/mfa/enroll [post]
+// EnrollMFAHandler stores a new pending TOTP secret for the current user
+func (a *HybridHandler) EnrollMFAHandler(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("X-User-Email")
+
+	user, err := a.Users.GetByEmail(r.Context(), email)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if user.TOTPEnabled {
+		http.Error(w, "two-factor authentication already enabled", http.StatusConflict)
+		return
+	}
+
+	// store the secret, it only becomes active after a valid code
+	secret := GenerateTOTPSecret()
+	if err := a.Users.SetTOTPSecret(r.Context(), user.ID, secret); err != nil {
+		http.Error(w, "unable to update", http.StatusInternalServerError)
+		return
+	}
+
+	go LogActivity("ENROLL_MFA", email)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFAEnrollment{Secret: secret, ProvisioningURI: ProvisioningURI(email, secret)})
+}
+
+// ActivateMFAHandler godoc
+// @Summary Activate TOTP
+// @Description Confirm enrollment with a code from the authenticator app. Returns one-time recovery codes, they are shown only once.
+// @Tags MFA
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param code body MFACode true "TOTP code"
+// @Success 200 {object} RecoveryCodes
+// @Failure 400 {object} map[string]string
+// @Router /api/mfa/activate [post]
+// ActivateMFAHandler enables TOTP for the current user and issues recovery codes
+func (a *HybridHandler) ActivateMFAHandler(w http.ResponseWriter, r *http.Request) {
+	email := r.Header.Get("X-User-Email")
+
+	var req MFACode
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+
+	user, err := a.Users.GetByEmail(r.Context(), email)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if user.TOTPEnabled {
+		http.Error(w, "two-factor authentication already enabled", http.StatusConflict)
+		return
+	}
+	if user.TOTPSecret == "" {
+		http.Error(w, "start enrollment with /api/mfa/enroll first", http.StatusBadRequest)
+		return
+	}
+	if _, ok := ValidateTOTP(user.TOTPSecret, req.Code, time.Now()); !ok {
+		http.Error(w, "invalid code", http.StatusBadRequest)
+		return
+	}
+
+	// enable TOTP and replace the recovery codes, only their hashes are stored
+	codes := newRecoveryCodes()
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashRecoveryCode(code)
+	}
+	if err := a.Users.EnableTOTP(r.Context(), user.ID, hashes); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	go LogActivity("ACTIVATE_MFA", email)
+	go AuditLog("ACTIVATE_MFA", "USER", user.ID, email)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecoveryCodes{RecoveryCodes: codes})
+}
+
+// ResetMFAHandler godoc
+// @Summary Reset user second factor
+// @Description Remove the TOTP secret and recovery codes of a user, who can then log in with the password only and enroll again
+// @Tags Users
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/mfa [delete]
+// ResetMFAHandler disables TOTP of a user by ID
+func (a *HybridHandler) ResetMFAHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	vars := mux.Vars(r)
+	idINT, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// drop the secret and recovery codes if the user exsists
+	err = a.Users.ResetTOTP(r.Context(), idINT)
+	if err == ErrNotFound {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "unable to update", http.StatusInternalServerError)
+		return
+	}
+
+	// Log reset action
+	go LogActivity("RESET_MFA", Actor(r))
+	go AuditLog("RESET_MFA", "USER", idINT, Actor(r))
+
+	// Send success response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "two-factor authentication reset"})
+}
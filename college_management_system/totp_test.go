@@ -0,0 +1,97 @@
+package collegemanagementsystem
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// the shared secret of the RFC 4226 and RFC 6238 SHA-1 test vectors
+var rfcSecret = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 appendix D
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := hotp(rfcSecret, uint64(counter)); got != code {
+			t.Errorf("hotp(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rfcSecret)
+
+	// RFC 6238 appendix B lists 8 digit codes, ours are their last TOTPDigits digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		now := time.Unix(v.unix, 0)
+		code := v.code[len(v.code)-TOTPDigits:]
+		step, ok := ValidateTOTP(secret, code, now)
+		if !ok {
+			t.Errorf("ValidateTOTP(%s) at %d refused", code, v.unix)
+			continue
+		}
+		if want := v.unix / int64(TOTPPeriod.Seconds()); step != want {
+			t.Errorf("ValidateTOTP(%s) at %d step = %d, want %d", code, v.unix, step, want)
+		}
+	}
+
+	// 1111111109 is step 37037036, codes of the neighbouring steps are accepted for clock drift
+	now := time.Unix(1111111109, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{"previous step", secret, hotp(rfcSecret, 37037035), true},
+		{"next step", secret, hotp(rfcSecret, 37037037), true},
+		{"two steps back", secret, hotp(rfcSecret, 37037034), false},
+		{"two steps ahead", secret, hotp(rfcSecret, 37037038), false},
+		{"lower case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "081804", true},
+		{"wrong code", secret, "000000", false},
+		{"too short", secret, "81804", false},
+		{"too long", secret, "07081804", false},
+		{"invalid secret", "not base32!", "081804", false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok != tt.ok {
+			t.Errorf("%s: ValidateTOTP(%q) = %v, want %v", tt.name, tt.code, ok, tt.ok)
+		}
+	}
+}
+
+func TestUseTOTPStep(t *testing.T) {
+	h := NewMemoryHandler()
+	steps := []struct {
+		userID int
+		step   int64
+		fresh  bool
+	}{
+		{1, 100, true},
+		{1, 100, false}, // replay of the same code
+		{1, 101, true},
+		{2, 100, true}, // another account may use the same step
+		{2, 100, false},
+	}
+	for i, s := range steps {
+		fresh, err := h.useTOTPStep(s.userID, s.step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fresh != s.fresh {
+			t.Errorf("call %d: useTOTPStep(%d, %d) = %v, want %v", i+1, s.userID, s.step, fresh, s.fresh)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User represents a login account stored in MySQL.
+// Password is only accepted on input, the stored value is a bcrypt hash.
+type User struct {
+	ID        int    `json:"id"`
+	Email     string `json:"email"`
+	Password  string `json:"password,omitempty"`
+	Role      string `json:"role"`
+	Disabled  bool   `json:"disabled"`
+	CreatedAt string `json:"created_at"`
+
+	// stored with the account and never sent
+	PasswordHash string `json:"-"`
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `json:"-"`
+	// StudentID or LecturerID is the record linked to the account, 0 when none
+	StudentID  int `json:"-"`
+	LecturerID int `json:"-"`
+}
+
+// PasswordReset represents the reset password request payload.
+type PasswordReset struct {
+	Password string `json:"password"`
+}
+
+// RoleChange represents the change role request payload.
+type RoleChange struct {
+	Role string `json:"role"`
+}
+
+// minimum length of an account password
+const MinPasswordLength = 8
+
+// ValidateUser validates incoming user data
+func ValidateUser(user User) error {
+	// validate email
+	if strings.TrimSpace(user.Email) == "" {
+		return fmt.Errorf("email is invalid and empty")
+	}
+	if !strings.Contains(user.Email, "@") {
+		return fmt.Errorf("email is invalid and does not contain @")
+	}
+	// validate role
+	if err := ValidateRole(user.Role); err != nil {
+		return err
+	}
+	// validate password
+	return ValidatePassword(user.Password)
+}
+
+// ValidatePassword checks the password policy for user accounts
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	return nil
+}
+
+// HashPassword hashes a plain text password with bcrypt.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// dummyPasswordHash is a bcrypt hash with the default cost that no account uses.
+// Logins with an unknown email are checked against it, so they take as long as a wrong password.
+const dummyPasswordHash = "$2a$10$JrGq6nvW9uSrPniD/2k20.DNcs8iTI4UkT.7CwPChc39bo8P7gxeW"
+
+// CheckPassword reports whether password matches the stored bcrypt hash.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// SeedAdminUser creates the first admin account from the EMAIL and PASSWORD
+// environment variables when the users table is still empty.
+func SeedAdminUser(users UserRepository) error {
+	email, password := os.Getenv("EMAIL"), os.Getenv("PASSWORD")
+	if email == "" || password == "" {
+		return nil
+	}
+
+	// only seed an empty users table
+	existing, err := users.List(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return nil
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := users.Create(context.Background(), &User{Email: email, PasswordHash: hash, Role: RoleAdmin}); err != nil {
+		return err
+	}
+	log.Printf("seeded initial user %s\n", email)
+	return nil
+}
+
+// RegisterUserHandler godoc
+// @Summary Register user
+// @Description Create a new login account
+// @Tags Users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param user body User true "User Data"
+// @Success 201 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/users [post]
+// RegisterUserHandler handles creation of a new user account
+func (a *HybridHandler) RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Decode incoming JSON request body
+	var user User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+
+	// validate requests payload
+	if err := ValidateUser(user); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"err": err.Error()})
+		return
+	}
+
+	// hash password before storing it
+	hash, err := HashPassword(user.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user.PasswordHash = hash
+	user.Password = ""
+
+	// Insert user record, duplicate emails are rejected
+	err = a.Users.Create(r.Context(), &user)
+	if err == ErrDuplicateEmail {
+		http.Error(w, "user already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Unable to insert", http.StatusInternalServerError)
+		return
+	}
+
+	// Log activity and Audit trail
+	go LogActivity("CREATE_USER", Actor(r))
+	go AuditLog("CREATE", "USER", user.ID, Actor(r))
+
+	// send success response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+// GetUsersHandler godoc
+// @Summary Get all users
+// @Description Retrieve all login accounts
+// @Tags Users
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} User
+// @Router /api/users [get]
+// GetUsersHandler to get all users
+func (a *HybridHandler) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+
+	// fetch user records
+	users, err := a.Users.List(r.Context())
+	if err != nil {
+		http.Error(w, "unable to fetch users", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// DisableUserHandler godoc
+// @Summary Disable user
+// @Description Disable a login account so it can no longer sign in
+// @Tags Users
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/disable [post]
+// DisableUserHandler disables a user by ID
+func (a *HybridHandler) DisableUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	vars := mux.Vars(r)
+	idINT, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// Check if user exsists
+	user, err := a.Users.Get(r.Context(), idINT)
+	if err == ErrNotFound {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Execute update query
+	if err := a.Users.Disable(r.Context(), idINT); err != nil {
+		http.Error(w, "unable to disable", http.StatusInternalServerError)
+		return
+	}
+
+	// revoke refresh tokens so the account is logged out everywhere
+	if err := a.RevokeAllSessions(user.Email); err != nil {
+		http.Error(w, "unable to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	// Log disable action
+	go LogActivity("DISABLE_USER", Actor(r))
+	go AuditLog("DISABLE", "USER", idINT, Actor(r))
+
+	// Send success response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "user disabled"})
+}
+
+// ResetUserPasswordHandler godoc
+// @Summary Reset user password
+// @Description Set a new password for a login account
+// @Tags Users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param password body PasswordReset true "New password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/password [post]
+// ResetUserPasswordHandler replaces the password of a user by ID
+func (a *HybridHandler) ResetUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	vars := mux.Vars(r)
+	idINT, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// Decode request body
+	var reset PasswordReset
+	if err := json.NewDecoder(r.Body).Decode(&reset); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+
+	// validate new password
+	if err := ValidatePassword(reset.Password); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"err": err.Error()})
+		return
+	}
+
+	hash, err := HashPassword(reset.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Check if user exsists
+	user, err := a.Users.Get(r.Context(), idINT)
+	if err == ErrNotFound {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Execute update query
+	if err := a.Users.SetPassword(r.Context(), idINT, hash); err != nil {
+		http.Error(w, "unable to update", http.StatusInternalServerError)
+		return
+	}
+
+	// sessions opened with the old password are revoked
+	if err := a.RevokeAllSessions(user.Email); err != nil {
+		http.Error(w, "unable to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	// Log reset action
+	go LogActivity("RESET_PASSWORD", Actor(r))
+	go AuditLog("RESET_PASSWORD", "USER", idINT, Actor(r))
+
+	// Send success response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "password reset"})
+}
+
+// SetUserRoleHandler godoc
+// @Summary Change user role
+// @Description Assign a role (admin, registrar, librarian, lecturer, student) to a login account
+// @Tags Users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param role body RoleChange true "New role"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/role [put]
+// SetUserRoleHandler changes the role of a user by ID
+func (a *HybridHandler) SetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	vars := mux.Vars(r)
+	idINT, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// Decode request body
+	var change RoleChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+
+	// validate role
+	if err := ValidateRole(change.Role); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"err": err.Error()})
+		return
+	}
+
+	// Execute update if the user exsists
+	err = a.Users.SetRole(r.Context(), idINT, change.Role)
+	if err == ErrNotFound {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "unable to update", http.StatusInternalServerError)
+		return
+	}
+
+	// Log role change
+	go LogActivity("CHANGE_ROLE", Actor(r))
+	go AuditLog("CHANGE_ROLE", "USER", idINT, Actor(r))
+
+	// Send success response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "role updated"})
+}
+
+// UnlockUserHandler godoc
+// @Summary Unlock user
+// @Description Clear the failed login counter and lockout of an account
+// @Tags Users
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/users/{id}/unlock [post]
+// UnlockUserHandler removes the login lockout of a user by ID
+func (a *HybridHandler) UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+
+	// Extract id from URL
+	vars := mux.Vars(r)
+	idINT, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// Check if user exsists
+	user, err := a.Users.Get(r.Context(), idINT)
+	if err == ErrNotFound {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// clear counters, ClearLoginFailures writes the UNLOCK audit entry
+	if err := a.ClearLoginFailures(user.Email, Actor(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	go LogActivity("UNLOCK_USER", Actor(r))
+
+	// Send success response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "user unlocked"})
+}
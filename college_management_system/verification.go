@@ -0,0 +1,372 @@
+package collegemanagementsystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+// Password reset and email verification links carry a signed single-use token.
+// The token is a JWT of type "password_reset" or "email_verify" whose jti is stored
+// in the AuthStore (Redis) under onetime:<jti> until it expires or is used.
+
+// one-time token types and TTLs
+const (
+	PasswordResetToken = "password_reset"
+	EmailVerifyToken   = "email_verify"
+	PasswordResetTTL   = 30 * time.Minute
+	EmailVerifyTTL     = 24 * time.Hour
+	ResetMailCooldown  = time.Minute
+)
+
+// ErrInvalidOneTimeToken is returned for unknown, expired or already used tokens
+var ErrInvalidOneTimeToken = errors.New("invalid, expired or already used token")
+
+// ForgotPassword is the request payload to send a password reset link.
+type ForgotPassword struct {
+	Email string `json:"email"`
+}
+
+// NewPassword is the request payload to set a new password with a reset token.
+type NewPassword struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// records whose email can be verified, keyed by the token subject prefix
+var verifiableKinds = map[string]bool{
+	"student":  true,
+	"lecturer": true,
+}
+
+// verifiableEmail returns the email of a student or lecturer record and whether it is verified
+func (a *HybridHandler) verifiableEmail(ctx context.Context, kind string, id int) (string, bool, error) {
+	if kind == "student" {
+		s, err := a.Students.Get(ctx, id)
+		return s.Email, s.EmailVerified, err
+	}
+	l, err := a.Lecturers.Get(ctx, id)
+	return l.Email, l.EmailVerified, err
+}
+
+func oneTimeKey(jti string) string { return "onetime:" + jti }
+
+// appURL builds an absolute link from APP_BASE_URL
+func appURL(path string, query url.Values) string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return strings.TrimSuffix(base, "/") + path + "?" + query.Encode()
+}
+
+// GenerateOneTimeToken creates a signed single-use token of tokenType for email and subject.
+// Its jti is stored in the AuthStore for ttl.
+func (a *HybridHandler) GenerateOneTimeToken(tokenType, email, subject string, ttl time.Duration) (string, error) {
+	jti := randomID()
+	if err := a.Auth.Set(a.Ctx, oneTimeKey(jti), subject, ttl); err != nil {
+		return "", err
+	}
+	claims := &Claims{
+		Email:     email,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return Keys.Sign(claims)
+}
+
+// ConsumeOneTimeToken validates a token of tokenType and deletes its jti so it can't be used again.
+func (a *HybridHandler) ConsumeOneTimeToken(tokenstr, tokenType string) (*Claims, error) {
+	claims, err := Validation(tokenstr)
+	if err != nil || claims.TokenType != tokenType || claims.ID == "" {
+		return nil, ErrInvalidOneTimeToken
+	}
+
+	// DEL is atomic, only one request can consume the token
+	deleted, err := a.Auth.Del(a.Ctx, oneTimeKey(claims.ID))
+	if err != nil {
+		return nil, err
+	}
+	if deleted == 0 {
+		return nil, ErrInvalidOneTimeToken
+	}
+	return claims, nil
+}
+
+// ForgotPasswordHandler godoc
+// @Summary Request password reset
+// @Description Email a single-use password reset link. Always answers 200 so account emails can't be probed.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param email body ForgotPassword true "Account email"
+// @Success 200 {object} map[string]string
+// @Router /password/forgot [post]
+// ForgotPasswordHandler sends a password reset link to an existing account
+func (a *HybridHandler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPassword
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+	response := map[string]string{"message": "if the account exists a reset link has been sent"}
+
+	// only enabled accounts get a link
+	user, err := a.Users.GetByEmail(r.Context(), req.Email)
+	if err != nil && err != ErrNotFound {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err == ErrNotFound || user.Disabled {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// from here on only the stored address is used, never the one the client typed
+	// one mail per cooldown so the endpoint can't be used to flood an inbox
+	first, err := a.Auth.SetNX(a.Ctx, "pwreset_cooldown:"+strings.ToLower(user.Email), "1", ResetMailCooldown)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if first {
+		token, err := a.GenerateOneTimeToken(PasswordResetToken, user.Email, "user:"+user.Email, PasswordResetTTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		link := appURL("/password/reset", url.Values{"token": {token}})
+		body := fmt.Sprintf("A password reset was requested for your account.\n\nUse this token or link within %s:\n%s\n\nToken: %s\n\nIf you did not ask for it, ignore this email.", PasswordResetTTL, link, token)
+		if err := a.Mailer.Send(user.Email, "Reset your password", body); err != nil {
+			http.Error(w, "unable to send email", http.StatusInternalServerError)
+			return
+		}
+		go AuditLog("REQUEST_PASSWORD_RESET", "USER", user.Email, "system")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ResetPasswordHandler godoc
+// @Summary Reset password with token
+// @Description Set a new password with the token from the reset email. The token works once, all sessions are logged out.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param reset body NewPassword true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /password/reset [post]
+// ResetPasswordHandler sets a new password using a password reset token
+func (a *HybridHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req NewPassword
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Failed to decode response", http.StatusBadRequest)
+		return
+	}
+
+	// validate the password before using up the token
+	if err := ValidatePassword(req.Password); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"err": err.Error()})
+		return
+	}
+
+	claims, err := a.ConsumeOneTimeToken(req.Token, PasswordResetToken)
+	if err == ErrInvalidOneTimeToken {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// a disabled or deleted account can't be reset
+	user, err := a.Users.GetByEmail(r.Context(), claims.Email)
+	if err == ErrNotFound || (err == nil && user.Disabled) {
+		http.Error(w, ErrInvalidOneTimeToken.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hash, err := HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := a.Users.SetPassword(r.Context(), user.ID, hash); err != nil {
+		http.Error(w, "unable to update", http.StatusInternalServerError)
+		return
+	}
+
+	// log out old sessions and lift a lockout
+	if err := a.RevokeAllSessions(claims.Email); err != nil {
+		http.Error(w, "unable to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	if err := a.ClearLoginFailures(claims.Email, claims.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	go LogActivity("RESET_PASSWORD", claims.Email)
+	go AuditLog("RESET_PASSWORD", "USER", claims.Email, claims.Email)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "password updated, please log in again"})
+}
+
+// sendVerification emails a verification link for a student or lecturer record
+func (a *HybridHandler) sendVerification(w http.ResponseWriter, r *http.Request, kind string) {
+
+	// Extract id from URL
+	vars := mux.Vars(r)
+	idINT, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	// look up the email of the record
+	email, verified, err := a.verifiableEmail(r.Context(), kind, idINT)
+	if err == ErrNotFound {
+		http.Error(w, kind+" not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if verified {
+		http.Error(w, "email already verified", http.StatusConflict)
+		return
+	}
+
+	token, err := a.GenerateOneTimeToken(EmailVerifyToken, email, fmt.Sprintf("%s:%d", kind, idINT), EmailVerifyTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	link := appURL("/verify-email", url.Values{"token": {token}})
+	body := fmt.Sprintf("Please confirm that this email address belongs to you by opening this link within %s:\n%s", EmailVerifyTTL, link)
+	if err := a.Mailer.Send(email, "Verify your email address", body); err != nil {
+		http.Error(w, "unable to send email", http.StatusInternalServerError)
+		return
+	}
+
+	go LogActivity("SEND_EMAIL_VERIFICATION", Actor(r))
+	go AuditLog("SEND_EMAIL_VERIFICATION", strings.ToUpper(kind), idINT, Actor(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "verification email sent"})
+}
+
+// SendStudentVerificationHandler godoc
+// @Summary Send student email verification
+// @Description Email a single-use verification link to the address of the student record
+// @Tags Students
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Student ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/students/{id}/verify-email [post]
+// SendStudentVerificationHandler sends a verification link to a student
+func (a *HybridHandler) SendStudentVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	a.sendVerification(w, r, "student")
+}
+
+// SendLecturerVerificationHandler godoc
+// @Summary Send lecturer email verification
+// @Description Email a single-use verification link to the address of the lecturer record
+// @Tags Lecturers
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Lecturer ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/lecturers/{id}/verify-email [post]
+// SendLecturerVerificationHandler sends a verification link to a lecturer
+func (a *HybridHandler) SendLecturerVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	a.sendVerification(w, r, "lecturer")
+}
+
+// VerifyEmailHandler godoc
+// @Summary Confirm email address
+// @Description Mark the email of a student or lecturer as verified with the token from the verification email
+// @Tags Authentication
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /verify-email [get]
+// VerifyEmailHandler confirms an email verification token
+func (a *HybridHandler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := a.ConsumeOneTimeToken(r.URL.Query().Get("token"), EmailVerifyToken)
+	if err == ErrInvalidOneTimeToken {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// subject is "<kind>:<id>"
+	kind, id, _ := strings.Cut(claims.Subject, ":")
+	idINT, err := strconv.Atoi(id)
+	if !verifiableKinds[kind] || err != nil {
+		http.Error(w, ErrInvalidOneTimeToken.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the record must still have the email the link was sent to
+	if kind == "student" {
+		err = a.Students.VerifyEmail(r.Context(), idINT, claims.Email)
+	} else {
+		err = a.Lecturers.VerifyEmail(r.Context(), idINT, claims.Email)
+	}
+	if err == ErrNotFound {
+		http.Error(w, "the email address of this record has changed, request a new link", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "unable to update", http.StatusInternalServerError)
+		return
+	}
+
+	// drop the cached record, it still says unverified
+	if kind == "student" {
+		a.Caches.Students.Invalidate(r.Context(), idINT)
+	} else {
+		a.Caches.Lecturers.Invalidate(r.Context(), idINT)
+	}
+
+	go AuditLog("VERIFY_EMAIL", strings.ToUpper(kind), idINT, claims.Email)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "email verified"})
+}
@@ -0,0 +1,10 @@
+// Package db embeds the SQL migrations so the binary can apply them without the source tree.
+package db
+
+import "embed"
+
+// Migrations holds the golang-migrate style NNNNNN_name.up.sql / .down.sql files,
+// one directory per dialect: migrations/mysql, migrations/postgres and migrations/sqlite.
+//
+//go:embed migrations/*/*.sql
+var Migrations embed.FS
@@ -1,7 +1,7 @@
+DROP TABLE IF EXISTS borrow_records;
+
 DROP TABLE IF EXISTS students;
 
 DROP TABLE IF EXISTS lecturers;
 
 DROP TABLE IF EXISTS libraries;
-
-DROP TABLE IF EXISTS borrow_records;
@@ -0,0 +1,4 @@
+-- Existing accounts had full access before roles existed, so they start as admin.
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'admin';
+
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'student';
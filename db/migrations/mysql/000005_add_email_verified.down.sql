@@ -0,0 +1,3 @@
+ALTER TABLE students DROP COLUMN email_verified;
+
+ALTER TABLE lecturers DROP COLUMN email_verified;
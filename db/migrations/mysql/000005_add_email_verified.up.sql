@@ -0,0 +1,3 @@
+ALTER TABLE students ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE lecturers ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
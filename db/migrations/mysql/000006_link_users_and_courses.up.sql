@@ -0,0 +1,21 @@
+ALTER TABLE users
+    ADD COLUMN student_id INT NULL UNIQUE,
+    ADD COLUMN lecturer_id INT NULL UNIQUE,
+    ADD CONSTRAINT fk_users_student FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_users_lecturer FOREIGN KEY (lecturer_id) REFERENCES lecturers(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS courses(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    lecturer_id INT NULL,
+    FOREIGN KEY (lecturer_id) REFERENCES lecturers(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS course_enrollments(
+    course_id INT NOT NULL,
+    student_id INT NOT NULL,
+    PRIMARY KEY (course_id, student_id),
+    FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE,
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+ALTER TABLE lecturers DROP INDEX ft_lecturers_search;
+ALTER TABLE students DROP INDEX ft_students_search;
+ALTER TABLE libraries DROP INDEX ft_libraries_search;
@@ -0,0 +1,3 @@
+ALTER TABLE libraries ADD FULLTEXT INDEX ft_libraries_search (title, book_name, author);
+ALTER TABLE students ADD FULLTEXT INDEX ft_students_search (name, email);
+ALTER TABLE lecturers ADD FULLTEXT INDEX ft_lecturers_search (name);
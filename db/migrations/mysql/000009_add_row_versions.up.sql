@@ -0,0 +1,4 @@
+-- version is bumped on every write, it is the ETag of the row
+ALTER TABLE students ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE lecturers ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE libraries ADD COLUMN version INT NOT NULL DEFAULT 1;
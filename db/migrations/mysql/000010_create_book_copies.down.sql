@@ -0,0 +1,5 @@
+ALTER TABLE borrow_records
+    DROP FOREIGN KEY fk_borrow_records_copy,
+    DROP COLUMN copy_id;
+
+DROP TABLE IF EXISTS book_copies;
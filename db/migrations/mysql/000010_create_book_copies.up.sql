@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS book_copies(
+    copy_id INT AUTO_INCREMENT PRIMARY KEY,
+    book_id INT NOT NULL,
+    barcode VARCHAR(64) NOT NULL UNIQUE,
+    copy_condition VARCHAR(20) NOT NULL DEFAULT 'good',
+    shelf_location VARCHAR(100) NOT NULL DEFAULT '',
+    on_loan BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (book_id) REFERENCES libraries(book_id)
+);
+
+ALTER TABLE borrow_records
+    ADD COLUMN copy_id INT NULL,
+    ADD CONSTRAINT fk_borrow_records_copy FOREIGN KEY (copy_id) REFERENCES book_copies(copy_id);
+
+-- existing books get one copy per available copy, barcodes BK<book_id>-<n>
+SET SESSION cte_max_recursion_depth = 100000;
+
+INSERT INTO book_copies (book_id, barcode)
+WITH RECURSIVE n (i) AS (
+    SELECT 1 UNION ALL SELECT i+1 FROM n WHERE i < (SELECT COALESCE(MAX(available_copies), 0) FROM libraries)
+)
+SELECT l.book_id, CONCAT('BK', l.book_id, '-', n.i) FROM libraries l JOIN n ON n.i <= l.available_copies;
+
+-- and one copy on loan per open loan, barcodes BK<book_id>-L<borrow_id>
+INSERT INTO book_copies (book_id, barcode, on_loan)
+SELECT book_id, CONCAT('BK', book_id, '-L', borrow_id), TRUE FROM borrow_records WHERE return_date IS NULL;
+
+UPDATE borrow_records SET copy_id = (
+    SELECT c.copy_id FROM book_copies c WHERE c.barcode = CONCAT('BK', borrow_records.book_id, '-L', borrow_records.borrow_id)
+) WHERE return_date IS NULL;
+
+UPDATE libraries SET available_copies = (
+    SELECT COUNT(*) FROM book_copies c WHERE c.book_id = libraries.book_id AND c.on_loan = FALSE
+);
@@ -0,0 +1,31 @@
+-- loans recorded before due dates have none and are never overdue
+ALTER TABLE borrow_records
+    ADD COLUMN due_date DATE NULL,
+    ADD COLUMN fine_per_day INT NOT NULL DEFAULT 0;
+
+-- one fine per late return, amounts are in cents
+CREATE TABLE IF NOT EXISTS fines(
+    fine_id INT AUTO_INCREMENT PRIMARY KEY,
+    borrow_id INT NOT NULL UNIQUE,
+    user_id INT NOT NULL,
+    user_type VARCHAR(20) NOT NULL,
+    book_id INT NOT NULL,
+    days_late INT NOT NULL,
+    amount INT NOT NULL,
+    paid INT NOT NULL DEFAULT 0,
+    waived INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_fines_user (user_type, user_id)
+);
+
+-- payments and waivers, the ledger behind paid and waived
+CREATE TABLE IF NOT EXISTS fine_transactions(
+    transaction_id INT AUTO_INCREMENT PRIMARY KEY,
+    fine_id INT NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    amount INT NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    recorded_by VARCHAR(100) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (fine_id) REFERENCES fines(fine_id)
+);
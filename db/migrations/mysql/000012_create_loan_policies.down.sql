@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS loan_policies;
+
+ALTER TABLE libraries DROP COLUMN category;
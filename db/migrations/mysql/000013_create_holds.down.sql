@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS holds;
+
+ALTER TABLE book_copies DROP COLUMN on_hold;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS students(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    age INT NOT NULL,
+    email VARCHAR(100) NOT NULL,
+    dept VARCHAR(50)
+);
+
+CREATE TABLE IF NOT EXISTS lecturers(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    age INT NOT NULL,
+    email VARCHAR(100) NOT NULL,
+    designation VARCHAR(50) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS libraries(
+    book_id SERIAL PRIMARY KEY,
+    book_name VARCHAR(100) NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    author VARCHAR(100) NOT NULL,
+    available_copies INT
+);
+
+CREATE TABLE IF NOT EXISTS borrow_records(
+    borrow_id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    user_type VARCHAR(100) NOT NULL,
+    book_id INT NOT NULL REFERENCES libraries(book_id),
+    borrow_date DATE,
+    return_date DATE
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS course_enrollments;
+
+DROP TABLE IF EXISTS courses;
+
+ALTER TABLE users
+    DROP CONSTRAINT fk_users_student,
+    DROP CONSTRAINT fk_users_lecturer,
+    DROP COLUMN student_id,
+    DROP COLUMN lecturer_id;
@@ -0,0 +1,18 @@
+ALTER TABLE users
+    ADD COLUMN student_id INT NULL UNIQUE,
+    ADD COLUMN lecturer_id INT NULL UNIQUE,
+    ADD CONSTRAINT fk_users_student FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_users_lecturer FOREIGN KEY (lecturer_id) REFERENCES lecturers(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS courses(
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    lecturer_id INT NULL REFERENCES lecturers(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS course_enrollments(
+    course_id INT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    student_id INT NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    PRIMARY KEY (course_id, student_id)
+);
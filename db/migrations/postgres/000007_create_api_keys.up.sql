@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+    id SERIAL PRIMARY KEY,
+    key_id CHAR(16) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL
+);
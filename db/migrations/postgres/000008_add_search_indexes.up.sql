@@ -0,0 +1,2 @@
+-- FULLTEXT indexes are MySQL only, search uses LIKE on this backend.
+-- The migration is kept so versions line up across backends.
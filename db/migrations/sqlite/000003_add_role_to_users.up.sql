@@ -0,0 +1,5 @@
+-- SQLite can't change a column default, so new accounts get 'student'
+-- and the existing ones (which had full access before roles existed) are set to admin.
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'student';
+
+UPDATE users SET role = 'admin';
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS user_recovery_codes;
+
+ALTER TABLE users DROP COLUMN totp_secret;
+
+ALTER TABLE users DROP COLUMN totp_enabled;
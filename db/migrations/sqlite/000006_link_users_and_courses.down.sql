@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS course_enrollments;
+
+DROP TABLE IF EXISTS courses;
+
+DROP INDEX IF EXISTS users_student_id;
+
+DROP INDEX IF EXISTS users_lecturer_id;
+
+ALTER TABLE users DROP COLUMN student_id;
+
+ALTER TABLE users DROP COLUMN lecturer_id;
@@ -0,0 +1,21 @@
+-- SQLite can't add UNIQUE columns, uniqueness comes from the indexes below
+ALTER TABLE users ADD COLUMN student_id INT NULL REFERENCES students(id) ON DELETE SET NULL;
+
+ALTER TABLE users ADD COLUMN lecturer_id INT NULL REFERENCES lecturers(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_student_id ON users(student_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_lecturer_id ON users(lecturer_id);
+
+CREATE TABLE IF NOT EXISTS courses(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    lecturer_id INT NULL REFERENCES lecturers(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS course_enrollments(
+    course_id INT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    student_id INT NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    PRIMARY KEY (course_id, student_id)
+);
@@ -0,0 +1 @@
+-- nothing to drop, see the up migration
@@ -0,0 +1,5 @@
+ALTER TABLE libraries DROP COLUMN version;
+
+ALTER TABLE lecturers DROP COLUMN version;
+
+ALTER TABLE students DROP COLUMN version;
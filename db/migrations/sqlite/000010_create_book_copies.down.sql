@@ -0,0 +1,3 @@
+ALTER TABLE borrow_records DROP COLUMN copy_id;
+
+DROP TABLE IF EXISTS book_copies;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS fine_transactions;
+
+DROP TABLE IF EXISTS fines;
+
+ALTER TABLE borrow_records DROP COLUMN due_date;
+
+ALTER TABLE borrow_records DROP COLUMN fine_per_day;
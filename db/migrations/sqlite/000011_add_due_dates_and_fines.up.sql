@@ -0,0 +1,31 @@
+-- loans recorded before due dates have none and are never overdue
+ALTER TABLE borrow_records ADD COLUMN due_date DATE NULL;
+
+ALTER TABLE borrow_records ADD COLUMN fine_per_day INT NOT NULL DEFAULT 0;
+
+-- one fine per late return, amounts are in cents
+CREATE TABLE IF NOT EXISTS fines(
+    fine_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    borrow_id INT NOT NULL UNIQUE,
+    user_id INT NOT NULL,
+    user_type VARCHAR(20) NOT NULL,
+    book_id INT NOT NULL,
+    days_late INT NOT NULL,
+    amount INT NOT NULL,
+    paid INT NOT NULL DEFAULT 0,
+    waived INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_fines_user ON fines (user_type, user_id);
+
+-- payments and waivers, the ledger behind paid and waived
+CREATE TABLE IF NOT EXISTS fine_transactions(
+    transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    fine_id INT NOT NULL REFERENCES fines(fine_id),
+    kind VARCHAR(10) NOT NULL,
+    amount INT NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    recorded_by VARCHAR(100) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,22 @@
+-- standard, short_loan or reference
+ALTER TABLE libraries ADD COLUMN category VARCHAR(20) NOT NULL DEFAULT 'standard';
+
+-- one policy per user type and book category, a category without a policy can't be borrowed
+CREATE TABLE IF NOT EXISTS loan_policies(
+    user_type VARCHAR(20) NOT NULL,
+    category VARCHAR(20) NOT NULL,
+    max_loans INT NOT NULL,
+    loan_days INT NOT NULL,
+    renewal_limit INT NOT NULL,
+    fine_per_day INT NOT NULL,
+    PRIMARY KEY (user_type, category)
+);
+
+-- max_loans 0 makes reference books reference-only
+INSERT INTO loan_policies (user_type, category, max_loans, loan_days, renewal_limit, fine_per_day) VALUES
+    ('student', 'standard', 5, 14, 2, 50),
+    ('student', 'short_loan', 2, 3, 0, 100),
+    ('student', 'reference', 0, 0, 0, 0),
+    ('lecturer', 'standard', 10, 30, 3, 25),
+    ('lecturer', 'short_loan', 3, 7, 1, 50),
+    ('lecturer', 'reference', 0, 0, 0, 0);
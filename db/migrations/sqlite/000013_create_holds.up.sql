@@ -0,0 +1,18 @@
+-- a copy kept for the next person in the queue is on hold, it can't be lent to anyone else
+ALTER TABLE book_copies ADD COLUMN on_hold BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- FIFO queue per book: waiting holds are served by hold_id, a ready hold has a copy and expires_at
+CREATE TABLE IF NOT EXISTS holds(
+    hold_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    book_id INT NOT NULL REFERENCES libraries(book_id),
+    user_id INT NOT NULL,
+    user_type VARCHAR(20) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'waiting',
+    copy_id INT NULL REFERENCES book_copies(copy_id),
+    placed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ready_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NULL,
+    closed_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_holds_book ON holds (book_id, status);
@@ -0,0 +1,11 @@
+-- every renewal of a loan, old_due_date is NULL for loans made before due dates
+CREATE TABLE IF NOT EXISTS borrow_renewals(
+    renewal_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    borrow_id INT NOT NULL REFERENCES borrow_records(borrow_id),
+    old_due_date DATE NULL,
+    new_due_date DATE NOT NULL,
+    renewed_by VARCHAR(100) NOT NULL,
+    renewed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_borrow_renewals_borrow ON borrow_renewals (borrow_id);
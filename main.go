@@ -1,8 +1,17 @@
 package main
 
-import collegemanagementsystem "college_management_system/college_management_system"
+import (
+	"os"
+
+	collegemanagementsystem "college_management_system/college_management_system"
+)
 
 func main() {
+	// "migrate ..." manages the database schema, anything else starts the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		collegemanagementsystem.MigrateCommand(os.Args[2:])
+		return
+	}
 	collegemanagementsystem.CollegeManagementSystem()
 
 }